@@ -0,0 +1,327 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qrcode
+
+import (
+	"image"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/aztec"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/datamatrix"
+	"github.com/boombuler/barcode/ean"
+	"github.com/boombuler/barcode/pdf417"
+	"github.com/pkg/errors"
+)
+
+const (
+	// SymbologyCode128 Code 128, ASCII only
+	SymbologyCode128 = "code128"
+	// SymbologyEAN13 EAN-13, 12 digits and an optional check digit
+	SymbologyEAN13 = "ean13"
+	// SymbologyUPCA UPC-A, 11 digits and an optional check digit
+	SymbologyUPCA = "upca"
+	// SymbologyDataMatrix Data Matrix ECC 200, ASCII only
+	SymbologyDataMatrix = "datamatrix"
+	// SymbologyPDF417 PDF417, whose security level follows ECC
+	SymbologyPDF417 = "pdf417"
+	// SymbologyAztec Aztec Code, whose error correction follows ECC
+	SymbologyAztec = "aztec"
+)
+
+const (
+	// DefaultLinearBorder default quiet zone width of linear(1D) barcodes in modules
+	DefaultLinearBorder = 11
+
+	// minBarHeight and maxBarHeight limits height of bars in modules
+	minBarHeight = 24
+	maxBarHeight = 72
+)
+
+// ErrInvalidContent is returned when content can not be encoded by symbology
+var ErrInvalidContent = errors.New("invalid content")
+
+// Symbology generates a kind of barcode other than QR Code family,
+// which is rendered by QREncoder like QR Code.
+type Symbology interface {
+	// Validate tells why content can not be encoded, nil if it can
+	Validate(content string) error
+	// Modules encodes content into modules without quiet zone,
+	// dark ones are true. ecc is ECCLow to ECCHigh, which may be ignored.
+	// Linear(1D) barcode has only one row, which is stretched into bars.
+	Modules(content, ecc string) ([][]bool, error)
+}
+
+var (
+	symbologiesMu sync.RWMutex
+	symbologies   = make(map[string]Symbology)
+)
+
+// RegisterSymbology makes symbology available to QREncoder by name,
+// which replaces any registered one of the same name.
+// QR Code family(SymbologyQR, SymbologyMicroQR and SymbologyRMQR) can not be replaced.
+func RegisterSymbology(name string, symbology Symbology) {
+	symbologiesMu.Lock()
+	defer symbologiesMu.Unlock()
+	symbologies[name] = symbology
+}
+
+// lookupSymbology finds registered symbology by name
+func lookupSymbology(name string) (symbology Symbology, ok bool) {
+	symbologiesMu.RLock()
+	defer symbologiesMu.RUnlock()
+	symbology, ok = symbologies[name]
+	return
+}
+
+// Symbologies lists every supported symbology in name order
+func Symbologies() (names []string) {
+	names = []string{SymbologyQR, SymbologyMicroQR, SymbologyRMQR}
+	symbologiesMu.RLock()
+	for name := range symbologies {
+		if !IsQRFamily(name) {
+			names = append(names, name)
+		}
+	}
+	symbologiesMu.RUnlock()
+	sort.Strings(names)
+	return
+}
+
+// ValidateContent tells why content can not be encoded by symbology,
+// which is wrapped ErrInvalidContent. QR Code family only checks emptiness,
+// whose capacity depends on other options.
+func ValidateContent(symbology, content string) (err error) {
+	if len(content) == 0 {
+		err = errors.Wrap(ErrInvalidContent, "content is empty")
+		return
+	}
+	if IsQRFamily(symbology) {
+		return
+	}
+	s, ok := lookupSymbology(symbology)
+	if !ok {
+		err = errors.Errorf("unknown symbology %s", symbology)
+		return
+	}
+	if reason := s.Validate(content); reason != nil {
+		err = errors.Wrap(ErrInvalidContent, reason.Error())
+		return
+	}
+	return
+}
+
+// IsQRFamily tells whether symbology is QR Code or its variants
+func IsQRFamily(symbology string) bool {
+	switch symbology {
+	case SymbologyQR, SymbologyMicroQR, SymbologyRMQR:
+		return true
+	default:
+		return false
+	}
+}
+
+// stretchBars turns a single row of linear barcode into bars
+func stretchBars(modules [][]bool) [][]bool {
+	height := len(modules[0]) * 2 / 3
+	if height < minBarHeight {
+		height = minBarHeight
+	}
+	if height > maxBarHeight {
+		height = maxBarHeight
+	}
+	bars := make([][]bool, height)
+	for y := range bars {
+		bars[y] = modules[0]
+	}
+	return bars
+}
+
+func init() {
+	RegisterSymbology(SymbologyCode128, code128Symbology{})
+	RegisterSymbology(SymbologyEAN13, eanSymbology{digits: 12})
+	RegisterSymbology(SymbologyUPCA, eanSymbology{digits: 11})
+	RegisterSymbology(SymbologyDataMatrix, dataMatrixSymbology{})
+	RegisterSymbology(SymbologyPDF417, pdf417Symbology{})
+	RegisterSymbology(SymbologyAztec, aztecSymbology{})
+}
+
+// code128Symbology Code 128
+type code128Symbology struct{}
+
+// maxCode128Length is the limit of go barcode library
+const maxCode128Length = 80
+
+func (code128Symbology) Validate(content string) error {
+	if len(content) > maxCode128Length {
+		return errors.Errorf("code128 content should be no more than %d characters", maxCode128Length)
+	}
+	if !isASCII(content) {
+		return errors.New("code128 content should be ASCII")
+	}
+	return nil
+}
+
+func (code128Symbology) Modules(content, ecc string) ([][]bool, error) {
+	return barcodeModules(code128.Encode(content))
+}
+
+// eanSymbology EAN-13, or UPC-A which is EAN-13 starting with 0
+type eanSymbology struct {
+	// digits count without check digit
+	digits int
+}
+
+func (s eanSymbology) Validate(content string) error {
+	name := SymbologyEAN13
+	if s.digits == 11 {
+		name = SymbologyUPCA
+	}
+	if !isDigits(content) || len(content) < s.digits || len(content) > s.digits+1 {
+		return errors.Errorf("%s content should be %d digits, with an optional check digit", name, s.digits)
+	}
+	if len(content) == s.digits+1 {
+		code := s.ean13(content)
+		if want := eanCheckDigit(code[:12]); code[12] != want {
+			return errors.Errorf("%s check digit should be %c", name, want)
+		}
+	}
+	return nil
+}
+
+func (s eanSymbology) Modules(content, ecc string) ([][]bool, error) {
+	return barcodeModules(ean.Encode(s.ean13(content)))
+}
+
+// ean13 converts content into EAN-13 code
+func (s eanSymbology) ean13(content string) string {
+	return strings.Repeat("0", 12-s.digits) + content
+}
+
+// eanCheckDigit calculates check digit of 12 digits
+func eanCheckDigit(digits string) byte {
+	sum := 0
+	for i := range digits {
+		n := int(digits[i] - '0')
+		if i%2 == 1 {
+			n *= 3
+		}
+		sum += n
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// dataMatrixSymbology Data Matrix ECC 200
+type dataMatrixSymbology struct{}
+
+func (dataMatrixSymbology) Validate(content string) error {
+	// extended ASCII is not encoded correctly by go barcode library
+	if !isASCII(content) {
+		return errors.New("datamatrix content should be ASCII")
+	}
+	return nil
+}
+
+func (dataMatrixSymbology) Modules(content, ecc string) ([][]bool, error) {
+	return barcodeModules(datamatrix.Encode(content))
+}
+
+// pdf417Symbology PDF417
+type pdf417Symbology struct{}
+
+func (pdf417Symbology) Validate(content string) error {
+	return nil
+}
+
+func (pdf417Symbology) Modules(content, ecc string) ([][]bool, error) {
+	// security level has 2^(level+1) error correction codewords
+	level := map[string]byte{ECCLow: 2, ECCMedium: 4, ECCQuartile: 6, ECCHigh: 8}[ecc]
+	if level == 0 {
+		level = 4
+	}
+	modules, err := barcodeModules(pdf417.Encode(content, level))
+	if err != nil {
+		return nil, err
+	}
+
+	// go barcode library draws rows 2 modules high,
+	// while 3 is the recommended minimum.
+	rows := make([][]bool, 0, len(modules)/2*pdf417RowHeight)
+	for y := 0; y < len(modules); y += 2 {
+		for i := 0; i < pdf417RowHeight; i++ {
+			rows = append(rows, modules[y])
+		}
+	}
+	return rows, nil
+}
+
+// pdf417RowHeight height of PDF417 row in modules
+const pdf417RowHeight = 3
+
+// aztecSymbology Aztec Code
+type aztecSymbology struct{}
+
+func (aztecSymbology) Validate(content string) error {
+	return nil
+}
+
+func (aztecSymbology) Modules(content, ecc string) ([][]bool, error) {
+	// minimum percentage of error correction words
+	percent := map[string]int{ECCLow: 10, ECCMedium: 23, ECCQuartile: 36, ECCHigh: 50}[ecc]
+	if percent == 0 {
+		percent = 23
+	}
+	return barcodeModules(aztec.Encode([]byte(content), percent, aztec.DEFAULT_LAYERS))
+}
+
+// barcodeModules reads modules from code whose pixel is a module,
+// encodeErr is taken as content being too long.
+func barcodeModules(code barcode.Barcode, encodeErr error) (modules [][]bool, err error) {
+	if encodeErr != nil {
+		err = errors.Wrap(ErrContentTooLong, encodeErr.Error())
+		return
+	}
+	bounds := code.Bounds()
+	modules = make([][]bool, bounds.Dy())
+	for y := range modules {
+		modules[y] = make([]bool, bounds.Dx())
+		for x := range modules[y] {
+			modules[y][x] = isDark(code, bounds.Min.X+x, bounds.Min.Y+y)
+		}
+	}
+	return
+}
+
+// isDark tells whether pixel at x, y is dark
+func isDark(img image.Image, x, y int) bool {
+	r, g, b, _ := img.At(x, y).RGBA()
+	return r+g+b < 0x8000*3
+}
+
+// isASCII tells whether s consists of ASCII only
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] > 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// isDigits tells whether s consists of digits only
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
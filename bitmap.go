@@ -0,0 +1,43 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qrcode
+
+import (
+	"bytes"
+)
+
+// reborder replaces border of bitmap, which is from modules wide,
+// with a new one to modules wide.
+func reborder(bitmap [][]bool, from, to int) [][]bool {
+	rows, columns := len(bitmap)-2*from, len(bitmap[0])-2*from
+	result := make([][]bool, rows+2*to)
+	for y := range result {
+		result[y] = make([]bool, columns+2*to)
+	}
+	for y := 0; y < rows; y++ {
+		copy(result[y+to][to:to+columns], bitmap[y+from][from:from+columns])
+	}
+	return result
+}
+
+// toText draws bitmap with block characters,
+// every module takes two characters to look square.
+func toText(bitmap [][]bool) string {
+	var buf bytes.Buffer
+	for _, row := range bitmap {
+		for _, dark := range row {
+			if dark {
+				buf.WriteString("██")
+			} else {
+				buf.WriteString("  ")
+			}
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
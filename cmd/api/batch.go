@@ -0,0 +1,228 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// errBatchTooBig request is bigger than MaxDecodeBatchSize
+	errBatchTooBig = errors.New("request is too big")
+	// errFileTooBig file is bigger than MaxDecodeFileSize
+	errFileTooBig = errors.New("file is too big")
+)
+
+// batchFile a file to decode in batch
+type batchFile struct {
+	name string
+	data []byte
+	// err why the file can not be decoded, if any
+	err error
+}
+
+// batchReader fails with errBatchTooBig
+// once more than limit bytes are read.
+type batchReader struct {
+	r io.Reader
+	// left bytes can be read, plus one
+	left int64
+}
+
+// newBatchReader wraps r to read at most limit bytes
+func newBatchReader(r io.Reader, limit int64) *batchReader {
+	return &batchReader{r: r, left: limit + 1}
+}
+
+func (b *batchReader) Read(p []byte) (n int, err error) {
+	if b.left <= 0 {
+		err = errBatchTooBig
+		return
+	}
+	if int64(len(p)) > b.left {
+		p = p[:b.left]
+	}
+	n, err = b.r.Read(p)
+	b.left -= int64(n)
+	if b.left <= 0 {
+		err = errBatchTooBig
+	}
+	return
+}
+
+// tooBig tells whether limit is exceeded
+func (b *batchReader) tooBig() bool {
+	return b.left <= 0
+}
+
+// batchCollector gathers files in a batch
+type batchCollector struct {
+	files []batchFile
+	names map[string]bool
+}
+
+// add takes a file
+func (b *batchCollector) add(file batchFile) (err error) {
+	if b.names == nil {
+		b.names = make(map[string]bool)
+	}
+	if b.names[file.name] {
+		err = fmt.Errorf("file name %s is duplicated", file.name)
+		return
+	}
+	if len(b.files) >= C.MaxBatchSize {
+		err = fmt.Errorf("batch should have no more than %d files", C.MaxBatchSize)
+		return
+	}
+	b.names[file.name] = true
+	b.files = append(b.files, file)
+	return
+}
+
+// addZip takes files in ZIP archive data, whose names start with prefix.
+// Archives in archive are not expanded.
+func (b *batchCollector) addZip(prefix string, data []byte) (err error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		err = fmt.Errorf("%s: %v", strings.TrimSuffix(prefix, "/"), err)
+		return
+	}
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		file := batchFile{name: prefix + entry.Name}
+		if entry.UncompressedSize64 > uint64(maxDecodeFileByte) {
+			file.err = errFileTooBig
+		} else {
+			var content io.ReadCloser
+			content, file.err = entry.Open()
+			if file.err == nil {
+				file.data, file.err = readFile(content, maxDecodeFileByte)
+				content.Close()
+			}
+		}
+		err = b.add(file)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// addMultipart takes every file in multipart form
+func (b *batchCollector) addMultipart(form *multipart.Reader) (err error) {
+	for {
+		var part *multipart.Part
+		part, err = form.NextPart()
+		if err == io.EOF {
+			err = nil
+			return
+		}
+		if err != nil {
+			return
+		}
+		if len(part.FileName()) == 0 {
+			continue
+		}
+		file := batchFile{name: part.FileName()}
+		isZip := strings.EqualFold(path.Ext(file.name), ".zip")
+		limit := maxDecodeFileByte
+		if isZip {
+			limit = maxDecodeBatchByte
+		}
+		file.data, file.err = readFile(part, limit)
+		if file.err != nil && file.err != errFileTooBig {
+			err = file.err
+			return
+		}
+		if isZip && file.err == nil {
+			err = b.addZip(file.name+"/", file.data)
+		} else {
+			err = b.add(file)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readFile reads up to limit bytes, errFileTooBig if there's more
+func readFile(r io.Reader, limit int64) (data []byte, err error) {
+	data, err = ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return
+	}
+	if int64(len(data)) > limit {
+		data, err = nil, errFileTooBig
+	}
+	return
+}
+
+// decodeFiles decodes files by at most workers goroutines until ctx is done,
+// responses are in the same order of files.
+func decodeFiles(ctx context.Context, files []batchFile, request DecodeRequest, workers int) (responses []DecodeResponse, errs []error) {
+	responses = make([]DecodeResponse, len(files))
+	errs = make([]error, len(files))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				responses[index], errs[index] = decodeBatchFile(ctx, files[index], request)
+			}
+		}()
+	}
+	for index := range files {
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+
+	return
+}
+
+// batchDecode decodes a file in batch, which is decodeFile except in tests
+var batchDecode = decodeFile
+
+// decodeBatchFile decodes a file in batch. A panic fails the file only,
+// as workers are out of reach of gin.Recovery.
+func decodeBatchFile(ctx context.Context, file batchFile, request DecodeRequest) (response DecodeResponse, err error) {
+	defer func() {
+		if fatal := recover(); fatal != nil {
+			err = fmt.Errorf("fatal error: %v", fatal)
+			response = DecodeResponse{
+				OK:   false,
+				Desc: "fatal error in decoding",
+			}
+		}
+	}()
+
+	if file.err != nil {
+		response = DecodeResponse{
+			OK:   false,
+			Desc: file.err.Error(),
+		}
+		return
+	}
+	return batchDecode(ctx, file.data, request)
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDecodeBatchPanic(t *testing.T) {
+	router := testRouter()
+	defer func() {
+		batchDecode = decodeFile
+	}()
+	batchDecode = func(ctx context.Context, file []byte, request DecodeRequest) (DecodeResponse, error) {
+		if string(file) == "panic" {
+			panic("decoder is broken")
+		}
+		return DecodeResponse{OK: true}, nil
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, content := range map[string]string{"bad.png": "panic", "good.png": "fine"} {
+		part, err := form.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte(content))
+	}
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/decode/batch", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var response DecodeBatchResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bad := response.Results["bad.png"]; bad.OK || bad.Desc == "" {
+		t.Errorf("bad.png = %+v, want failure", bad)
+	}
+	if good := response.Results["good.png"]; !good.OK {
+		t.Errorf("good.png = %+v, want success", good)
+	}
+}
@@ -32,8 +32,48 @@ type Setting struct {
 	DefaultEncodeWidth int
 	// max image size for QR code encoding
 	MaxEncodeWidth int
-	// max image file size for QR code decode in KiB
+	// max request body size for encoding in KiB, which holds content and params,
+	// uploaded logo is limited by MaxDecodeFileSize on top of it
+	MaxEncodeBodySize int
+	// max image file size for QR code decode in KiB,
+	// which limits uploaded logo as well
 	MaxDecodeFileSize int
+	// max pixels of image, or every page or frame of file, to decode,
+	// checked before decoding, no limit if 0
+	MaxDecodePixels int
+	// directory of logos which can be referred by file name,
+	// no logo is registered if empty
+	LogoDir string
+	// max items in a batch encoding request or files in a batch decoding one,
+	// batch encoding and decoding are disabled if 0
+	MaxBatchSize int
+	// max request size for batch decoding in KiB,
+	// while every file is limited by MaxDecodeFileSize
+	MaxDecodeBatchSize int
+	// how many files are decoded at the same time in a batch,
+	// number of CPUs if 0
+	DecodeWorkers int
+	// time limit in seconds to fetch image for decoding from URL,
+	// decoding from URL is disabled if 0
+	DecodeURLTimeout int
+	// hosts(covering subdomains) and networks in CIDR allowed to fetch image from,
+	// any public host is allowed if empty. Internal addresses are allowed only by networks.
+	DecodeURLAllowlist []string
+	// decoder of symbols, zbar or go(QR Code only) as built in,
+	// the one built in if empty
+	Decoder string
+	// how many worker subprocesses decode in isolation, in which crash of decoder
+	// is contained and dead workers are restarted, decoding is done in process if 0
+	DecodeIsolatedWorkers int
+	// time limit in seconds for an isolated worker to decode a file,
+	// after which the worker is killed and restarted, no limit if 0
+	DecodeIsolatedTimeout int
+	// memory limit in MiB of heap and other data of every isolated worker,
+	// which is supported on Linux only, no limit if 0
+	DecodeIsolatedMemory int
+	// time limit in seconds to process a request, after which encoding or decoding
+	// is given up with 503 Service Unavailable, no limit if 0
+	ProcessTimeout int
 }
 
 // AddPath adds path to config search scope
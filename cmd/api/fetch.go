@@ -0,0 +1,264 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxRedirects max redirects followed when fetching image
+const maxRedirects = 3
+
+var (
+	// errURLNotAllowed URL is not allowed to fetch
+	errURLNotAllowed = errors.New("url is not allowed")
+	// errFetchTooBig fetched file is bigger than MaxDecodeFileSize
+	errFetchTooBig = errors.New("file is too big")
+)
+
+// blockedNetworks are private, loopback, link-local and other
+// special-purpose networks, which are never fetched unless allowed.
+var blockedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+// fetcher downloads images and PDF documents for decoding,
+// keeping away from internal networks.
+type fetcher struct {
+	client *http.Client
+	// hosts allowed to fetch with their subdomains,
+	// any public host is allowed if both hosts and networks are empty.
+	hosts []string
+	// networks allowed to fetch, which may be internal
+	networks []*net.IPNet
+}
+
+// newFetcher makes a fetcher with timeout for the whole fetching.
+//
+// allowlist contains hosts like example.com, which covers its subdomains,
+// and networks like 10.1.0.0/16, which are the only way to reach internal addresses.
+// Any public host is allowed if allowlist is empty.
+func newFetcher(timeout time.Duration, allowlist []string) (f *fetcher, err error) {
+	f = new(fetcher)
+	for _, item := range allowlist {
+		if strings.Contains(item, "/") {
+			var network *net.IPNet
+			_, network, err = net.ParseCIDR(item)
+			if err != nil {
+				err = errors.Wrapf(err, "allowlist %s", item)
+				return
+			}
+			f.networks = append(f.networks, network)
+			continue
+		}
+		f.hosts = append(f.hosts, strings.ToLower(item))
+	}
+
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		// checked again right before connecting, against DNS rebinding
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			return f.checkIP(net.ParseIP(host))
+		},
+	}
+	f.client = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// no proxy, which would connect to anywhere on behalf of us
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          16,
+			IdleConnTimeout:       time.Minute,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return f.checkURL(req.Context(), req.URL)
+		},
+	}
+	return
+}
+
+// fetch downloads file at rawURL, which is no bigger than limit bytes
+func (f *fetcher) fetch(ctx context.Context, rawURL string, limit int64) (file *bytes.Buffer, err error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		err = errors.Wrap(errURLNotAllowed, "url is malformed")
+		return
+	}
+	err = f.checkURL(ctx, target)
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodGet, target.String(), http.NoBody)
+	if err != nil {
+		err = errors.Wrap(errURLNotAllowed, err.Error())
+		return
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", "QR Code API "+Version)
+	req.Header.Set("Accept", "image/*, application/pdf")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		err = errors.Wrap(err, "fetching error")
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("fetching error: status %s", resp.Status)
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(mediaType, "image/") && mediaType != "application/pdf" {
+		err = fmt.Errorf("fetched file should be an image or PDF, got %q", resp.Header.Get("Content-Type"))
+		return
+	}
+	if resp.ContentLength > limit {
+		err = errFetchTooBig
+		return
+	}
+
+	file = new(bytes.Buffer)
+	_, copyErr := io.CopyN(file, resp.Body, limit+1)
+	if copyErr == nil {
+		err = errFetchTooBig
+		return
+	}
+	if copyErr != io.EOF {
+		err = errors.Wrap(copyErr, "fetching error")
+		return
+	}
+	return
+}
+
+// checkURL makes sure target is http(s) to an allowed host
+func (f *fetcher) checkURL(ctx context.Context, target *url.URL) (err error) {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		err = errors.Wrap(errURLNotAllowed, "scheme should be http or https")
+		return
+	}
+	host := strings.ToLower(target.Hostname())
+	if len(host) == 0 {
+		err = errors.Wrap(errURLNotAllowed, "host is empty")
+		return
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = append(ips, ip)
+	} else {
+		var addrs []net.IPAddr
+		addrs, err = net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			err = errors.Wrap(err, "fetching error")
+			return
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+
+	// host passes by name, or by every address of it
+	hostAllowed := (len(f.hosts) == 0 && len(f.networks) == 0) || f.allowHost(host)
+	for _, ip := range ips {
+		err = f.checkIP(ip)
+		if err != nil {
+			return
+		}
+		if !hostAllowed && !f.allowIP(ip) {
+			err = errors.Wrapf(errURLNotAllowed, "host %s is not in allowlist", host)
+			return
+		}
+	}
+	return
+}
+
+// allowHost tells whether host or its parent domain is in allowlist
+func (f *fetcher) allowHost(host string) bool {
+	for _, allowed := range f.hosts {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowIP tells whether ip is in networks of allowlist
+func (f *fetcher) allowIP(ip net.IP) bool {
+	for _, network := range f.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIP rejects ip in blocked networks unless it is in allowlist
+func (f *fetcher) checkIP(ip net.IP) error {
+	if ip == nil {
+		return errors.Wrap(errURLNotAllowed, "address is invalid")
+	}
+	if f.allowIP(ip) {
+		return nil
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return errors.Wrapf(errURLNotAllowed, "address %s is internal", ip)
+		}
+	}
+	return nil
+}
+
+// mustParseCIDRs parses networks, panics on error
+func mustParseCIDRs(cidrs ...string) (networks []*net.IPNet) {
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return
+}
@@ -27,7 +27,19 @@ func main() {
 
 	C.DefaultEncodeWidth = 360
 	C.MaxEncodeWidth = 800
+	C.MaxEncodeBodySize = 1024
 	C.MaxDecodeFileSize = 512
+	C.MaxDecodePixels = 4096 * 4096
+	C.MaxBatchSize = 100
+	C.MaxDecodeBatchSize = 8192
+	C.DecodeWorkers = 4
+	C.DecodeURLTimeout = 10
+	C.DecodeURLAllowlist = []string{}
+	C.Decoder = ""
+	C.DecodeIsolatedWorkers = 0
+	C.DecodeIsolatedTimeout = 10
+	C.DecodeIsolatedMemory = 512
+	C.ProcessTimeout = 30
 
 	content, err := C.Info()
 	if err != nil {
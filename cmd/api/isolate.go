@@ -0,0 +1,309 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/gob"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/nanmu42/qrcode-api"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// errWorkerTimeout worker exceeds time limit of a job
+var errWorkerTimeout = errors.New("decoding timed out")
+
+// workerSetting is the first message a worker receives
+type workerSetting struct {
+	// Decoder name, the preferred one if empty
+	Decoder string
+	// MemoryLimit in MiB of data segment, no limit if 0
+	MemoryLimit int
+}
+
+// workerJob is a file to decode sent to worker
+type workerJob struct {
+	File        []byte
+	Effort      string
+	Symbologies []string
+}
+
+// workerResult is what worker replies for a job
+type workerResult struct {
+	Symbols []qrcode.Symbol
+	// Err message of decoding error, empty if none
+	Err string
+}
+
+// worker is a running worker subprocess
+type worker struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	encoder *gob.Encoder
+	decoder *gob.Decoder
+	// exited is closed when the process is gone
+	exited chan struct{}
+	// exitErr tells how the process is gone, valid after exited is closed
+	exitErr error
+}
+
+// startWorker starts a worker subprocess of the running executable
+func startWorker(setting workerSetting) (w *worker, err error) {
+	executable, err := os.Executable()
+	if err != nil {
+		err = errors.Wrap(err, "os.Executable")
+		return
+	}
+
+	cmd := exec.Command(executable, "-worker")
+	// crash report of decoder goes to stderr
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		err = errors.Wrap(err, "cmd.StdinPipe")
+		return
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		err = errors.Wrap(err, "cmd.StdoutPipe")
+		return
+	}
+	err = cmd.Start()
+	if err != nil {
+		err = errors.Wrap(err, "cmd.Start")
+		return
+	}
+
+	w = &worker{
+		cmd:     cmd,
+		stdin:   stdin,
+		encoder: gob.NewEncoder(stdin),
+		decoder: gob.NewDecoder(stdout),
+		exited:  make(chan struct{}),
+	}
+	go func() {
+		w.exitErr = cmd.Wait()
+		close(w.exited)
+	}()
+
+	err = w.encoder.Encode(setting)
+	if err != nil {
+		w.kill()
+		w, err = nil, errors.Wrap(err, "sending setting")
+		return
+	}
+	return
+}
+
+// alive tells whether the process is still running
+func (w *worker) alive() bool {
+	select {
+	case <-w.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// kill stops the process and waits for it to be gone
+func (w *worker) kill() {
+	w.stdin.Close()
+	w.cmd.Process.Kill()
+	<-w.exited
+}
+
+// decode sends job to worker and waits for result, or until ctx is done,
+// err is about the worker rather than decoding, after which worker is unusable.
+func (w *worker) decode(ctx context.Context, job workerJob, timeout time.Duration) (result workerResult, err error) {
+	var received workerResult
+	replied := make(chan error, 1)
+	go func() {
+		sendErr := w.encoder.Encode(job)
+		if sendErr != nil {
+			replied <- sendErr
+			return
+		}
+		replied <- w.decoder.Decode(&received)
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case err = <-replied:
+		if err != nil {
+			w.kill()
+			if w.exitErr != nil {
+				err = w.exitErr
+			}
+			err = errors.Wrap(err, "decoding worker crashed")
+			return
+		}
+		result = received
+	case <-expired:
+		err = errWorkerTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
+
+// workerPool decodes files in supervised worker subprocesses,
+// so that crash of decoder, e.g. segmentation fault in ZBar, is contained.
+//
+// A worker is restarted if it dies or exceeds time limit of a job.
+type workerPool struct {
+	setting workerSetting
+	timeout time.Duration
+	// idle workers, nil for one failed to restart
+	idle chan *worker
+}
+
+// newWorkerPool starts size workers
+func newWorkerPool(size int, timeout time.Duration, setting workerSetting) (p *workerPool, err error) {
+	p = &workerPool{
+		setting: setting,
+		timeout: timeout,
+		idle:    make(chan *worker, size),
+	}
+	for i := 0; i < size; i++ {
+		var w *worker
+		w, err = startWorker(setting)
+		if err != nil {
+			close(p.idle)
+			for started := range p.idle {
+				started.kill()
+			}
+			p, err = nil, errors.Wrap(err, "startWorker")
+			return
+		}
+		p.idle <- w
+	}
+	return
+}
+
+// Decode decodes file in an idle worker, waiting for one if all are busy,
+// giving up when ctx is done, in which case err is ctx.Err().
+//
+// The busy worker is restarted if ctx is done, as the job can not be stopped otherwise.
+func (p *workerPool) Decode(ctx context.Context, file []byte, effort string, symbologies ...string) (symbols []qrcode.Symbol, err error) {
+	var w *worker
+	select {
+	case w = <-p.idle:
+	case <-ctx.Done():
+		err = ctx.Err()
+		return
+	}
+	defer func() {
+		p.idle <- w
+	}()
+
+	if w == nil || !w.alive() {
+		w, err = p.restart(w)
+		if err != nil {
+			return
+		}
+	}
+
+	result, err := w.decode(ctx, workerJob{
+		File:        file,
+		Effort:      effort,
+		Symbologies: symbologies,
+	}, p.timeout)
+	if err != nil {
+		if err != ctx.Err() {
+			logger.Warn("restarting decoding worker",
+				zap.Error(err),
+			)
+		}
+		// replacement is started for next job, whose error is left to it
+		w, _ = p.restart(w)
+		return
+	}
+	if len(result.Err) > 0 {
+		err = errors.New(result.Err)
+		return
+	}
+	symbols = result.Symbols
+	return
+}
+
+// restart kills w if any and starts a new worker
+func (p *workerPool) restart(w *worker) (started *worker, err error) {
+	if w != nil {
+		w.kill()
+	}
+	started, err = startWorker(p.setting)
+	if err != nil {
+		err = errors.Wrap(err, "restarting worker")
+		return
+	}
+	return
+}
+
+// runWorker serves jobs from stdin until it is closed,
+// replying results to stdout.
+func runWorker() (err error) {
+	var (
+		decoder = gob.NewDecoder(os.Stdin)
+		encoder = gob.NewEncoder(os.Stdout)
+		setting workerSetting
+	)
+	err = decoder.Decode(&setting)
+	if err != nil {
+		err = errors.Wrap(err, "reading setting")
+		return
+	}
+	if len(setting.Decoder) > 0 {
+		err = qrcode.UseDecoder(setting.Decoder)
+		if err != nil {
+			err = errors.Wrap(err, "qrcode.UseDecoder")
+			return
+		}
+	}
+	if setting.MemoryLimit > 0 {
+		err = limitMemory(uint64(setting.MemoryLimit) << 20)
+		if err != nil {
+			err = errors.Wrap(err, "limitMemory")
+			return
+		}
+	}
+
+	for {
+		var job workerJob
+		err = decoder.Decode(&job)
+		if err == io.EOF {
+			err = nil
+			return
+		}
+		if err != nil {
+			err = errors.Wrap(err, "reading job")
+			return
+		}
+
+		var result workerResult
+		result.Symbols, err = qrcode.DecodeFile(job.File, job.Effort, job.Symbologies...)
+		if err != nil {
+			result.Err = err.Error()
+		}
+		err = encoder.Encode(result)
+		if err != nil {
+			err = errors.Wrap(err, "sending result")
+			return
+		}
+	}
+}
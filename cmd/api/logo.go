@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package main
+
+import (
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// logos registered on server side, keyed by file name without extension
+var logos = make(map[string]image.Image)
+
+// LoadLogos registers every image in dir as logo,
+// which can be referred by its file name without extension.
+func LoadLogos(dir string) (err error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		err = errors.Wrap(err, "ioutil.ReadDir")
+		return
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		name := file.Name()
+		img, loadErr := loadImage(filepath.Join(dir, name))
+		if loadErr != nil {
+			err = errors.Wrapf(loadErr, "logo %s", name)
+			return
+		}
+		logos[strings.TrimSuffix(name, filepath.Ext(name))] = img
+	}
+	return
+}
+
+// loadImage decodes image from file
+func loadImage(path string) (img image.Image, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		err = errors.Wrap(err, "os.Open")
+		return
+	}
+	defer f.Close()
+
+	img, _, err = image.Decode(f)
+	if err != nil {
+		err = errors.Wrap(err, "image.Decode")
+		return
+	}
+	return
+}
@@ -10,9 +10,12 @@ package main
 import (
 	"flag"
 	"fmt"
+	"runtime"
+	"time"
 
 	"github.com/pkg/errors"
 
+	"github.com/nanmu42/qrcode-api"
 	"github.com/nanmu42/qrcode-api/cmd/common"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -22,6 +25,7 @@ import (
 var (
 	logger     *zap.Logger
 	configFile = flag.String("config", "config.toml", "config.toml file location for rly")
+	asWorker   = flag.Bool("worker", false, "run as isolated decoding worker, which is started by API")
 	// Version build params
 	Version string
 	// BuildDate build params
@@ -31,6 +35,18 @@ var (
 // maxDecodeFileByte is MaxDecodeFileSize's byte version
 var maxDecodeFileByte int64
 
+// maxDecodeBatchByte is MaxDecodeBatchSize's byte version
+var maxDecodeBatchByte int64
+
+// maxEncodeBodyByte is MaxEncodeBodySize's byte version
+var maxEncodeBodyByte int64
+
+// urlFetcher fetches image to decode from URL, nil if disabled
+var urlFetcher *fetcher
+
+// decodePool decodes files in isolated workers, nil if decoding is done in process
+var decodePool *workerPool
+
 func init() {
 	w := common.NewBufferedLumberjack(&lumberjack.Logger{
 		Filename:   "logs/qrcode-api.log",
@@ -60,6 +76,14 @@ func main() {
 
 	flag.Parse()
 
+	if *asWorker {
+		err = runWorker()
+		if err != nil {
+			err = errors.Wrap(err, "runWorker")
+		}
+		return
+	}
+
 	fmt.Printf(`QRCode API(%s)
 built on %s
 
@@ -71,7 +95,52 @@ built on %s
 		return
 	}
 
+	if len(C.Decoder) > 0 {
+		err = qrcode.UseDecoder(C.Decoder)
+		if err != nil {
+			err = errors.Wrap(err, "qrcode.UseDecoder")
+			return
+		}
+	}
+
 	maxDecodeFileByte = int64(C.MaxDecodeFileSize << 10)
+	maxDecodeBatchByte = int64(C.MaxDecodeBatchSize << 10)
+	maxEncodeBodyByte = int64(C.MaxEncodeBodySize << 10)
+	if C.DecodeWorkers <= 0 {
+		C.DecodeWorkers = runtime.NumCPU()
+	}
+	if C.DecodeURLTimeout > 0 {
+		urlFetcher, err = newFetcher(time.Duration(C.DecodeURLTimeout)*time.Second, C.DecodeURLAllowlist)
+		if err != nil {
+			err = errors.Wrap(err, "newFetcher")
+			return
+		}
+	}
+
+	if C.DecodeIsolatedWorkers > 0 {
+		if C.DecodeIsolatedMemory > 0 && !memoryLimitSupported {
+			logger.Warn("DecodeIsolatedMemory is ignored as memory limit is not supported on this platform",
+				zap.String("GOOS", runtime.GOOS),
+			)
+			C.DecodeIsolatedMemory = 0
+		}
+		decodePool, err = newWorkerPool(C.DecodeIsolatedWorkers, time.Duration(C.DecodeIsolatedTimeout)*time.Second, workerSetting{
+			Decoder:     C.Decoder,
+			MemoryLimit: C.DecodeIsolatedMemory,
+		})
+		if err != nil {
+			err = errors.Wrap(err, "newWorkerPool")
+			return
+		}
+	}
+
+	if len(C.LogoDir) > 0 {
+		err = LoadLogos(C.LogoDir)
+		if err != nil {
+			err = errors.Wrap(err, "LoadLogos")
+			return
+		}
+	}
 
 	router := setupRouter()
 	startAPI(router, C.Port)
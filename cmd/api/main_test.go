@@ -0,0 +1,32 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// testRouter sets up API with settings for tests
+func testRouter() *gin.Engine {
+	logger = zap.NewNop()
+	C = Setting{
+		DefaultEncodeWidth: 256,
+		MaxEncodeWidth:     1024,
+		MaxEncodeBodySize:  64,
+		MaxDecodeFileSize:  1024,
+		MaxDecodePixels:    1 << 20,
+		MaxBatchSize:       8,
+		MaxDecodeBatchSize: 4096,
+		DecodeWorkers:      2,
+	}
+	maxDecodeFileByte = int64(C.MaxDecodeFileSize << 10)
+	maxDecodeBatchByte = int64(C.MaxDecodeBatchSize << 10)
+	maxEncodeBodyByte = int64(C.MaxEncodeBodySize << 10)
+	return setupRouter()
+}
@@ -0,0 +1,27 @@
+//go:build linux
+// +build linux
+
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package main
+
+import (
+	"syscall"
+)
+
+// memoryLimitSupported tells whether limitMemory works on this platform
+const memoryLimitSupported = true
+
+// limitMemory limits data segment of the process in bytes,
+// which covers heap mapped by Go runtime since Linux 4.7
+func limitMemory(limit uint64) error {
+	return syscall.Setrlimit(syscall.RLIMIT_DATA, &syscall.Rlimit{
+		Cur: limit,
+		Max: limit,
+	})
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+// +build !linux
+
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package main
+
+import (
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+// memoryLimitSupported tells whether limitMemory works on this platform
+const memoryLimitSupported = false
+
+// limitMemory is not supported on this platform
+func limitMemory(limit uint64) error {
+	return errors.New("memory limit is not supported on " + runtime.GOOS)
+}
@@ -8,12 +8,18 @@
 package main
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"image"
 	"io"
+	"io/ioutil"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"sync/atomic"
@@ -52,10 +58,25 @@ func setupRouter() (router *gin.Engine) {
 	}
 	// log requests
 	router.Use(RequestLogger(logger))
+	if C.ProcessTimeout > 0 {
+		router.Use(ProcessTimeout(time.Duration(C.ProcessTimeout) * time.Second))
+	}
 
 	// setup routes
 	router.GET("/encode", EncodeQRCode)
+	router.POST("/encode", EncodeQRCode)
+	router.GET("/encode/:symbology", EncodeQRCode)
+	// POST /encode/batch shares the route with symbologies
+	router.POST("/encode/:symbology", func(c *gin.Context) {
+		if c.Param("symbology") == "batch" {
+			EncodeBatch(c)
+			return
+		}
+		EncodeQRCode(c)
+	})
+	router.GET("/decode", DecodeQRCode)
 	router.POST("/decode", DecodeQRCode)
+	router.POST("/decode/batch", DecodeBatch)
 	return
 }
 
@@ -136,39 +157,395 @@ func RequestLogger(l *zap.Logger) gin.HandlerFunc {
 	}
 }
 
-// EncodeQRCode controller to encode QR code per request
+// ProcessTimeout sets deadline of timeout on context of every request,
+// which is honored by encoding and decoding.
+func ProcessTimeout(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// EncodeQRCode controller to encode QR code per request,
+// or other barcode if symbology is in path.
 func EncodeQRCode(c *gin.Context) {
 	var err error
 
-	encoder, err := ParseEncodeRequest(c.Request.URL.Query())
+	values := c.Request.URL.Query()
+	var uploadedLogo image.Image
+	if c.Request.Method == http.MethodPost {
+		switch c.ContentType() {
+		case "application/octet-stream":
+			values, err = parseEncodeBody(c)
+		case "application/json":
+			values, err = ParseEncodeJSON(http.MaxBytesReader(c.Writer, c.Request.Body, maxEncodeBodyByte))
+		default:
+			values, uploadedLogo, err = parseEncodeForm(c)
+		}
+		if err != nil {
+			c.Error(err)
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if symbology := c.Param("symbology"); len(symbology) > 0 {
+		values.Set(symbologyField, symbology)
+	}
+
+	encoder, err := ParseEncodeRequest(values, c.GetHeader("Accept"))
 	if err != nil {
 		c.Error(err)
 		c.String(http.StatusBadRequest, err.Error())
 		return
 	}
+	if uploadedLogo != nil {
+		err = SetLogo(&encoder, uploadedLogo)
+		if err == nil {
+			err = capacityCheck(encoder, len(values.Get(appendField)) > 0)
+		}
+		if err != nil {
+			c.Error(err)
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if appendMode := values.Get(appendField); len(appendMode) > 0 {
+		encodeAppend(c, &encoder, appendMode)
+		return
+	}
 
 	var buf bytes.Buffer
-	gotType, err := encoder.Encode(&buf)
+	gotType, err := encoder.EncodeContext(c.Request.Context(), &buf)
+	if isEncodeRequestError(err) {
+		c.Error(err)
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if isGivenUp(err) {
+		err = errors.Wrap(err, "encoding is given up")
+		c.Error(err)
+		c.String(http.StatusServiceUnavailable, err.Error())
+		return
+	}
 	if err != nil {
 		c.Error(err)
 		c.String(http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	switch gotType {
-	case qrcode.TypePNG:
-		c.DataFromReader(http.StatusOK, int64(buf.Len()), "image/png", &buf, map[string]string{})
-	case qrcode.TypeString:
-		c.DataFromReader(http.StatusOK, int64(buf.Len()), "text/plain; charset=utf-8", &buf, map[string]string{})
+	// type may be picked per Accept header
+	c.DataFromReader(http.StatusOK, int64(buf.Len()), contentTypes[gotType], &buf, map[string]string{
+		"Vary": "Accept",
+	})
+
+	return
+}
+
+// encodeAppend responds QR Codes linked by Structured Append
+// as a ZIP archive, a tiled sheet or a JSON array per appendMode.
+func encodeAppend(c *gin.Context, encoder *qrcode.QREncoder, appendMode string) {
+	var (
+		err     error
+		gotType string
+		parts   [][]byte
+		buf     bytes.Buffer
+	)
+
+	if appendMode == appendSheet {
+		gotType, err = encoder.EncodeSheetContext(c.Request.Context(), &buf)
+	} else {
+		parts, gotType, err = encoder.EncodeAppendContext(c.Request.Context())
+	}
+	if isEncodeRequestError(err) {
+		c.Error(err)
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if isGivenUp(err) {
+		err = errors.Wrap(err, "encoding is given up")
+		c.Error(err)
+		c.String(http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	switch appendMode {
+	case appendSheet:
+		c.DataFromReader(http.StatusOK, int64(buf.Len()), contentTypes[gotType], &buf, map[string]string{
+			"Vary": "Accept",
+		})
+	case appendJSON:
+		response := EncodeAppendResponse{
+			OK:   true,
+			Type: gotType,
+		}
+		for _, part := range parts {
+			response.Content = append(response.Content, base64.StdEncoding.EncodeToString(part))
+		}
+		c.JSON(http.StatusOK, response)
+	default:
+		archive := zip.NewWriter(&buf)
+		for index, part := range parts {
+			var file io.Writer
+			file, err = archive.Create(fmt.Sprintf("qrcode-%02d.%s", index+1, fileExtension(gotType)))
+			if err == nil {
+				_, err = file.Write(part)
+			}
+			if err != nil {
+				err = errors.Wrap(err, "zip archiving error")
+				c.Error(err)
+				c.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		err = archive.Close()
+		if err != nil {
+			err = errors.Wrap(err, "zip archiving error")
+			c.Error(err)
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.DataFromReader(http.StatusOK, int64(buf.Len()), "application/zip", &buf, map[string]string{
+			"Content-Disposition": `attachment; filename="qrcode.zip"`,
+			"Vary":                "Accept",
+		})
 	}
 
 	return
 }
 
-// DecodeQRCode controller to decode QR Code
+// EncodeBatch controller to encode a JSON array of requests,
+// responding a ZIP archive or NDJSON per format.
+//
+// Every request is checked before responding,
+// while encoding errors are reported per item.
+func EncodeBatch(c *gin.Context) {
+	var err error
+
+	if C.MaxBatchSize <= 0 {
+		err = errors.New("batch encoding is disabled")
+		c.Error(err)
+		c.String(http.StatusForbidden, err.Error())
+		return
+	}
+	format := c.DefaultQuery(formatField, batchZip)
+	if format != batchZip && format != batchNDJSON {
+		err = errors.New("format should be one of zip or ndjson")
+		c.Error(err)
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	items, err := ParseEncodeBatch(http.MaxBytesReader(c.Writer, c.Request.Body, maxEncodeBodyByte))
+	if err == nil && len(items) == 0 {
+		err = errors.New("batch is empty")
+	}
+	if err == nil && len(items) > C.MaxBatchSize {
+		err = fmt.Errorf("batch should have no more than %d items", C.MaxBatchSize)
+	}
+	if err != nil {
+		c.Error(err)
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	encoders := make([]qrcode.QREncoder, len(items))
+	for index, values := range items {
+		if len(values.Get(appendField)) > 0 {
+			err = fmt.Errorf("item %d: append is not supported in batch", index)
+		} else {
+			encoders[index], err = ParseEncodeRequest(values, "")
+			err = errors.Wrapf(err, "item %d", index)
+		}
+		if err != nil {
+			c.Error(err)
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if format == batchNDJSON {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+		lines := json.NewEncoder(c.Writer)
+		for index := range encoders {
+			result := EncodeBatchResult{Index: index}
+			var buf bytes.Buffer
+			result.Type, err = encoders[index].EncodeContext(c.Request.Context(), &buf)
+			if isGivenUp(err) {
+				// the rest are given up as well
+				c.Error(errors.Wrap(err, "encoding is given up"))
+				return
+			}
+			if err != nil {
+				c.Error(errors.Wrapf(err, "item %d", index))
+				result.Desc = err.Error()
+			} else {
+				result.OK = true
+				result.Content = base64.StdEncoding.EncodeToString(buf.Bytes())
+			}
+			err = lines.Encode(result)
+			if err != nil {
+				c.Error(errors.Wrap(err, "response writing error"))
+				return
+			}
+			c.Writer.Flush()
+		}
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="qrcode.zip"`)
+	c.Status(http.StatusOK)
+	archive := zip.NewWriter(c.Writer)
+	for index := range encoders {
+		var (
+			buf     bytes.Buffer
+			gotType string
+			name    string
+			file    io.Writer
+		)
+		gotType, err = encoders[index].EncodeContext(c.Request.Context(), &buf)
+		if isGivenUp(err) {
+			// archive is left unfinished
+			c.Error(errors.Wrap(err, "encoding is given up"))
+			return
+		}
+		if err != nil {
+			c.Error(errors.Wrapf(err, "item %d", index))
+			name = fmt.Sprintf("qrcode-%04d.error.txt", index)
+			buf.Reset()
+			buf.WriteString(err.Error())
+		} else {
+			name = fmt.Sprintf("qrcode-%04d.%s", index, fileExtension(gotType))
+		}
+		file, err = archive.Create(name)
+		if err == nil {
+			_, err = buf.WriteTo(file)
+		}
+		if err == nil {
+			err = archive.Flush()
+		}
+		if err != nil {
+			c.Error(errors.Wrap(err, "zip archiving error"))
+			return
+		}
+	}
+	err = archive.Close()
+	if err != nil {
+		c.Error(errors.Wrap(err, "zip archiving error"))
+		return
+	}
+
+	return
+}
+
+// isGivenUp tells whether err is caused by request context being done,
+// for ProcessTimeout or client leaving.
+func isGivenUp(err error) bool {
+	switch errors.Cause(err) {
+	case context.DeadlineExceeded, context.Canceled:
+		return true
+	default:
+		return false
+	}
+}
+
+// isEncodeRequestError tells whether encoding error
+// is caused by params in request.
+func isEncodeRequestError(err error) bool {
+	switch errors.Cause(err) {
+	case qrcode.ErrSizeTooSmall, qrcode.ErrContentTooLong, qrcode.ErrInvalidContent, qrcode.ErrUnknownType:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseEncodeBody takes request body as content,
+// and the other params from query.
+func parseEncodeBody(c *gin.Context) (values url.Values, err error) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxEncodeBodyByte))
+	if err != nil {
+		err = errors.Wrap(err, "body read error")
+		return
+	}
+
+	values = c.Request.URL.Query()
+	values.Set(contentField, string(body))
+	return
+}
+
+// parseEncodeForm reads encoding params and optional logo
+// from multipart form, or params only from urlencoded form.
+func parseEncodeForm(c *gin.Context) (values url.Values, logo image.Image, err error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxEncodeBodyByte+maxDecodeFileByte)
+	err = c.Request.ParseMultipartForm(maxDecodeFileByte)
+	if err == http.ErrNotMultipart {
+		// form is parsed anyway
+		values, err = c.Request.Form, nil
+		return
+	}
+	if err != nil {
+		err = errors.Wrap(err, "multipart form parsing error")
+		return
+	}
+	values = c.Request.Form
+
+	file, header, err := c.Request.FormFile(logoField)
+	if err == http.ErrMissingFile {
+		err = nil
+		return
+	}
+	if err != nil {
+		err = errors.Wrap(err, "logo reading error")
+		return
+	}
+	defer file.Close()
+	if header.Size > maxDecodeFileByte {
+		err = errors.Wrap(errFileTooBig, "logo")
+		return
+	}
+
+	logo, _, err = image.Decode(file)
+	if err != nil {
+		err = errors.Wrap(err, "logo decoding error")
+		return
+	}
+	return
+}
+
+// DecodeQRCode controller to decode QR Code,
+// or other symbologies in image from body or url.
 func DecodeQRCode(c *gin.Context) {
 	var err error
 
+	request, err := ParseDecodeRequest(c.Request.URL.Query())
+	if err == nil && c.Request.Method == http.MethodGet && len(c.Query(urlField)) == 0 {
+		err = errors.New("url is empty")
+	}
+	if err != nil {
+		c.Error(err)
+		c.Request.Body.Close()
+		c.JSON(http.StatusBadRequest, DecodeResponse{
+			OK:      false,
+			Desc:    err.Error(),
+			Content: nil,
+		})
+		return
+	}
+	if rawURL := c.Query(urlField); len(rawURL) > 0 {
+		c.Request.Body.Close()
+		decodeURL(c, rawURL, request)
+		return
+	}
+
 	// avoid too big image
 	if c.Request.ContentLength >= maxDecodeFileByte {
 		err = errors.New("request is too big(content-length)")
@@ -205,12 +582,21 @@ func DecodeQRCode(c *gin.Context) {
 		return
 	}
 
-	// decode image
-	input, _, err := image.Decode(&buf)
+	response, err := decodeFile(c.Request.Context(), buf.Bytes(), request)
 	if err != nil {
-		err = errors.Wrap(err, "file decoding error")
 		c.Error(err)
-		c.JSON(http.StatusOK, DecodeResponse{
+	}
+	c.JSON(decodeStatus(err), response)
+
+	return
+}
+
+// decodeURL fetches file from rawURL and decodes it
+func decodeURL(c *gin.Context, rawURL string, request DecodeRequest) {
+	if urlFetcher == nil {
+		err := errors.New("decoding from url is disabled")
+		c.Error(err)
+		c.JSON(http.StatusForbidden, DecodeResponse{
 			OK:      false,
 			Desc:    err.Error(),
 			Content: nil,
@@ -218,23 +604,186 @@ func DecodeQRCode(c *gin.Context) {
 		return
 	}
 
-	contents, err := qrcode.DecodeQRCode(input)
+	file, err := urlFetcher.fetch(c.Request.Context(), rawURL, maxDecodeFileByte)
 	if err != nil {
-		err = errors.Wrap(err, "QR Code scanning error")
+		status := http.StatusOK
+		switch errors.Cause(err) {
+		case errURLNotAllowed:
+			status = http.StatusBadRequest
+		case errFetchTooBig:
+			status = http.StatusRequestEntityTooLarge
+		}
 		c.Error(err)
-		c.JSON(http.StatusOK, DecodeResponse{
+		c.JSON(status, DecodeResponse{
+			OK:      false,
+			Desc:    err.Error(),
+			Content: nil,
+		})
+		return
+	}
+
+	response, err := decodeFile(c.Request.Context(), file.Bytes(), request)
+	if err != nil {
+		c.Error(err)
+	}
+	c.JSON(decodeStatus(err), response)
+}
+
+// decodeFile decodes symbols in every page or frame of file,
+// in isolated worker if enabled, until ctx is done.
+// err is reported in response as well.
+//
+// file is refused without decoding if it has more pixels than MaxDecodePixels.
+func decodeFile(ctx context.Context, file []byte, request DecodeRequest) (response DecodeResponse, err error) {
+	if C.MaxDecodePixels > 0 {
+		err = qrcode.CheckFilePixels(file, C.MaxDecodePixels)
+		if errors.Cause(err) == qrcode.ErrTooManyPixels {
+			err = errors.Wrapf(err, "pixels are limited to %d", C.MaxDecodePixels)
+		}
+		if err != nil {
+			response = DecodeResponse{
+				OK:      false,
+				Desc:    err.Error(),
+				Content: nil,
+			}
+			return
+		}
+	}
+
+	var symbols []qrcode.Symbol
+	if decodePool != nil {
+		symbols, err = decodePool.Decode(ctx, file, request.Effort, request.Symbologies...)
+	} else {
+		symbols, err = qrcode.DecodeFileContext(ctx, file, request.Effort, request.Symbologies...)
+	}
+	if isGivenUp(err) {
+		err = errors.Wrap(err, "decoding is given up")
+	}
+	if err != nil {
+		response = DecodeResponse{
 			OK:      false,
 			Desc:    err.Error(),
 			Content: nil,
+		}
+		return
+	}
+
+	response = NewDecodeResponse(symbols)
+	return
+}
+
+// decodeStatus is HTTP status responding err of decodeFile
+func decodeStatus(err error) int {
+	switch {
+	case errors.Cause(err) == qrcode.ErrTooManyPixels:
+		return http.StatusRequestEntityTooLarge
+	case isGivenUp(err):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusOK
+	}
+}
+
+// DecodeBatch controller to decode files in multipart form,
+// or in ZIP archive as request body, responding results keyed by file name.
+func DecodeBatch(c *gin.Context) {
+	var err error
+
+	if C.MaxBatchSize <= 0 {
+		err = errors.New("batch decoding is disabled")
+		c.Error(err)
+		c.Request.Body.Close()
+		c.JSON(http.StatusForbidden, DecodeBatchResponse{
+			OK:   false,
+			Desc: err.Error(),
+		})
+		return
+	}
+	request, err := ParseDecodeRequest(c.Request.URL.Query())
+	if err != nil {
+		c.Error(err)
+		c.Request.Body.Close()
+		c.JSON(http.StatusBadRequest, DecodeBatchResponse{
+			OK:   false,
+			Desc: err.Error(),
+		})
+		return
+	}
+	if c.Request.ContentLength > maxDecodeBatchByte {
+		err = errors.New("request is too big(content-length)")
+		c.Error(err)
+		c.Request.Body.Close()
+		c.JSON(http.StatusRequestEntityTooLarge, DecodeBatchResponse{
+			OK:   false,
+			Desc: "request is too big",
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, DecodeResponse{
+	var (
+		body      = newBatchReader(c.Request.Body, maxDecodeBatchByte)
+		collector batchCollector
+	)
+	c.Request.Body = ioutil.NopCloser(body)
+	switch c.ContentType() {
+	case "application/zip":
+		var data []byte
+		data, err = ioutil.ReadAll(body)
+		if err == nil {
+			err = collector.addZip("", data)
+		}
+	case "multipart/form-data":
+		var form *multipart.Reader
+		form, err = c.Request.MultipartReader()
+		if err == nil {
+			err = collector.addMultipart(form)
+		}
+	default:
+		err = errors.New("request should be multipart/form-data or application/zip")
+	}
+	if body.tooBig() {
+		err = errors.New("request is too big(actually read)")
+		c.Error(err)
+		c.Request.Body.Close()
+		c.JSON(http.StatusRequestEntityTooLarge, DecodeBatchResponse{
+			OK:   false,
+			Desc: "request is too big",
+		})
+		return
+	}
+	if err == nil && len(collector.files) == 0 {
+		err = errors.New("there is no file in request")
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, DecodeBatchResponse{
+			OK:   false,
+			Desc: err.Error(),
+		})
+		return
+	}
+
+	responses, errs := decodeFiles(c.Request.Context(), collector.files, request, C.DecodeWorkers)
+	result := DecodeBatchResponse{
 		OK:      true,
-		Desc:    "",
-		Content: contents,
-	})
+		Results: make(map[string]DecodeResponse, len(responses)),
+	}
+	status := http.StatusOK
+	err = c.Request.Context().Err()
+	if err != nil {
+		err = errors.Wrap(err, "decoding is given up")
+		c.Error(err)
+		// results so far are kept
+		result.OK, result.Desc = false, err.Error()
+		status = http.StatusServiceUnavailable
+	}
+	for index, file := range collector.files {
+		if errs[index] != nil {
+			c.Error(errors.Wrap(errs[index], file.name))
+		}
+		result.Results[file.name] = responses[index]
+	}
+	c.JSON(status, result)
 
 	return
 }
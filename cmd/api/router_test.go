@@ -0,0 +1,125 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// largePNG is a blank PNG of width x height
+func largePNG(width, height int) []byte {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height)))
+	return buf.Bytes()
+}
+
+// manyFramesGIF is a blank GIF animation of frames in width x height
+func manyFramesGIF(frames, width, height int) []byte {
+	var animation gif.GIF
+	for i := 0; i < frames; i++ {
+		animation.Image = append(animation.Image, image.NewPaletted(image.Rect(0, 0, width, height), palette.Plan9))
+		animation.Delay = append(animation.Delay, 10)
+	}
+	var buf bytes.Buffer
+	gif.EncodeAll(&buf, &animation)
+	return buf.Bytes()
+}
+
+// largeImagePDF is a PDF whose page shows an image declared as width x height
+func largeImagePDF(width, height int) []byte {
+	return []byte(fmt.Sprintf(`%%PDF-1.4
+1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj
+2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj
+3 0 obj << /Type /Page /Parent 2 0 R /MediaBox [0 0 72 72] /Contents 4 0 R /Resources << /XObject << /I 5 0 R >> >> >> endobj
+4 0 obj << /Length 26 >>
+stream
+q 72 0 0 72 0 0 cm /I Do Q
+endstream
+endobj
+5 0 obj << /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8 /Length 1 >>
+stream
+x
+endstream
+endobj
+trailer << /Root 1 0 R >>
+%%%%EOF
+`, width, height))
+}
+
+func TestDecodeTooManyPixels(t *testing.T) {
+	router := testRouter()
+
+	tests := []struct {
+		name string
+		file []byte
+	}{
+		{"large PNG", largePNG(2000, 1000)},
+		{"many frames of GIF", manyFramesGIF(30, 200, 200)},
+		{"large image in PDF", largeImagePDF(4000, 4000)},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/decode", bytes.NewReader(tt.file))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, http.StatusRequestEntityTooLarge, w.Body)
+			continue
+		}
+		var response DecodeResponse
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if response.OK || !strings.Contains(response.Desc, "too many pixels") {
+			t.Errorf("%s: response = %+v", tt.name, response)
+		}
+	}
+}
+
+func TestEncodeBodyLimit(t *testing.T) {
+	router := testRouter()
+
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"raw content", "/encode?ecc=L", "application/octet-stream", strings.Repeat("1", 1000), http.StatusOK},
+		{"raw content too big", "/encode", "application/octet-stream", strings.Repeat("1", int(maxEncodeBodyByte)+1), http.StatusBadRequest},
+		{"JSON too big", "/encode", "application/json", `{"content": "` + strings.Repeat("1", int(maxEncodeBodyByte)) + `"}`, http.StatusBadRequest},
+		{"batch too big", "/encode/batch", "application/json", `[{"content": "` + strings.Repeat("1", int(maxEncodeBodyByte)) + `"}]`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %.100s", tt.name, w.Code, tt.want, w.Body)
+			continue
+		}
+		if tt.want != http.StatusOK && !strings.Contains(w.Body.String(), "too large") {
+			t.Errorf("%s: body = %.100s, want it too large", tt.name, w.Body)
+		}
+	}
+}
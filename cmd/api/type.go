@@ -1,48 +1,762 @@
 package main
 
 import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/nanmu42/qrcode-api"
 )
 
 // query filed name
 const (
-	contentField = "content"
-	typeField    = "type"
-	sizeField    = "size"
+	contentField     = "content"
+	typeField        = "type"
+	sizeField        = "size"
+	eccField         = "ecc"
+	unitField        = "unit"
+	dpiField         = "dpi"
+	fgField          = "fg"
+	bgField          = "bg"
+	logoField        = "logo"
+	borderField      = "border"
+	scaleField       = "scale"
+	strictField      = "strict"
+	qualityField     = "quality"
+	appendField      = "append"
+	versionField     = "version"
+	maskField        = "mask"
+	symbologyField   = "symbology"
+	encodingField    = "encoding"
+	eciField         = "eci"
+	symbologiesField = "symbologies"
+	formatField      = "format"
+	urlField         = "url"
+	effortField      = "effort"
 )
 
-// ParseEncodeRequest convert encoding request to struct
-func ParseEncodeRequest(values url.Values) (encoder qrcode.QREncoder, err error) {
+// response formats of batch encoding
+const (
+	// batchZip every code in its own file of a ZIP archive
+	batchZip = "zip"
+	// batchNDJSON one EncodeBatchResult per line
+	batchNDJSON = "ndjson"
+)
+
+// ways content is encoded in request
+const (
+	// encodingBase64 standard or URL-safe base64, padding is optional
+	encodingBase64 = "base64"
+	// encodingHex hexadecimal
+	encodingHex = "hex"
+)
+
+// ways to return QR Codes linked by Structured Append
+const (
+	// appendZip every QR Code in its own file of a ZIP archive
+	appendZip = "zip"
+	// appendSheet QR Codes tiled into one image
+	appendSheet = "sheet"
+	// appendJSON QR Codes in base64 in a JSON array
+	appendJSON = "json"
+)
+
+// contentTypes maps encoding type to MIME type
+var contentTypes = map[string]string{
+	qrcode.TypePNG:    "image/png",
+	qrcode.TypeJPEG:   "image/jpeg",
+	qrcode.TypeGIF:    "image/gif",
+	qrcode.TypeBMP:    "image/bmp",
+	qrcode.TypeWebP:   "image/webp",
+	qrcode.TypeSVG:    "image/svg+xml",
+	qrcode.TypePDF:    "application/pdf",
+	qrcode.TypeEPS:    "application/postscript",
+	qrcode.TypeString: "text/plain; charset=utf-8",
+}
+
+const (
+	// maxDPI upper limit of dpi
+	maxDPI = 2400
+	// maxBorder upper limit of border in modules
+	maxBorder = 16
+	// maxScale upper limit of pixels per module
+	maxScale = 16
+)
+
+// ParseEncodeRequest convert encoding request to struct,
+// accept is the Accept header for choosing type when it is absent.
+func ParseEncodeRequest(values url.Values, accept string) (encoder qrcode.QREncoder, err error) {
 	// required param
-	encoder.Content = values.Get(contentField)
+	encoder.Content, err = parseContent(values.Get(contentField), values.Get(encodingField))
+	if err != nil {
+		return
+	}
 	if len(encoder.Content) == 0 {
 		err = errors.New("content is empty")
 		return
 	}
-	// max capacity of QR Code is 2953 bytes
-	if len(encoder.Content) > 2048 {
-		err = errors.New("content should be no more than 2KB")
+
+	// optional param
+	encoder.ECC = strings.ToUpper(values.Get(eccField))
+	if len(encoder.ECC) == 0 {
+		encoder.ECC = qrcode.DefaultECC
+	}
+	if !qrcode.IsValidECC(encoder.ECC) {
+		err = errors.New("ecc should be one of L, M, Q or H")
+		return
+	}
+	encoder.Symbology = strings.ToLower(values.Get(symbologyField))
+	if len(encoder.Symbology) == 0 {
+		encoder.Symbology = qrcode.DefaultSymbology
+	}
+	if !qrcode.IsValidSymbology(encoder.Symbology) {
+		err = fmt.Errorf("symbology should be one of %s", strings.Join(qrcode.Symbologies(), ", "))
 		return
 	}
+	err = qrcode.ValidateContent(encoder.Symbology, encoder.Content)
+	if err != nil {
+		return
+	}
+	switch encoder.Symbology {
+	case qrcode.SymbologyMicroQR:
+		if encoder.ECC == qrcode.ECCHigh {
+			err = errors.New("ecc should be one of L, M or Q for microqr")
+			return
+		}
+	case qrcode.SymbologyRMQR:
+		if encoder.ECC != qrcode.ECCMedium && encoder.ECC != qrcode.ECCHigh {
+			err = errors.New("ecc should be one of M or H for rmqr")
+			return
+		}
+	}
+	if rawECI := values.Get(eciField); len(rawECI) > 0 {
+		if encoder.Symbology != qrcode.SymbologyQR && encoder.Symbology != qrcode.SymbologyRMQR {
+			err = fmt.Errorf("eci is not supported by symbology %s", encoder.Symbology)
+			return
+		}
+		encoder.ECI, err = parseECI(rawECI)
+		if err != nil {
+			return
+		}
+	}
+	encoder.Type = values.Get(typeField)
+	if len(encoder.Type) == 0 {
+		encoder.Type = negotiateType(accept)
+	}
+	if !qrcode.IsValidType(encoder.Type) {
+		err = errors.New("type should be one of png, jpeg, gif, bmp, webp, svg, pdf, eps or string")
+		return
+	}
+	if name := values.Get(logoField); len(name) > 0 {
+		logo, ok := logos[name]
+		if !ok {
+			err = fmt.Errorf("logo %s is not registered", name)
+			return
+		}
+		err = SetLogo(&encoder, logo)
+		if err != nil {
+			return
+		}
+	}
+	appendMode := values.Get(appendField)
+	if len(appendMode) > 0 && encoder.Symbology != qrcode.SymbologyQR {
+		err = fmt.Errorf("append is not supported by symbology %s", encoder.Symbology)
+		return
+	}
+	switch appendMode {
+	case "", appendZip, appendJSON:
+	case appendSheet:
+		if !qrcode.IsRasterType(encoder.Type) {
+			err = fmt.Errorf("sheet is not supported by type %s", encoder.Type)
+			return
+		}
+	default:
+		err = errors.New("append should be one of zip, sheet or json")
+		return
+	}
+	if encoder.Symbology == qrcode.SymbologyQR {
+		err = capacityCheck(encoder, len(appendMode) > 0)
+		if err != nil {
+			return
+		}
+	}
 
-	// optional param
-	size, badNum := strconv.ParseInt(values.Get(sizeField), 10, 64)
-	if badNum != nil || size <= 0 || size > int64(C.MaxEncodeWidth) {
-		encoder.Size = C.DefaultEncodeWidth
+	if !qrcode.IsQRFamily(encoder.Symbology) && (len(values.Get(versionField)) > 0 || len(values.Get(maskField)) > 0) {
+		err = fmt.Errorf("version and mask are not supported by symbology %s", encoder.Symbology)
+		return
+	}
+	if rawVersion := values.Get(versionField); len(rawVersion) > 0 {
+		encoder.Version, err = parseVersion(encoder.Symbology, rawVersion)
+		if err != nil {
+			return
+		}
+	}
+	if encoder.Symbology == qrcode.SymbologyMicroQR {
+		err = microECCCheck(&encoder, len(values.Get(eccField)) == 0)
+		if err != nil {
+			return
+		}
+	}
+	if rawMask := values.Get(maskField); len(rawMask) > 0 {
+		maxMask := qrcode.MaxMask
+		switch encoder.Symbology {
+		case qrcode.SymbologyMicroQR:
+			maxMask = qrcode.MaxMicroMask
+		case qrcode.SymbologyRMQR:
+			err = errors.New("mask is not supported by rmqr")
+			return
+		}
+		mask, badNum := strconv.ParseInt(rawMask, 10, 64)
+		if badNum != nil || mask < 0 || mask > int64(maxMask) {
+			err = fmt.Errorf("mask should be an integer between 0 and %d", maxMask)
+			return
+		}
+		pattern := int(mask)
+		encoder.Mask = &pattern
+	}
+
+	if rawScale := values.Get(scaleField); len(rawScale) > 0 {
+		scale, badNum := strconv.ParseInt(rawScale, 10, 64)
+		if badNum != nil || scale <= 0 || scale > maxScale {
+			err = fmt.Errorf("scale should be an integer between 1 and %d", maxScale)
+			return
+		}
+		encoder.Scale = int(scale)
+	}
+	encoder.Strict, _ = strconv.ParseBool(values.Get(strictField))
+	if rawQuality := values.Get(qualityField); len(rawQuality) > 0 {
+		quality, badNum := strconv.ParseInt(rawQuality, 10, 64)
+		if badNum != nil || quality <= 0 || quality > 100 {
+			err = errors.New("quality should be an integer between 1 and 100")
+			return
+		}
+		encoder.Quality = int(quality)
+	}
+	if rawBorder := values.Get(borderField); len(rawBorder) > 0 {
+		border, badNum := strconv.ParseInt(rawBorder, 10, 64)
+		if badNum != nil || border < 0 || border > maxBorder {
+			err = fmt.Errorf("border should be an integer between 0 and %d", maxBorder)
+			return
+		}
+		encoder.Border = int(border)
+		if border == 0 {
+			encoder.Border = qrcode.NoBorder
+		}
+	}
+	if fg := values.Get(fgField); len(fg) > 0 {
+		encoder.Foreground, err = parseHexColor(fg)
+		if err != nil {
+			err = fmt.Errorf("fg: %v", err)
+			return
+		}
+	}
+	if bg := values.Get(bgField); len(bg) > 0 {
+		encoder.Background, err = parseHexColor(bg)
+		if err != nil {
+			err = fmt.Errorf("bg: %v", err)
+			return
+		}
+	}
+	err = contrastCheck(encoder.Foreground, encoder.Background)
+	if err != nil {
+		return
+	}
+
+	dpi, badNum := strconv.ParseInt(values.Get(dpiField), 10, 64)
+	if badNum != nil || dpi <= 0 || dpi > maxDPI {
+		encoder.DPI = qrcode.DefaultDPI
 	} else {
-		encoder.Size = int(size)
+		encoder.DPI = int(dpi)
+	}
+
+	switch unit := values.Get(unitField); unit {
+	case qrcode.UnitMillimeter, qrcode.UnitInch:
+		physical, badNum := strconv.ParseFloat(values.Get(sizeField), 64)
+		if badNum != nil || physical <= 0 {
+			err = errors.New("size should be a positive number when unit is specified")
+			return
+		}
+		encoder.PhysicalSize = physical
+		encoder.Unit = unit
+		if !isVectorType(encoder.Type) && encoder.PixelSize() > C.MaxEncodeWidth {
+			err = fmt.Errorf("size should be no more than %d pixels at %d dpi", C.MaxEncodeWidth, encoder.DPI)
+			return
+		}
+	case "", "px":
+		size, badNum := strconv.ParseInt(values.Get(sizeField), 10, 64)
+		if encoder.Strict && encoder.Scale <= 0 && (badNum != nil || size <= 0 || size > int64(C.MaxEncodeWidth)) {
+			err = fmt.Errorf("size should be an integer between 1 and %d in strict mode", C.MaxEncodeWidth)
+			return
+		}
+		if badNum != nil || size <= 0 || size > int64(C.MaxEncodeWidth) {
+			encoder.Size = C.DefaultEncodeWidth
+		} else {
+			encoder.Size = int(size)
+		}
+	default:
+		err = errors.New("unit should be one of px, mm or in")
+		return
+	}
+	return
+}
+
+// parseContent decodes raw content per encoding,
+// raw is taken as it is if encoding is empty.
+func parseContent(raw, encoding string) (content string, err error) {
+	var data []byte
+	switch encoding {
+	case "":
+		content = raw
+		return
+	case encodingBase64:
+		raw = strings.TrimRight(raw, "=")
+		data, err = base64.RawStdEncoding.DecodeString(raw)
+		if err != nil {
+			data, err = base64.RawURLEncoding.DecodeString(raw)
+		}
+	case encodingHex:
+		data, err = hex.DecodeString(raw)
+	default:
+		err = errors.New("encoding should be one of base64 or hex")
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("content is not valid %s", encoding)
+		return
+	}
+	content = string(data)
+	return
+}
+
+// parseECI parses ECI assignment number,
+// or name of character set like UTF-8 or Shift_JIS.
+func parseECI(raw string) (eci int, err error) {
+	if value, ok := qrcode.ECI(raw); ok {
+		eci = value
+		return
+	}
+	value, badNum := strconv.ParseInt(raw, 10, 64)
+	if badNum != nil || value < 0 || value > qrcode.MaxECI {
+		err = fmt.Errorf("eci should be a character set like UTF-8 or Shift_JIS, or an integer between 0 and %d", qrcode.MaxECI)
+		return
+	}
+	eci = int(value)
+	return
+}
+
+// microECCCheck checks ecc against Micro QR version,
+// M1 only detects errors and Q is only supported by M4.
+// ecc defaults to L for M1 if it is absent.
+func microECCCheck(encoder *qrcode.QREncoder, eccAbsent bool) (err error) {
+	switch encoder.Version {
+	case 1:
+		if eccAbsent {
+			encoder.ECC = qrcode.ECCLow
+		}
+		if encoder.ECC != qrcode.ECCLow {
+			err = errors.New("ecc should be L for microqr version M1, which only detects errors")
+			return
+		}
+	case 2, 3:
+		if encoder.ECC == qrcode.ECCQuartile {
+			err = errors.New("ecc Q is only supported by microqr version M4")
+			return
+		}
+	}
+	return
+}
+
+// parseVersion parses symbol version of symbology,
+// which is an integer, or like M2 for Micro QR and R7x43 for rMQR.
+func parseVersion(symbology, raw string) (version int, err error) {
+	var badNum error
+	switch symbology {
+	case qrcode.SymbologyMicroQR:
+		version, badNum = strconv.Atoi(strings.TrimPrefix(strings.ToUpper(raw), "M"))
+		if badNum != nil || version < qrcode.MinVersion || version > qrcode.MaxMicroVersion {
+			err = fmt.Errorf("version should be one of M1 to M%d for microqr", qrcode.MaxMicroVersion)
+			return
+		}
+	case qrcode.SymbologyRMQR:
+		var ok bool
+		if version, ok = qrcode.RMQRVersion(raw); ok {
+			return
+		}
+		version, badNum = strconv.Atoi(raw)
+		if badNum != nil || version < qrcode.MinVersion || version > qrcode.MaxRMQRVersion {
+			err = fmt.Errorf("version should be like R7x43, or an integer between %d and %d for rmqr", qrcode.MinVersion, qrcode.MaxRMQRVersion)
+			return
+		}
+	default:
+		version, badNum = strconv.Atoi(raw)
+		if badNum != nil || version < qrcode.MinVersion || version > qrcode.MaxVersion {
+			err = fmt.Errorf("version should be an integer between %d and %d", qrcode.MinVersion, qrcode.MaxVersion)
+			return
+		}
+	}
+	return
+}
+
+// negotiateType picks the most preferred type in Accept header,
+// qrcode.DefaultType if none is supported.
+//
+// Another type is picked only if it is preferred strictly over qrcode.DefaultType,
+// whose preference may come from image/* or */*.
+func negotiateType(accept string) (fileType string) {
+	fileType = qrcode.DefaultType
+	var (
+		bestQ float64
+		// preference of qrcode.DefaultType by its name, image/* and */*, -1 if not given
+		defaultQ = [3]float64{-1, -1, -1}
+	)
+	for _, item := range strings.Split(accept, ",") {
+		parts := strings.Split(item, ";")
+		mime := strings.ToLower(strings.TrimSpace(parts[0]))
+		q := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, _ = strconv.ParseFloat(param[2:], 64)
+			}
+		}
+		switch mime {
+		case "image/*":
+			defaultQ[1] = q
+			continue
+		case "*/*":
+			defaultQ[2] = q
+			continue
+		}
+		for candidate, contentType := range contentTypes {
+			if !strings.HasPrefix(contentType, mime) || (len(contentType) != len(mime) && contentType[len(mime)] != ';') {
+				continue
+			}
+			if candidate == qrcode.DefaultType {
+				defaultQ[0] = q
+			} else if q > bestQ {
+				fileType, bestQ = candidate, q
+			}
+			break
+		}
+	}
+	// browsers accept image/webp along with */* for any page,
+	// so WebP has to rank above qrcode.DefaultType by its name
+	if fileType == qrcode.TypeWebP && defaultQ[0] < 0 && (defaultQ[1] >= 0 || defaultQ[2] >= 0) {
+		fileType = qrcode.DefaultType
+		return
+	}
+	// the most specific one counts
+	for _, q := range defaultQ {
+		if q >= 0 {
+			if bestQ <= q {
+				fileType = qrcode.DefaultType
+			}
+			break
+		}
+	}
+	return
+}
+
+// SetLogo puts logo onto encoder, which raises error correction level to H.
+func SetLogo(encoder *qrcode.QREncoder, logo image.Image) (err error) {
+	if len(encoder.Symbology) > 0 && encoder.Symbology != qrcode.SymbologyQR {
+		err = fmt.Errorf("logo is not supported by symbology %s", encoder.Symbology)
+		return
+	}
+	switch encoder.Type {
+	case qrcode.TypeString, qrcode.TypePDF, qrcode.TypeEPS:
+		err = fmt.Errorf("logo is not supported by type %s", encoder.Type)
+		return
+	}
+	encoder.Logo = logo
+	encoder.ECC = qrcode.ECCHigh
+	return
+}
+
+// capacityCheck makes sure content fits in QR Code,
+// or QR Codes linked by Structured Append when appended.
+func capacityCheck(encoder qrcode.QREncoder, appended bool) (err error) {
+	// capacity of QR Code shrinks as error correction level goes up
+	capacity := qrcode.Capacity(encoder.ECC)
+	if appended {
+		capacity = qrcode.AppendCapacity(encoder.ECC)
+	}
+	if len(encoder.Content) > capacity {
+		err = fmt.Errorf("content should be no more than %d bytes for ecc %s", capacity, encoder.ECC)
+		return
+	}
+	return
+}
+
+// parseHexColor parses color in hex like #RGB, RGB, RRGGBB or RRGGBBAA,
+// transparent is accepted as well.
+func parseHexColor(raw string) (c color.Color, err error) {
+	if raw == "transparent" {
+		c = color.Transparent
+		return
+	}
+	raw = strings.TrimPrefix(raw, "#")
+	if len(raw) == 3 {
+		raw = string([]byte{raw[0], raw[0], raw[1], raw[1], raw[2], raw[2]})
+	}
+	if len(raw) == 6 {
+		raw += "ff"
+	}
+	if len(raw) != 8 {
+		err = errors.New("color should be hex in form of RGB, RRGGBB or RRGGBBAA")
+		return
+	}
+	rgba, err := hex.DecodeString(raw)
+	if err != nil {
+		err = errors.New("color should be hex in form of RGB, RRGGBB or RRGGBBAA")
+		return
+	}
+	c = color.NRGBA{R: rgba[0], G: rgba[1], B: rgba[2], A: rgba[3]}
+	return
+}
+
+// contrastCheck rejects color pairs which are hard to scan,
+// nil means default color.
+//
+// Transparent background is not checked since the backdrop is unknown.
+func contrastCheck(fg, bg color.Color) (err error) {
+	if fg == nil {
+		fg = qrcode.DefaultForeground
+	}
+	if bg == nil {
+		bg = qrcode.DefaultBackground
+	}
+	if qrcode.IsTransparent(bg) {
+		return
+	}
+	if ratio := qrcode.ContrastRatio(fg, bg); ratio < qrcode.MinContrastRatio {
+		err = fmt.Errorf("contrast ratio between fg and bg is %.2f, should be at least %.1f", ratio, qrcode.MinContrastRatio)
+		return
+	}
+	return
+}
+
+// isVectorType tells whether output type is vector graphics,
+// which is not bound by pixel size limit.
+func isVectorType(fileType string) bool {
+	switch fileType {
+	case qrcode.TypeSVG, qrcode.TypePDF, qrcode.TypeEPS:
+		return true
+	default:
+		return false
+	}
+}
+
+// ParseEncodeJSON reads encoding params from a JSON object,
+// whose fields are named after query params.
+func ParseEncodeJSON(r io.Reader) (values url.Values, err error) {
+	var object map[string]interface{}
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	err = decoder.Decode(&object)
+	if err != nil {
+		err = fmt.Errorf("JSON decoding error: %v", err)
+		return
+	}
+
+	values, err = jsonValues(object)
+	return
+}
+
+// ParseEncodeBatch reads a JSON array of encoding params,
+// which are like the ones of ParseEncodeJSON.
+func ParseEncodeBatch(r io.Reader) (items []url.Values, err error) {
+	var objects []map[string]interface{}
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	err = decoder.Decode(&objects)
+	if err != nil {
+		err = fmt.Errorf("JSON decoding error: %v", err)
+		return
+	}
+
+	for index, object := range objects {
+		var values url.Values
+		values, err = jsonValues(object)
+		if err != nil {
+			err = fmt.Errorf("item %d: %v", index, err)
+			return
+		}
+		items = append(items, values)
+	}
+	return
+}
+
+// jsonValues converts fields of JSON object into params
+func jsonValues(object map[string]interface{}) (values url.Values, err error) {
+	values = make(url.Values, len(object))
+	for key, value := range object {
+		switch v := value.(type) {
+		case nil:
+		case string:
+			values.Set(key, v)
+		case json.Number:
+			values.Set(key, v.String())
+		case bool:
+			values.Set(key, strconv.FormatBool(v))
+		default:
+			err = fmt.Errorf("%s should be a string, number or boolean", key)
+			return
+		}
+	}
+	return
+}
+
+// EncodeBatchResult result of an item in batch encoding
+type EncodeBatchResult struct {
+	// Index position of item in request, starts from 0
+	Index int    `json:"index"`
+	OK    bool   `json:"ok"`
+	Desc  string `json:"desc"`
+	// Type file type of Content
+	Type string `json:"type"`
+	// Content encoded file in base64
+	Content string `json:"content"`
+}
+
+// EncodeAppendResponse holds QR Codes linked by Structured Append
+type EncodeAppendResponse struct {
+	OK   bool   `json:"ok"`
+	Desc string `json:"desc"`
+	// Type file type of every QR Code
+	Type string `json:"type"`
+	// Content QR Codes in base64, in order of sequence
+	Content []string `json:"content"`
+}
+
+// fileExtension returns file name extension for encoding type
+func fileExtension(fileType string) string {
+	if fileType == qrcode.TypeString {
+		return "txt"
+	}
+	return fileType
+}
+
+// DecodeRequest options of decoding
+type DecodeRequest struct {
+	// Symbologies to decode, empty for every symbology
+	Symbologies []string
+	// Effort how hard to try on hard-to-read images
+	Effort string
+}
+
+// ParseDecodeRequest reads symbologies to decode and effort
+func ParseDecodeRequest(values url.Values) (request DecodeRequest, err error) {
+	request.Effort = strings.ToLower(values.Get(effortField))
+	if len(request.Effort) == 0 {
+		request.Effort = qrcode.DefaultEffort
+	}
+	if !qrcode.IsValidEffort(request.Effort) {
+		err = errors.New("effort should be one of low, medium or high")
+		return
+	}
+
+	raw := values.Get(symbologiesField)
+	if len(raw) == 0 {
+		return
+	}
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !qrcode.IsDecodableSymbology(name) {
+			err = fmt.Errorf("symbologies should be some of %s", strings.Join(qrcode.DecodeSymbologies(), ", "))
+			return
+		}
+		request.Symbologies = append(request.Symbologies, name)
 	}
-	encoder.Type = values.Get(typeField)
 	return
 }
 
 // DecodeResponse content holder for response
 type DecodeResponse struct {
-	OK      bool     `json:"ok"`
-	Desc    string   `json:"desc"`
+	OK   bool   `json:"ok"`
+	Desc string `json:"desc"`
+	// Content data of every symbol found
 	Content []string `json:"content"`
+	// Symbols detail of every symbol found, in the same order of Content
+	Symbols []DecodedSymbol `json:"symbols"`
+}
+
+// DecodeBatchResponse results of batch decoding
+type DecodeBatchResponse struct {
+	OK   bool   `json:"ok"`
+	Desc string `json:"desc"`
+	// Results keyed by file name
+	Results map[string]DecodeResponse `json:"results"`
+}
+
+// DecodedSymbol a symbol found in file
+type DecodedSymbol struct {
+	Symbology string `json:"symbology"`
+	Content   string `json:"content"`
+	// Raw bytes of content before converted to text, in base64
+	Raw []byte `json:"raw"`
+	// Quality relative quality, larger is better
+	Quality int `json:"quality"`
+	// Polygon location in image, in pixel
+	Polygon []Point `json:"polygon"`
+	// Corners top-left, bottom-left, bottom-right and top-right corner
+	Corners []Point `json:"corners"`
+	// Bounds bounding box
+	Bounds Box `json:"bounds"`
+	// Frame index of PDF page, TIFF page or GIF frame, counting from 0
+	Frame int `json:"frame"`
+}
+
+// Point a point in image
+type Point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// Box a rectangle in image
+type Box struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// NewDecodeResponse makes a successful response from decoded symbols
+func NewDecodeResponse(symbols []qrcode.Symbol) (response DecodeResponse) {
+	response.OK = true
+	for _, symbol := range symbols {
+		item := DecodedSymbol{
+			Symbology: symbol.Symbology,
+			Content:   symbol.Data,
+			Raw:       symbol.Raw,
+			Quality:   symbol.Quality,
+			Frame:     symbol.Frame,
+		}
+		for _, point := range symbol.Polygon {
+			item.Polygon = append(item.Polygon, Point{X: point.X, Y: point.Y})
+		}
+		if len(symbol.Polygon) > 0 {
+			for _, point := range symbol.Corners() {
+				item.Corners = append(item.Corners, Point{X: point.X, Y: point.Y})
+			}
+			bounds := symbol.Bounds()
+			item.Bounds = Box{
+				X:      bounds.Min.X,
+				Y:      bounds.Min.Y,
+				Width:  bounds.Dx(),
+				Height: bounds.Dy(),
+			}
+		}
+		response.Content = append(response.Content, symbol.Data)
+		response.Symbols = append(response.Symbols, item)
+	}
+	return
 }
@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseEncodeRequestMicroECC(t *testing.T) {
+	testRouter()
+
+	tests := []struct {
+		query string
+		// error containing it is wanted, or none if empty
+		wantErr string
+	}{
+		{"symbology=microqr&version=1", ""},
+		{"symbology=microqr&version=M1&ecc=L", ""},
+		{"symbology=microqr&version=1&ecc=M", "ecc should be L for microqr version M1"},
+		{"symbology=microqr&version=M2", ""},
+		{"symbology=microqr&version=M3&ecc=Q", "ecc Q is only supported by microqr version M4"},
+		{"symbology=microqr&version=M4&ecc=Q", ""},
+		{"symbology=microqr&ecc=Q", ""},
+	}
+	for _, tt := range tests {
+		values, err := url.ParseQuery("content=12345&" + tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoder, err := ParseEncodeRequest(values, "")
+		if err == nil {
+			_, err = encoder.Encode(ioutil.Discard)
+		}
+		switch {
+		case len(tt.wantErr) == 0 && err != nil:
+			t.Errorf("%s: %v", tt.query, err)
+		case len(tt.wantErr) > 0 && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: error = %v, want %q", tt.query, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNegotiateType(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", "png"},
+		{"image/webp", "webp"},
+		{"image/svg+xml, image/png;q=0.5", "svg"},
+		{"image/svg+xml;q=0.5, image/png", "png"},
+		{"application/pdf;q=0.9, */*;q=0.8", "pdf"},
+		{"image/webp, image/png;q=0.8, */*", "webp"},
+		{"image/webp, image/*;q=0.8", "png"},
+		{"image/svg+xml, image/*;q=0.8", "svg"},
+		{"image/svg+xml;q=0.8, image/*", "png"},
+		// Chrome asks for an image
+		{"image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8", "png"},
+		// Chrome asks for a page
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7", "png"},
+	}
+	for _, tt := range tests {
+		if got := negotiateType(tt.accept); got != tt.want {
+			t.Errorf("negotiateType(%q) = %s, want %s", tt.accept, got, tt.want)
+		}
+	}
+}
@@ -32,8 +32,13 @@ type Setting struct {
 	QRCodeSize int
 	// max image file size for QR code decode in bytes
 	MaxDecodeFileSize int
+	// max pixels of image for QR code decode, checked before decoding, no limit if 0
+	MaxDecodePixels int
 	// max encode content length in bytes
 	MaxEncodeContentLength int
+	// decoder of QR Code, zbar or go as built in,
+	// the one built in if empty
+	Decoder string
 }
 
 // AddPath adds path to config search scope
@@ -28,7 +28,9 @@ func main() {
 	C.EncodeAPIEndpoint = "https://qrcode-api.nanmu.me/encode?"
 	C.QRCodeSize = 500
 	C.MaxDecodeFileSize = 800 << 10 // 800 KiB
+	C.MaxDecodePixels = 4096 * 4096
 	C.MaxEncodeContentLength = 2048
+	C.Decoder = ""
 
 	content, err := C.Info()
 	if err != nil {
@@ -111,6 +111,14 @@ built on %s
 		return
 	}
 
+	if len(C.Decoder) > 0 {
+		err = qrcode.UseDecoder(C.Decoder)
+		if err != nil {
+			err = errors.Wrap(err, "qrcode.UseDecoder")
+			return
+		}
+	}
+
 	botCtx, err := bearychat.NewRTMContext(C.RTMToken)
 	if err != nil {
 		err = errors.Wrap(err, "bearychat.NewRTMContext")
@@ -187,7 +195,9 @@ built on %s
 						msgOpt.Text = "仅支持jpeg, png或gif格式的图片哟。:kissing_heart: "
 					default:
 						scanningResult, badScan := DownloadImageAndScan(file.ImageURL)
-						if badScan != nil {
+						if errors.Cause(badScan) == qrcode.ErrTooManyPixels {
+							msgOpt.Text = "图片尺寸过大，抱歉。:ghost: "
+						} else if badScan != nil {
 							msgOpt.Text = "哦噢，出错了。:dizzy_face: " + badScan.Error()
 						} else if len(scanningResult) == 0 {
 							msgOpt.Text = "没能在您的图片中找到二维码/条形码，或者它们损坏了，小码会继续努力哒！ :kissing_heart: "
@@ -291,6 +301,14 @@ func DownloadImageAndScan(imageClue string) (result []string, err error) {
 		return
 	}
 
+	if C.MaxDecodePixels > 0 {
+		err = qrcode.CheckFilePixels(buf.Bytes(), C.MaxDecodePixels)
+		if err != nil {
+			err = errors.Wrap(err, "qrcode.CheckFilePixels")
+			return
+		}
+	}
+
 	img, _, err := image.Decode(&buf)
 	if err != nil {
 		err = errors.Wrap(err, "image.Decode")
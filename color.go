@@ -0,0 +1,58 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qrcode
+
+import (
+	"image/color"
+	"math"
+)
+
+// MinContrastRatio is the lowest contrast ratio between foreground
+// and background color considered scannable.
+const MinContrastRatio = 3.0
+
+var (
+	// DefaultForeground default color of dark modules
+	DefaultForeground color.Color = color.Black
+	// DefaultBackground default color of light modules
+	DefaultBackground color.Color = color.White
+)
+
+// ContrastRatio calculates contrast ratio between color a and b,
+// ranging from 1(no contrast) to 21(black and white),
+// as is defined in WCAG 2.0.
+//
+// Translucent colors are blended onto white first.
+func ContrastRatio(a, b color.Color) float64 {
+	la, lb := luminance(a), luminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// IsTransparent tells whether c is fully transparent
+func IsTransparent(c color.Color) bool {
+	_, _, _, a := c.RGBA()
+	return a == 0
+}
+
+// luminance calculates relative luminance of c
+func luminance(c color.Color) float64 {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	alpha := float64(n.A) / 0xff
+	linear := func(v uint8) float64 {
+		// blend onto white
+		s := (float64(v)/0xff)*alpha + (1 - alpha)
+		if s <= 0.03928 {
+			return s / 12.92
+		}
+		return math.Pow((s+0.055)/1.055, 2.4)
+	}
+	return 0.2126*linear(n.R) + 0.7152*linear(n.G) + 0.0722*linear(n.B)
+}
@@ -8,19 +8,209 @@
 package qrcode
 
 import (
-	"errors"
 	"fmt"
 	"image"
+	"sort"
+	"strings"
 
-	"github.com/PeterCxy/gozbar"
+	"github.com/pkg/errors"
 )
 
+// symbologies only for decoding
+const (
+	SymbologyEAN2       = "ean2"
+	SymbologyEAN5       = "ean5"
+	SymbologyEAN8       = "ean8"
+	SymbologyUPCE       = "upce"
+	SymbologyISBN10     = "isbn10"
+	SymbologyISBN13     = "isbn13"
+	SymbologyI25        = "i25"
+	SymbologyDataBar    = "databar"
+	SymbologyDataBarExp = "databarexp"
+	SymbologyCodabar    = "codabar"
+	SymbologyCode39     = "code39"
+	SymbologyCode93     = "code93"
+)
+
+// names of decoders
+const (
+	// DecoderZBar decodes every symbology of ZBar, requires cgo and libzbar
+	DecoderZBar = "zbar"
+	// DecoderGo decodes QR Code in pure Go, built with tag purego or without cgo
+	DecoderGo = "go"
+)
+
+// Decoder finds and decodes symbols in image
+type Decoder interface {
+	// Symbologies lists symbologies which can be decoded, sorted
+	Symbologies() []string
+	// Decode decodes symbols of symbologies from img,
+	// every symbology of Symbologies is enabled if symbologies is empty.
+	//
+	// symbols and err are both nil when nothing found.
+	Decode(img image.Image, symbologies ...string) (symbols []Symbol, err error)
+}
+
+var (
+	// decoders built in, registered by name
+	decoders = make(map[string]Decoder)
+	// decoder in use by package level functions
+	decoder Decoder
+)
+
+// ErrNoDecoder no decoder is built in
+var ErrNoDecoder = errors.New("no decoder is built in, build with cgo or tag purego")
+
+// registerDecoder makes decoder available by name,
+// ZBar is used by default if built in.
+func registerDecoder(name string, d Decoder) {
+	decoders[name] = d
+	if decoder == nil || name == DecoderZBar {
+		decoder = d
+	}
+}
+
+// Decoders lists names of decoders built in, sorted
+func Decoders() (names []string) {
+	for name := range decoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// GetDecoder returns decoder built in by name
+func GetDecoder(name string) (d Decoder, err error) {
+	d, ok := decoders[name]
+	if !ok {
+		err = fmt.Errorf("decoder %s is not built in, available: %s", name, strings.Join(Decoders(), ", "))
+	}
+	return
+}
+
+// UseDecoder picks decoder by name for package level functions,
+// which should be called before decoding starts.
+func UseDecoder(name string) (err error) {
+	d, err := GetDecoder(name)
+	if err != nil {
+		return
+	}
+	decoder = d
+	return
+}
+
+// ErrUnknownSymbology symbology can not be decoded
+var ErrUnknownSymbology = errors.New("unknown symbology")
+
+// Symbol a symbol found in image
+type Symbol struct {
+	// Symbology e.g. SymbologyQR, SymbologyEAN13
+	Symbology string
+	// Data content of symbol, in which text is converted
+	// to UTF-8 per ECI, or by guess of decoder.
+	Data string
+	// Raw bytes of content before conversion of text.
+	// It is the same as Data except for QR Code,
+	// and requires ZBar 0.23 or newer for QR Code, too.
+	Raw []byte
+	// Quality relative quality of symbol reported by decoder, larger is better
+	Quality int
+	// Polygon location of symbol in image, points are in img's coordinate.
+	// It outlines the symbol for 2D symbologies,
+	// and is made of points on scan lines for linear ones.
+	Polygon []image.Point
+	// Frame index of page or frame where symbol is found,
+	// counting from 0, for files of multiple pages or frames.
+	Frame int
+}
+
+// Bounds returns the smallest rectangle containing the symbol,
+// which is empty if location is unknown.
+func (s Symbol) Bounds() (bounds image.Rectangle) {
+	for index, point := range s.Polygon {
+		pixel := image.Rectangle{Min: point, Max: point.Add(image.Pt(1, 1))}
+		if index == 0 {
+			bounds = pixel
+			continue
+		}
+		bounds = bounds.Union(pixel)
+	}
+	return
+}
+
+// Corners returns four corners of the symbol
+// in order of top-left, bottom-left, bottom-right and top-right.
+//
+// Corners are rotated along with the symbol for 2D symbologies,
+// in which case top-left is the one with finder pattern for QR Code.
+// Otherwise they are corners of Bounds.
+// corners are all zero if location is unknown.
+func (s Symbol) Corners() (corners [4]image.Point) {
+	if len(s.Polygon) == 0 {
+		return
+	}
+	if (s.Symbology != SymbologyQR && s.Symbology != SymbologyPDF417) || len(s.Polygon) != len(corners) {
+		// corners are pixels inside
+		min, max := s.Bounds().Min, s.Bounds().Max.Sub(image.Pt(1, 1))
+		corners = [4]image.Point{
+			min,
+			{X: min.X, Y: max.Y},
+			max,
+			{X: max.X, Y: min.Y},
+		}
+		return
+	}
+
+	copy(corners[:], s.Polygon)
+	return
+}
+
+// DecodeSymbologies lists symbologies which can be decoded by decoder in use, sorted
+//
+// ISBN10 and ISBN13 are read as EAN-13 unless asked for explicitly,
+// and some symbologies require newer ZBar.
+func DecodeSymbologies() (names []string) {
+	if decoder == nil {
+		return
+	}
+	return decoder.Symbologies()
+}
+
+// IsDecodableSymbology tells whether symbology can be decoded by decoder in use
+func IsDecodableSymbology(symbology string) bool {
+	for _, name := range DecodeSymbologies() {
+		if name == symbology {
+			return true
+		}
+	}
+	return false
+}
+
 // DecodeQRCode decodes QR Code content from image
 //
 // content contains multiple string if there are more than one QR Code
 // got decoded.
+// QR Codes linked by Structured Append in img are joined into one content.
 // content and err are both nil when no QR Code found.
 func DecodeQRCode(img image.Image) (content []string, err error) {
+	symbols, err := DecodeSymbols(img, SymbologyQR)
+	if err != nil {
+		return
+	}
+
+	for _, symbol := range symbols {
+		content = append(content, symbol.Data)
+	}
+
+	return
+}
+
+// DecodeSymbols decodes symbols of symbologies from image by decoder in use,
+// every symbology that is supported by the decoder is enabled
+// if symbologies is empty.
+//
+// symbols and err are both nil when nothing found.
+func DecodeSymbols(img image.Image, symbologies ...string) (symbols []Symbol, err error) {
 	defer func() {
 		if fatal := recover(); fatal != nil {
 			if err == nil {
@@ -31,25 +221,9 @@ func DecodeQRCode(img image.Image) (content []string, err error) {
 		}
 	}()
 
-	input := zbar.FromImage(img)
-
-	s := zbar.NewScanner()
-	s.SetConfig(zbar.QRCODE, zbar.CFG_ENABLE, 1)
-	result := s.Scan(input)
-
-	if result < 0 {
-		err = errors.New("error occurred when scanning")
-		return
-	}
-
-	if result == 0 {
-		// no result
+	if decoder == nil {
+		err = ErrNoDecoder
 		return
 	}
-
-	input.First().Each(func(item string) {
-		content = append(content, item)
-	})
-
-	return
+	return decoder.Decode(img, symbologies...)
 }
@@ -0,0 +1,169 @@
+//go:build purego || !cgo
+// +build purego !cgo
+
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qrcode
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"unicode/utf8"
+
+	"github.com/nanmu42/qrcode-api/internal/qr"
+	"github.com/pkg/errors"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// eciEncodings maps ECI assignment number to encoding of character set,
+// content of UTF-8, ASCII, binary and unknown ones is left as is.
+var eciEncodings = map[int]encoding.Encoding{
+	1:  charmap.ISO8859_1,
+	2:  charmap.CodePage437,
+	3:  charmap.ISO8859_1,
+	4:  charmap.ISO8859_2,
+	5:  charmap.ISO8859_3,
+	6:  charmap.ISO8859_4,
+	7:  charmap.ISO8859_5,
+	8:  charmap.ISO8859_6,
+	9:  charmap.ISO8859_7,
+	10: charmap.ISO8859_8,
+	11: charmap.ISO8859_9,
+	12: charmap.ISO8859_10,
+	// superset of ISO-8859-11
+	13: charmap.Windows874,
+	15: charmap.ISO8859_13,
+	16: charmap.ISO8859_14,
+	17: charmap.ISO8859_15,
+	18: charmap.ISO8859_16,
+	20: japanese.ShiftJIS,
+	21: charmap.Windows1250,
+	22: charmap.Windows1251,
+	23: charmap.Windows1252,
+	24: charmap.Windows1256,
+	25: unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+	28: traditionalchinese.Big5,
+	29: simplifiedchinese.GB18030,
+	30: korean.EUCKR,
+}
+
+func init() {
+	registerDecoder(DecoderGo, goDecoder{})
+}
+
+// goDecoder decodes QR Code in pure Go
+type goDecoder struct{}
+
+// Symbologies lists QR Code only
+func (goDecoder) Symbologies() []string {
+	return []string{SymbologyQR}
+}
+
+// Decode decodes QR Codes from image
+func (goDecoder) Decode(img image.Image, symbologies ...string) (symbols []Symbol, err error) {
+	for _, name := range symbologies {
+		if name != SymbologyQR {
+			err = errors.Wrap(ErrUnknownSymbology, name)
+			return
+		}
+	}
+
+	gray, ok := img.(*image.Gray)
+	if !ok {
+		bounds := img.Bounds()
+		gray = image.NewGray(bounds)
+		draw.Draw(gray, bounds, img, bounds.Min, draw.Src)
+	}
+
+	// standalone symbols and Structured Append sequences, in order found
+	var entries [][]qr.Found
+	sequences := make(map[[2]int]int)
+	for _, found := range qr.Scan(gray) {
+		if found.Append == nil || found.Append.Index >= found.Append.Total {
+			entries = append(entries, []qr.Found{found})
+			continue
+		}
+		key := [2]int{found.Append.Total, int(found.Append.Parity)}
+		index, ok := sequences[key]
+		if !ok {
+			index = len(entries)
+			sequences[key] = index
+			entries = append(entries, make([]qr.Found, found.Append.Total))
+		}
+		entries[index][found.Append.Index] = found
+	}
+
+	for _, entry := range entries {
+		complete := true
+		for _, part := range entry {
+			complete = complete && part.Decoded != nil
+		}
+		if complete {
+			symbols = append(symbols, newQRSymbol(entry))
+			continue
+		}
+		// parts of incomplete sequence are left apart
+		for _, part := range entry {
+			if part.Decoded != nil {
+				symbols = append(symbols, newQRSymbol([]qr.Found{part}))
+			}
+		}
+	}
+	return
+}
+
+// newQRSymbol makes symbol of QR Codes joined in order,
+// located by the first of them.
+func newQRSymbol(parts []qr.Found) Symbol {
+	symbol := Symbol{
+		Symbology: SymbologyQR,
+		Quality:   1,
+		Polygon:   parts[0].Corners[:],
+	}
+	var data bytes.Buffer
+	for _, part := range parts {
+		for _, run := range part.Parts {
+			symbol.Raw = append(symbol.Raw, run.Data...)
+			data.WriteString(text(run))
+		}
+	}
+	symbol.Data = data.String()
+	return symbol
+}
+
+// text converts part into UTF-8 per its ECI, or by guess if not designated,
+// which takes UTF-8, Shift JIS and then ISO-8859-1.
+func text(part qr.Part) string {
+	if charset, ok := eciEncodings[part.ECI]; ok {
+		decoded, err := charset.NewDecoder().Bytes(part.Data)
+		if err != nil {
+			return string(part.Data)
+		}
+		return string(decoded)
+	}
+	if part.ECI != 0 {
+		return string(part.Data)
+	}
+
+	if !part.Kanji && utf8.Valid(part.Data) {
+		return string(part.Data)
+	}
+	decoded, err := japanese.ShiftJIS.NewDecoder().Bytes(part.Data)
+	if err == nil && !bytes.ContainsRune(decoded, utf8.RuneError) {
+		return string(decoded)
+	}
+	decoded, _ = charmap.ISO8859_1.NewDecoder().Bytes(part.Data)
+	return string(decoded)
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qrcode
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// corpus in testdata, made by testdata/gen.go
+var corpus = []struct {
+	file      string
+	symbology string
+	data      string
+	frame     int
+	// corners top-left, bottom-left, bottom-right and top-right
+	corners [4]image.Point
+	// tolerance of corners in pixels, along X and Y
+	tolerance image.Point
+}{
+	{
+		file:      "qr.png",
+		symbology: SymbologyQR,
+		data:      "https://github.com/nanmu42/qrcode-api",
+		corners:   [4]image.Point{{16, 16}, {16, 132}, {132, 132}, {132, 16}},
+		tolerance: image.Pt(4, 4),
+	},
+	{
+		file:      "qr-utf8.png",
+		symbology: SymbologyQR,
+		data:      "二维码，QR Code",
+		corners:   [4]image.Point{{16, 16}, {16, 116}, {116, 116}, {116, 16}},
+		tolerance: image.Pt(4, 4),
+	},
+	{
+		file:      "qr-turned.png",
+		symbology: SymbologyQR,
+		data:      "turned right",
+		corners:   [4]image.Point{{100, 16}, {16, 16}, {16, 100}, {100, 100}},
+		tolerance: image.Pt(4, 4),
+	},
+	{
+		file:      "qr.jpg",
+		symbology: SymbologyQR,
+		data:      "JPEG of quality 50",
+		corners:   [4]image.Point{{20, 20}, {20, 145}, {145, 145}, {145, 20}},
+		tolerance: image.Pt(5, 5),
+	},
+	{
+		file:      "qr-frames.gif",
+		symbology: SymbologyQR,
+		data:      "first frame",
+		corners:   [4]image.Point{{16, 16}, {16, 116}, {116, 116}, {116, 16}},
+		tolerance: image.Pt(4, 4),
+	},
+	{
+		file:      "qr-frames.gif",
+		symbology: SymbologyQR,
+		data:      "second frame",
+		frame:     1,
+		corners:   [4]image.Point{{16, 16}, {16, 116}, {116, 116}, {116, 16}},
+		tolerance: image.Pt(4, 4),
+	},
+	{
+		// rendered in 200 dpi, 11 pixels or so per module
+		file:      "qr.pdf",
+		symbology: SymbologyQR,
+		data:      "QR Code in PDF",
+		corners:   [4]image.Point{{45, 45}, {45, 277}, {277, 277}, {277, 45}},
+		tolerance: image.Pt(11, 11),
+	},
+	{
+		// scan lines may be anywhere across bars
+		file:      "ean13.png",
+		symbology: SymbologyEAN13,
+		data:      "5901234123457",
+		corners:   [4]image.Point{{8, 70}, {8, 70}, {197, 70}, {197, 70}},
+		tolerance: image.Pt(4, 63),
+	},
+	{
+		file:      "code128.png",
+		symbology: SymbologyCode128,
+		data:      "Code 128",
+		corners:   [4]image.Point{{8, 80}, {8, 80}, {253, 80}, {253, 80}},
+		tolerance: image.Pt(4, 72),
+	},
+}
+
+func TestDecoders(t *testing.T) {
+	if len(Decoders()) == 0 {
+		t.Skip(ErrNoDecoder)
+	}
+	defer func(inUse Decoder) {
+		decoder = inUse
+	}(decoder)
+
+	for _, name := range Decoders() {
+		err := UseDecoder(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tt := range corpus {
+			if !IsDecodableSymbology(tt.symbology) {
+				continue
+			}
+			file, err := ioutil.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			symbols, err := DecodeFile(file, EffortLow, tt.symbology)
+			if err != nil {
+				t.Errorf("%s: %s: %v", name, tt.file, err)
+				continue
+			}
+
+			var symbol *Symbol
+			for i := range symbols {
+				if symbols[i].Frame == tt.frame {
+					symbol = &symbols[i]
+				}
+			}
+			if symbol == nil {
+				t.Errorf("%s: %s: nothing found in frame %d", name, tt.file, tt.frame)
+				continue
+			}
+			if symbol.Symbology != tt.symbology || symbol.Data != tt.data {
+				t.Errorf("%s: %s: found %s %q, want %s %q", name, tt.file, symbol.Symbology, symbol.Data, tt.symbology, tt.data)
+			}
+
+			corners := symbol.Corners()
+			for i, corner := range corners {
+				off := corner.Sub(tt.corners[i])
+				if off.X > tt.tolerance.X || -off.X > tt.tolerance.X || off.Y > tt.tolerance.Y || -off.Y > tt.tolerance.Y {
+					t.Errorf("%s: %s: corners = %v, want %v", name, tt.file, corners, tt.corners)
+					break
+				}
+			}
+			// top-left, bottom-left and bottom-right turn counterclockwise on screen
+			a, b := corners[1].Sub(corners[0]), corners[2].Sub(corners[1])
+			if a.X*b.Y-a.Y*b.X > 0 {
+				t.Errorf("%s: %s: corners %v are in wrong order", name, tt.file, corners)
+			}
+		}
+	}
+}
+
+func TestDecodeQRCodeAppend(t *testing.T) {
+	if len(Decoders()) == 0 {
+		t.Skip(ErrNoDecoder)
+	}
+	defer func(inUse Decoder) {
+		decoder = inUse
+	}(decoder)
+
+	const content = "Structured Append links QR Codes into one content"
+	q := QREncoder{
+		Content: content,
+		Type:    "png",
+		ECC:     ECCLow,
+		Version: 1,
+		Scale:   4,
+	}
+	parts, _, err := q.EncodeAppendContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) < 2 {
+		t.Fatalf("content is split into %d QR Codes, want more", len(parts))
+	}
+	images := make([]image.Image, 0, len(parts))
+	for _, part := range parts {
+		img, err := png.Decode(bytes.NewReader(part))
+		if err != nil {
+			t.Fatal(err)
+		}
+		images = append(images, img)
+	}
+	sheet := tile(images, color.White)
+
+	for _, name := range Decoders() {
+		err = UseDecoder(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := DecodeQRCode(sheet)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if len(got) != 1 || got[0] != content {
+			t.Errorf("%s: DecodeQRCode() = %q, want %q", name, got, []string{content})
+		}
+	}
+}
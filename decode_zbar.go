@@ -0,0 +1,149 @@
+//go:build cgo && !purego
+// +build cgo,!purego
+
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qrcode
+
+import (
+	"image"
+	"sort"
+
+	"github.com/nanmu42/qrcode-api/internal/zbar"
+	"github.com/pkg/errors"
+)
+
+// zbarTypes maps decodable symbology to ZBar symbol type
+var zbarTypes = map[string]zbar.Type{
+	SymbologyQR:         zbar.QRCode,
+	SymbologyPDF417:     zbar.PDF417,
+	SymbologyCode128:    zbar.Code128,
+	SymbologyEAN13:      zbar.EAN13,
+	SymbologyUPCA:       zbar.UPCA,
+	SymbologyEAN2:       zbar.EAN2,
+	SymbologyEAN5:       zbar.EAN5,
+	SymbologyEAN8:       zbar.EAN8,
+	SymbologyUPCE:       zbar.UPCE,
+	SymbologyISBN10:     zbar.ISBN10,
+	SymbologyISBN13:     zbar.ISBN13,
+	SymbologyI25:        zbar.I25,
+	SymbologyDataBar:    zbar.DataBar,
+	SymbologyDataBarExp: zbar.DataBarExp,
+	SymbologyCodabar:    zbar.Codabar,
+	SymbologyCode39:     zbar.Code39,
+	SymbologyCode93:     zbar.Code93,
+}
+
+func init() {
+	registerDecoder(DecoderZBar, zbarDecoder{})
+}
+
+// zbarDecoder decodes by ZBar
+type zbarDecoder struct{}
+
+// Symbologies lists symbologies of ZBar, sorted
+func (zbarDecoder) Symbologies() (names []string) {
+	for name := range zbarTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// Decode decodes symbols of symbologies from image by ZBar
+func (zbarDecoder) Decode(img image.Image, symbologies ...string) (symbols []Symbol, err error) {
+	s := zbar.NewScanner()
+	defer s.Destroy()
+
+	// start from nothing
+	err = s.Enable(zbar.None, false)
+	if err != nil {
+		err = errors.Wrap(err, "zbar config error")
+		return
+	}
+	if len(symbologies) == 0 {
+		for name, symbolType := range zbarTypes {
+			if name == SymbologyISBN10 || name == SymbologyISBN13 {
+				continue
+			}
+			// older ZBar does not know every type
+			_ = s.Enable(symbolType, true)
+		}
+	}
+	for _, name := range symbologies {
+		symbolType, ok := zbarTypes[name]
+		if !ok {
+			err = errors.Wrap(ErrUnknownSymbology, name)
+			return
+		}
+		err = s.Enable(symbolType, true)
+		if err != nil {
+			err = errors.Wrapf(err, "zbar can not decode %s", name)
+			return
+		}
+	}
+
+	found, err := s.Scan(img)
+	if err != nil {
+		return
+	}
+
+	names := make(map[zbar.Type]string, len(zbarTypes))
+	for name, symbolType := range zbarTypes {
+		names[symbolType] = name
+	}
+	var (
+		raws    [][]byte
+		qrIndex int
+	)
+	for _, item := range found {
+		symbol := Symbol{
+			Symbology: names[item.Type],
+			Data:      string(item.Data),
+			Raw:       item.Data,
+			Quality:   item.Quality,
+			Polygon:   item.Points,
+		}
+		if item.Type == zbar.QRCode {
+			if raws == nil {
+				raws = rawQRCodes(img)
+			}
+			if qrIndex < len(raws) {
+				symbol.Raw = raws[qrIndex]
+			}
+			qrIndex++
+		}
+		symbols = append(symbols, symbol)
+	}
+
+	return
+}
+
+// rawQRCodes scans img again for raw bytes of QR Codes,
+// in the same order of QR Codes in normal scan.
+//
+// raws is empty if ZBar does not support binary mode.
+func rawQRCodes(img image.Image) (raws [][]byte) {
+	s := zbar.NewScanner()
+	defer s.Destroy()
+
+	raws = [][]byte{}
+	if s.Enable(zbar.None, false) != nil || s.Enable(zbar.QRCode, true) != nil || s.SetBinary(zbar.QRCode, true) != nil {
+		return
+	}
+	found, err := s.Scan(img)
+	if err != nil {
+		return
+	}
+	for _, item := range found {
+		if item.Type == zbar.QRCode {
+			raws = append(raws, item.Data)
+		}
+	}
+	return
+}
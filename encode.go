@@ -8,11 +8,20 @@
 package qrcode
 
 import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
 	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/pkg/errors"
 
-	qrc "github.com/skip2/go-qrcode"
+	"github.com/nanmu42/qrcode-api/internal/qr"
 )
 
 const (
@@ -20,45 +29,809 @@ const (
 	TypePNG = "png"
 	// TypeString QRCode
 	TypeString = "string"
+	// TypeSVG file as svg
+	TypeSVG = "svg"
+	// TypePDF file as pdf
+	TypePDF = "pdf"
+	// TypeEPS file as eps
+	TypeEPS = "eps"
+	// TypeJPEG file as jpeg
+	TypeJPEG = "jpeg"
+	// TypeGIF file as gif
+	TypeGIF = "gif"
+	// TypeBMP file as bmp
+	TypeBMP = "bmp"
+	// TypeWebP file as lossless webp
+	TypeWebP = "webp"
 
 	// DefaultType default file type
 	DefaultType = TypePNG
 )
 
+// Format is type of file to produce, typed counterpart of Type constants
+type Format string
+
+// formats of file
+const (
+	FormatPNG    Format = TypePNG
+	FormatString Format = TypeString
+	FormatSVG    Format = TypeSVG
+	FormatPDF    Format = TypePDF
+	FormatEPS    Format = TypeEPS
+	FormatJPEG   Format = TypeJPEG
+	FormatGIF    Format = TypeGIF
+	FormatBMP    Format = TypeBMP
+	FormatWebP   Format = TypeWebP
+)
+
+const (
+	// DefaultBorder default width of quiet zone around QR Code in modules
+	DefaultBorder = 4
+	// NoBorder leaves out quiet zone
+	NoBorder = -1
+)
+
+const (
+	// ECCLow recovers 7% of data
+	ECCLow = "L"
+	// ECCMedium recovers 15% of data
+	ECCMedium = "M"
+	// ECCQuartile recovers 25% of data
+	ECCQuartile = "Q"
+	// ECCHigh recovers 30% of data
+	ECCHigh = "H"
+
+	// DefaultECC default error correction level
+	DefaultECC = ECCMedium
+)
+
+const (
+	// UnitMillimeter physical size in millimeter
+	UnitMillimeter = "mm"
+	// UnitInch physical size in inch
+	UnitInch = "in"
+
+	// DefaultDPI dots per inch when not specified,
+	// one pixel equals to one point(1/72 inch) at this DPI.
+	DefaultDPI = 72
+)
+
+const (
+	// SymbologyQR QR Code Model 2
+	SymbologyQR = "qr"
+	// SymbologyMicroQR Micro QR Code from M1 to M4, for small labels.
+	// It supports ECC L, M and Q(M4 only).
+	SymbologyMicroQR = "microqr"
+	// SymbologyRMQR rectangular Micro QR Code(rMQR) from R7x43 to R17x139,
+	// for narrow labels. It supports ECC M and H.
+	SymbologyRMQR = "rmqr"
+
+	// DefaultSymbology default symbology
+	DefaultSymbology = SymbologyQR
+)
+
+const (
+	// AutoVersion picks the smallest QR Code version which fits content
+	AutoVersion = 0
+	// MinVersion smallest QR Code version
+	MinVersion = qr.MinVersion
+	// MaxVersion largest QR Code version
+	MaxVersion = qr.MaxVersion
+
+	// MaxMask largest mask pattern, which starts from 0
+	MaxMask = 7
+
+	// MaxMicroVersion largest Micro QR version, 1 to 4 stand for M1 to M4
+	MaxMicroVersion = qr.MaxMicroVersion
+	// MaxMicroMask largest Micro QR mask pattern
+	MaxMicroMask = qr.MaxMicroMask
+	// MaxRMQRVersion largest rMQR version, see RMQRVersion
+	MaxRMQRVersion = qr.MaxRMQRVersion
+
+	// MaxECI largest ECI assignment number
+	MaxECI = qr.MaxECI
+)
+
+// eciCharsets maps character set to ECI assignment number
+var eciCharsets = map[string]int{
+	"cp437":        2,
+	"iso-8859-1":   3,
+	"iso-8859-2":   4,
+	"iso-8859-3":   5,
+	"iso-8859-4":   6,
+	"iso-8859-5":   7,
+	"iso-8859-6":   8,
+	"iso-8859-7":   9,
+	"iso-8859-8":   10,
+	"iso-8859-9":   11,
+	"iso-8859-10":  12,
+	"iso-8859-11":  13,
+	"iso-8859-13":  15,
+	"iso-8859-14":  16,
+	"iso-8859-15":  17,
+	"iso-8859-16":  18,
+	"shift_jis":    20,
+	"windows-1250": 21,
+	"windows-1251": 22,
+	"windows-1252": 23,
+	"windows-1256": 24,
+	"utf-16be":     25,
+	"utf-8":        26,
+	"us-ascii":     27,
+	"big5":         28,
+	"gb18030":      29,
+	"euc-kr":       30,
+	"binary":       899,
+}
+
+// byteCapacity is the max content length in bytes
+// a QR Code (version 40, byte mode) holds per error correction level
+var byteCapacity = map[string]int{
+	ECCLow:      2953,
+	ECCMedium:   2331,
+	ECCQuartile: 1663,
+	ECCHigh:     1273,
+}
+
 // QREncoder holds info for QR code encoding
 type QREncoder struct {
 	// content to encode
 	Content string
 	// desired encoding type
 	Type string
-	// desired image size in pixel, may not be honored
+	// desired image size in pixel,
+	// image grows if it is too small to hold every module unless Strict
 	Size int
+	// error correction level, L, M, Q or H, DefaultECC if empty
+	ECC string
+	// physical image size in Unit, overrides Size when positive
+	PhysicalSize float64
+	// unit of PhysicalSize, mm or in
+	Unit string
+	// dots per inch, converts between pixel and physical size
+	DPI int
+	// color of dark modules, DefaultForeground if nil
+	Foreground color.Color
+	// color of light modules, DefaultBackground if nil,
+	// may be transparent.
+	Background color.Color
+	// quiet zone width in modules, DefaultBorder if 0, NoBorder for none
+	Border int
+	// pixels per module, image size follows QR Code when positive,
+	// overrides Size and PhysicalSize.
+	Scale int
+	// JPEG quality ranging from 1 to 100, jpeg.DefaultQuality if 0
+	Quality int
+	// image size must be exactly Size(or PhysicalSize),
+	// otherwise image grows when too small to hold every module.
+	Strict bool
+	// optional logo in the center, which forces ECC to be ECCHigh.
+	// Only raster types and SVG support logo.
+	Logo image.Image
+	// QR Code version from MinVersion to MaxVersion, or AutoVersion.
+	// Content must fit in the version, which is never changed.
+	// It is up to MaxMicroVersion for Micro QR, MaxRMQRVersion for rMQR.
+	Version int
+	// mask pattern from 0 to MaxMask(MaxMicroMask for Micro QR),
+	// the one with lowest penalty is picked if nil.
+	// rMQR has only one mask pattern so it should be nil.
+	Mask *int
+	// symbology, DefaultSymbology if empty.
+	// Only QR Code supports logo and Structured Append,
+	// and only QR Code family supports Version and Mask.
+	Symbology string
+	// ECI assignment number declaring character set of Content,
+	// see ECI. None if 0, and Content is usually read as UTF-8.
+	// Only QR Code and rMQR support ECI.
+	ECI int
 }
 
+// ErrSizeTooSmall is returned in strict mode when
+// image size can not hold every module.
+var ErrSizeTooSmall = errors.New("image size is too small for QR Code")
+
+// ErrContentTooLong is returned when content does not fit in
+// symbol(s) of chosen version and error correction level.
+var ErrContentTooLong = errors.New("content is too long")
+
+// ErrUnknownType is returned when file type is not supported
+var ErrUnknownType = errors.New("unknown type")
+
 // Encode produces a QR code
 func (q *QREncoder) Encode(dest io.Writer) (gotType string, err error) {
-	qrcode, err := qrc.New(q.Content, qrc.Medium)
+	return q.EncodeContext(context.Background(), dest)
+}
+
+// EncodeContext is Encode giving up when ctx is done,
+// in which case err is ctx.Err() and nothing is written to dest.
+//
+// ctx is checked before encoding and before rendering.
+func (q *QREncoder) EncodeContext(ctx context.Context, dest io.Writer) (gotType string, err error) {
+	err = ctx.Err()
+	if err != nil {
+		return
+	}
+	gotType, err = q.check()
+	if err != nil {
+		return
+	}
+
+	modules, err := q.modules()
+	if err != nil {
+		return
+	}
+	err = ctx.Err()
+	if err != nil {
+		return
+	}
+	err = q.write(dest, gotType, modules)
+	return
+}
+
+// Modules encodes content into modules of chosen symbology
+// for rendering on your own, dark ones are true.
+//
+// Modules are in rows from top without quiet zone,
+// and linear(1D) barcode has only one row. Logo is not drawn,
+// while it raises error correction level all the same.
+func (q *QREncoder) Modules() (modules [][]bool, err error) {
+	err = q.checkSymbol()
+	if err != nil {
+		return
+	}
+	return q.modules()
+}
+
+// modules encodes content into modules of chosen symbology
+func (q *QREncoder) modules() (modules [][]bool, err error) {
+	segments := []qr.Segment{qr.MakeSegment([]byte(q.Content))}
+	var code *qr.Code
+	switch q.symbology() {
+	case SymbologyQR:
+		code, err = qr.Encode(segments, q.options())
+	case SymbologyMicroQR:
+		code, err = qr.EncodeMicro(segments, q.options())
+	case SymbologyRMQR:
+		code, err = qr.EncodeRMQR(segments, q.options())
+	default:
+		symbology, _ := lookupSymbology(q.symbology())
+		err = ValidateContent(q.symbology(), q.Content)
+		if err != nil {
+			return
+		}
+		modules, err = symbology.Modules(q.Content, q.ecc())
+		return
+	}
+	if err != nil {
+		err = q.encodeError(err)
+		return
+	}
+	modules = code.Modules
+	return
+}
+
+// EncodeAppend splits content into up to qr.MaxAppendSymbols QR Codes
+// linked by Structured Append, each one is rendered in its own file.
+//
+// There is only one part, which is a plain QR Code, if content fits in.
+func (q *QREncoder) EncodeAppend() (parts [][]byte, gotType string, err error) {
+	return q.EncodeAppendContext(context.Background())
+}
+
+// EncodeAppendContext is EncodeAppend giving up when ctx is done,
+// in which case err is ctx.Err() and parts is nil.
+//
+// ctx is checked before encoding and before rendering every QR Code.
+func (q *QREncoder) EncodeAppendContext(ctx context.Context) (parts [][]byte, gotType string, err error) {
+	err = ctx.Err()
+	if err != nil {
+		return
+	}
+	gotType, err = q.check()
+	if err != nil {
+		return
+	}
+	if q.symbology() != SymbologyQR {
+		err = errors.Errorf("Structured Append is not supported by symbology %s", q.symbology())
+		return
+	}
+
+	codes, err := qr.EncodeAppend([]byte(q.Content), q.options())
+	if err != nil {
+		err = q.encodeError(err)
+		return
+	}
+	for _, code := range codes {
+		err = ctx.Err()
+		if err != nil {
+			parts = nil
+			return
+		}
+		var buf bytes.Buffer
+		err = q.write(&buf, gotType, code.Modules)
+		if err != nil {
+			return
+		}
+		parts = append(parts, buf.Bytes())
+	}
+	return
+}
+
+// EncodeSheet is like EncodeAppend, but tiles QR Codes
+// into one image in reading order. Only raster types are supported.
+func (q *QREncoder) EncodeSheet(dest io.Writer) (gotType string, err error) {
+	return q.EncodeSheetContext(context.Background(), dest)
+}
+
+// EncodeSheetContext is EncodeSheet giving up when ctx is done,
+// in which case err is ctx.Err() and nothing is written to dest.
+//
+// ctx is checked before encoding, before rendering every QR Code and before writing.
+func (q *QREncoder) EncodeSheetContext(ctx context.Context, dest io.Writer) (gotType string, err error) {
+	err = ctx.Err()
+	if err != nil {
+		return
+	}
+	gotType, err = q.check()
+	if err != nil {
+		return
+	}
+	if q.symbology() != SymbologyQR {
+		err = errors.Errorf("Structured Append is not supported by symbology %s", q.symbology())
+		return
+	}
+	if !IsRasterType(gotType) {
+		err = errors.Errorf("sheet is not supported by type %s", gotType)
+		return
+	}
+
+	codes, err := qr.EncodeAppend([]byte(q.Content), q.options())
 	if err != nil {
-		err = errors.Wrap(err, "cannot get a QR Code instance")
+		err = q.encodeError(err)
+		return
+	}
+	tiles := make([]image.Image, 0, len(codes))
+	for _, code := range codes {
+		err = ctx.Err()
+		if err != nil {
+			return
+		}
+		bitmap, area, size, prepareErr := q.prepare(code.Modules)
+		if prepareErr != nil {
+			err = prepareErr
+			return
+		}
+		tiles = append(tiles, q.raster(bitmap, area, size))
+	}
+	err = ctx.Err()
+	if err != nil {
+		return
+	}
+	err = writeRaster(dest, gotType, tile(tiles, q.background()), q.foreground(), q.background(), q.quality())
+	return
+}
+
+// check validates type, symbology and logo, returns type to produce
+func (q *QREncoder) check() (fileType string, err error) {
+	fileType, err = fileTypeCheck(q.Type)
+	if err != nil {
+		return
+	}
+	err = q.checkSymbol()
+	if err != nil {
+		return
+	}
+	if q.Logo != nil {
+		if !IsRasterType(fileType) && fileType != TypeSVG {
+			err = errors.Errorf("logo is not supported by type %s", fileType)
+			return
+		}
+		if q.Logo.Bounds().Empty() {
+			err = errors.New("logo is empty")
+			return
+		}
+	}
+	if q.Size < 0 {
+		err = errors.New("size should not be negative")
+		return
+	}
+	if q.Scale < 0 {
+		err = errors.New("scale should not be negative")
+		return
+	}
+	if q.DPI < 0 {
+		err = errors.New("dpi should not be negative")
+		return
+	}
+	if q.PhysicalSize < 0 {
+		err = errors.New("physical size should not be negative")
+		return
+	}
+	if q.PhysicalSize > 0 && q.Unit != UnitMillimeter && q.Unit != UnitInch {
+		err = errors.Errorf("unknown unit %s, which should be one of mm or in", q.Unit)
+		return
+	}
+	if q.Quality < 0 || q.Quality > 100 {
+		err = errors.New("quality should be from 1 to 100, or 0 for default")
+		return
+	}
+	return
+}
+
+// checkSymbol validates symbology and the options it supports
+func (q *QREncoder) checkSymbol() (err error) {
+	if !IsValidSymbology(q.symbology()) {
+		err = errors.Errorf("unknown symbology %s", q.Symbology)
 		return
 	}
-	switch fileTypeCheck(q.Type) {
-	case TypePNG:
-		gotType = TypePNG
-		err = qrcode.Write(q.Size, dest)
+	if q.ECI != 0 && q.symbology() != SymbologyQR && q.symbology() != SymbologyRMQR {
+		err = errors.Errorf("ECI is not supported by symbology %s", q.symbology())
+		return
+	}
+	if q.Logo != nil && q.symbology() != SymbologyQR {
+		err = errors.Errorf("logo is not supported by symbology %s", q.symbology())
+		return
+	}
+	if q.ECI < 0 || q.ECI > MaxECI {
+		err = errors.Errorf("eci should be from 1 to %d, or 0 for none", MaxECI)
+		return
+	}
+
+	if len(q.ECC) > 0 && !IsValidECC(q.ECC) {
+		err = errors.Errorf("unknown ecc %s, which should be one of L, M, Q or H", q.ECC)
+		return
+	}
+	switch q.symbology() {
+	case SymbologyMicroQR:
+		if q.ecc() == ECCHigh {
+			err = errors.New("ecc should be one of L, M or Q for symbology microqr")
+			return
+		}
+	case SymbologyRMQR:
+		if q.ecc() != ECCMedium && q.ecc() != ECCHigh {
+			err = errors.New("ecc should be one of M or H for symbology rmqr")
+			return
+		}
+	}
+
+	maxVersion, maxMask := versionMaskRange(q.symbology())
+	if q.Version != AutoVersion && maxVersion == AutoVersion {
+		err = errors.Errorf("version is not supported by symbology %s", q.symbology())
+		return
+	}
+	if q.Version < AutoVersion || q.Version > maxVersion {
+		err = errors.Errorf("version should be from 1 to %d for symbology %s", maxVersion, q.symbology())
+		return
+	}
+	if q.Mask != nil && maxMask < 0 {
+		err = errors.Errorf("mask is not supported by symbology %s", q.symbology())
+		return
+	}
+	if q.Mask != nil && (*q.Mask < 0 || *q.Mask > maxMask) {
+		err = errors.Errorf("mask should be from 0 to %d for symbology %s", maxMask, q.symbology())
+		return
+	}
+	return
+}
+
+// versionMaskRange returns the largest version and mask pattern of symbology,
+// AutoVersion and -1 if it does not support them.
+func versionMaskRange(symbology string) (maxVersion, maxMask int) {
+	switch symbology {
+	case SymbologyQR:
+		return MaxVersion, MaxMask
+	case SymbologyMicroQR:
+		return MaxMicroVersion, MaxMicroMask
+	case SymbologyRMQR:
+		return MaxRMQRVersion, -1
+	default:
+		return AutoVersion, -1
+	}
+}
+
+// options returns symbol options in effect
+func (q *QREncoder) options() qr.Options {
+	mask := qr.AutoMask
+	if q.Mask != nil {
+		mask = *q.Mask
+	}
+	return qr.Options{
+		Level:   qrLevel(q.ecc()),
+		Version: q.Version,
+		Mask:    mask,
+		ECI:     q.ECI,
+	}
+}
+
+// encodeError explains error from qr
+func (q *QREncoder) encodeError(err error) error {
+	if err != qr.ErrTooLong {
+		return errors.Wrap(err, "cannot get a QR Code instance")
+	}
+	if q.Version != AutoVersion && q.symbology() == SymbologyRMQR {
+		return errors.Wrapf(ErrContentTooLong, "version %s with ecc %s", qr.RMQRName(q.Version), q.ecc())
+	}
+	if q.Version != AutoVersion {
+		return errors.Wrapf(ErrContentTooLong, "version %d with ecc %s", q.Version, q.ecc())
+	}
+	return errors.Wrapf(ErrContentTooLong, "ecc %s", q.ecc())
+}
+
+// prepare puts quiet zone around modules and clears logo area,
+// returning image size in pixel as well.
+// Single row of linear barcode is stretched into bars.
+func (q *QREncoder) prepare(modules [][]bool) (bitmap [][]bool, area image.Rectangle, size int, err error) {
+	border := q.border()
+	if len(modules) == 1 {
+		modules = stretchBars(modules)
+		if q.Border == 0 {
+			border = DefaultLinearBorder
+		}
+	}
+	bitmap = reborder(modules, 0, border)
+	if q.Logo != nil {
+		area = logoArea(len(bitmap), q.border())
+		bitmap = clearArea(bitmap, area)
+	}
+
+	size = q.pixels(len(bitmap[0]))
+	if q.Strict && q.Scale <= 0 && size < len(bitmap[0]) {
+		err = ErrSizeTooSmall
+		return
+	}
+	return
+}
+
+// raster draws bitmap and logo into image
+func (q *QREncoder) raster(bitmap [][]bool, area image.Rectangle, size int) (img image.Image) {
+	img = rasterize(bitmap, size, q.foreground(), q.background())
+	if q.Logo != nil {
+		offset, pixelsPerModule := modulePixels(len(bitmap), size)
+		img = drawLogo(img, q.Logo, image.Rect(
+			offset+area.Min.X*pixelsPerModule,
+			offset+area.Min.Y*pixelsPerModule,
+			offset+area.Max.X*pixelsPerModule,
+			offset+area.Max.Y*pixelsPerModule,
+		))
+	}
+	return
+}
+
+// write renders modules of a symbol into fileType
+func (q *QREncoder) write(dest io.Writer, fileType string, modules [][]bool) (err error) {
+	bitmap, area, size, err := q.prepare(modules)
+	if err != nil {
+		return
+	}
+
+	fg, bg := q.foreground(), q.background()
+	switch fileType {
+	case TypePNG, TypeJPEG, TypeGIF, TypeBMP, TypeWebP:
+		err = writeRaster(dest, fileType, q.raster(bitmap, area, size), fg, bg, q.quality())
 	case TypeString:
-		gotType = TypeString
-		_, err = dest.Write([]byte(qrcode.ToString(true)))
+		_, err = io.WriteString(dest, toText(bitmap))
+	case TypeSVG:
+		width, height := q.svgSize(len(bitmap[0]), len(bitmap))
+		err = writeSVG(dest, bitmap, width, height, fg, bg, q.Logo, area)
+	case TypePDF:
+		err = writePDF(dest, bitmap, q.pointSize(len(bitmap[0])), fg, bg)
+	case TypeEPS:
+		err = writeEPS(dest, bitmap, q.pointSize(len(bitmap[0])), fg, bg)
 	}
 	return
 }
 
-// fileTypeCheck checks incoming types
-func fileTypeCheck(want string) string {
-	switch want {
-	case TypePNG, TypeString:
-		return want
+// border returns quiet zone width in effect
+func (q *QREncoder) border() int {
+	switch {
+	case q.Border == 0:
+		return DefaultBorder
+	case q.Border < 0:
+		return 0
+	default:
+		return q.Border
+	}
+}
+
+// symbology returns symbology in effect
+func (q *QREncoder) symbology() string {
+	if len(q.Symbology) == 0 {
+		return DefaultSymbology
+	}
+	return q.Symbology
+}
+
+// quality returns JPEG quality in effect
+func (q *QREncoder) quality() int {
+	if q.Quality == 0 {
+		return jpeg.DefaultQuality
+	}
+	return q.Quality
+}
+
+// ecc returns error correction level in effect
+func (q *QREncoder) ecc() string {
+	if q.Logo != nil {
+		return ECCHigh
+	}
+	if len(q.ECC) == 0 {
+		return DefaultECC
+	}
+	return q.ECC
+}
+
+// fileTypeCheck checks incoming types, DefaultType if empty
+func fileTypeCheck(want string) (fileType string, err error) {
+	if len(want) == 0 {
+		fileType = DefaultType
+		return
+	}
+	if !IsValidType(want) {
+		err = errors.Wrapf(ErrUnknownType, "type %s", want)
+		return
+	}
+	fileType = want
+	return
+}
+
+// PixelSize returns image size in pixel,
+// converted from physical size if there is one.
+func (q *QREncoder) PixelSize() int {
+	inches, ok := q.inches()
+	if !ok {
+		return q.Size
+	}
+	return int(math.Round(inches * float64(q.dpi())))
+}
+
+// foreground returns color of dark modules
+func (q *QREncoder) foreground() color.Color {
+	if q.Foreground == nil {
+		return DefaultForeground
+	}
+	return q.Foreground
+}
+
+// background returns color of light modules
+func (q *QREncoder) background() color.Color {
+	if q.Background == nil {
+		return DefaultBackground
+	}
+	return q.Background
+}
+
+// pixels returns image width in pixel for a bitmap of modules wide
+func (q *QREncoder) pixels(modules int) int {
+	if q.Scale > 0 {
+		return modules * q.Scale
+	}
+	return q.PixelSize()
+}
+
+// pointSize returns image width in point(1/72 inch) for vector output
+func (q *QREncoder) pointSize(modules int) float64 {
+	if inches, ok := q.inches(); ok && q.Scale <= 0 {
+		return inches * 72
+	}
+	return float64(q.pixels(modules)) * 72 / float64(q.dpi())
+}
+
+// svgSize returns image width and height as SVG length
+// for a bitmap of columns x rows modules.
+func (q *QREncoder) svgSize(columns, rows int) (width, height string) {
+	ratio := float64(rows) / float64(columns)
+	if _, ok := q.inches(); ok && q.Scale <= 0 {
+		width = strconv.FormatFloat(q.PhysicalSize, 'f', -1, 64) + q.Unit
+		height = strconv.FormatFloat(q.PhysicalSize*ratio, 'f', -1, 64) + q.Unit
+		return
+	}
+	pixels := q.pixels(columns)
+	width = strconv.Itoa(pixels)
+	height = strconv.FormatFloat(float64(pixels)*ratio, 'f', -1, 64)
+	return
+}
+
+// inches converts physical size into inches,
+// ok is false if there is no valid physical size.
+func (q *QREncoder) inches() (inches float64, ok bool) {
+	if q.PhysicalSize <= 0 {
+		return
+	}
+	switch q.Unit {
+	case UnitInch:
+		return q.PhysicalSize, true
+	case UnitMillimeter:
+		return q.PhysicalSize / 25.4, true
+	default:
+		return
+	}
+}
+
+// dpi returns DPI, DefaultDPI if not set
+func (q *QREncoder) dpi() int {
+	if q.DPI <= 0 {
+		return DefaultDPI
+	}
+	return q.DPI
+}
+
+// IsValidType tells whether fileType is supported
+func IsValidType(fileType string) bool {
+	switch fileType {
+	case TypePNG, TypeString, TypeSVG, TypePDF, TypeEPS,
+		TypeJPEG, TypeGIF, TypeBMP, TypeWebP:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsRasterType tells whether fileType is a raster image
+func IsRasterType(fileType string) bool {
+	switch fileType {
+	case TypePNG, TypeJPEG, TypeGIF, TypeBMP, TypeWebP:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsValidSymbology tells whether symbology is supported
+func IsValidSymbology(symbology string) bool {
+	if IsQRFamily(symbology) {
+		return true
+	}
+	_, ok := lookupSymbology(symbology)
+	return ok
+}
+
+// RMQRVersion looks up rMQR version by size in modules like R7x43(height x width),
+// ok is false if there is no such one.
+func RMQRVersion(name string) (version int, ok bool) {
+	return qr.RMQRVersion(name)
+}
+
+// IsValidECC tells whether ecc is a known error correction level
+func IsValidECC(ecc string) bool {
+	_, ok := byteCapacity[ecc]
+	return ok
+}
+
+// Capacity returns max content length in bytes for error correction level ecc,
+// unknown level is treated as DefaultECC.
+func Capacity(ecc string) int {
+	if !IsValidECC(ecc) {
+		ecc = DefaultECC
+	}
+	return byteCapacity[ecc]
+}
+
+// ECI returns ECI assignment number of character set,
+// which is case-insensitive like UTF-8, Shift_JIS or GB18030.
+func ECI(charset string) (eci int, ok bool) {
+	eci, ok = eciCharsets[strings.ToLower(charset)]
+	return
+}
+
+// AppendCapacity returns max content length in bytes for error correction
+// level ecc when content is split by EncodeAppend or EncodeSheet.
+//
+// Structured Append header takes 2 bytes in every symbol,
+// and a few more are kept as split moves to UTF-8 boundaries.
+func AppendCapacity(ecc string) int {
+	return qr.MaxAppendSymbols * (Capacity(ecc) - 2 - utf8.UTFMax)
+}
+
+// qrLevel maps error correction level into qr's
+func qrLevel(ecc string) qr.Level {
+	switch ecc {
+	case ECCLow:
+		return qr.Low
+	case ECCQuartile:
+		return qr.Quartile
+	case ECCHigh:
+		return qr.High
 	default:
-		return DefaultType
+		return qr.Medium
 	}
 }
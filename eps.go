@@ -0,0 +1,49 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qrcode
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+)
+
+// writeEPS renders bitmap into dest as an Encapsulated PostScript file,
+// whose bounding box is width points(1/72 inch) wide.
+func writeEPS(dest io.Writer, bitmap [][]bool, width float64, fg, bg color.Color) (err error) {
+	rows, columns := len(bitmap), len(bitmap[0])
+	unit := width / float64(columns)
+	height := unit * float64(rows)
+	w := bufio.NewWriter(dest)
+
+	fmt.Fprintf(w, `%%!PS-Adobe-3.0 EPSF-3.0
+%%%%Creator: qrcode-api
+%%%%BoundingBox: 0 0 %d %d
+%%%%HiResBoundingBox: 0 0 %.4f %.4f
+%%%%Pages: 1
+%%%%EndComments
+/R { rectfill } bind def
+`, int(math.Ceil(width)), int(math.Ceil(height)), width, height)
+
+	if r, g, b, transparent := rgb(bg); !transparent {
+		fmt.Fprintf(w, "%.3f %.3f %.3f setrgbcolor\n0 0 %.4f %.4f R\n", r, g, b, width, height)
+	}
+	r, g, b, _ := rgb(fg)
+	fmt.Fprintf(w, "%.3f %.3f %.3f setrgbcolor\n", r, g, b)
+	eachRun(bitmap, func(x, y, length int) {
+		// PostScript origin lies in the bottom left
+		fmt.Fprintf(w, "%.4f %.4f %.4f %.4f R\n",
+			float64(x)*unit, float64(rows-y-1)*unit, float64(length)*unit, unit)
+	})
+	w.WriteString("showpage\n%%EOF\n")
+
+	err = w.Flush()
+	return
+}
@@ -0,0 +1,401 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qrcode
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"image/gif"
+
+	"github.com/nanmu42/qrcode-api/internal/pdf"
+	"github.com/pkg/errors"
+	"golang.org/x/image/tiff"
+)
+
+// MaxFrames max pages or frames of a file to decode
+const MaxFrames = 32
+
+const (
+	// pdfDPI resolution to render PDF pages in
+	pdfDPI = 200
+	// maxPagePixels max pixels of a rendered PDF page,
+	// larger pages are rendered in lower resolution.
+	maxPagePixels = 3000 * 3000
+)
+
+// DecodeFile decodes symbols of symbologies in file, as hard as effort,
+// file may be a PDF document, a multi-page TIFF image,
+// an animated GIF or an image in any format registered to package image.
+//
+// Every page or frame is decoded, up to MaxFrames,
+// and Frame of symbols tells where they are found.
+// PDF pages are rendered without text, which does not matter to bar codes.
+//
+// symbols and err are both nil when nothing found.
+func DecodeFile(file []byte, effort string, symbologies ...string) (symbols []Symbol, err error) {
+	return DecodeFileContext(context.Background(), file, effort, symbologies...)
+}
+
+// DecodeFileContext is DecodeFile giving up when ctx is done,
+// in which case err is ctx.Err(), along with symbols found so far.
+//
+// ctx is checked before every page or frame, and the same as DecodeContext within it.
+func DecodeFileContext(ctx context.Context, file []byte, effort string, symbologies ...string) (symbols []Symbol, err error) {
+	var scanErr error
+	err = eachFrame(file, func(index int, frame image.Image) error {
+		var found []Symbol
+		found, scanErr = DecodeContext(ctx, frame, effort, symbologies...)
+		for _, symbol := range found {
+			symbol.Frame = index
+			symbols = append(symbols, symbol)
+		}
+		return scanErr
+	})
+	if scanErr != nil && scanErr == ctx.Err() {
+		err = scanErr
+		return
+	}
+	if scanErr != nil {
+		err = errors.Wrap(scanErr, "scanning error")
+		return
+	}
+	if err != nil {
+		err = errors.Wrap(err, "file decoding error")
+		return
+	}
+	return
+}
+
+// ErrTooManyPixels image is larger than allowed
+var ErrTooManyPixels = errors.New("image has too many pixels")
+
+// CheckFilePixels tells whether every page or frame of file DecodeFile
+// would decode has no more than maxPixels pixels, by reading headers only,
+// returning ErrTooManyPixels if not.
+//
+// PDF pages are always rendered in no more than 3000x3000 pixels,
+// so images in them are checked instead,
+// frames of GIF animation count together, each as large as its logical screen.
+func CheckFilePixels(file []byte, maxPixels int) (err error) {
+	// headers of untrusted file are parsed before any limit applies
+	defer func() {
+		if fatal := recover(); fatal != nil {
+			err = errors.Errorf("file decoding error: fatal error: %v", fatal)
+		}
+	}()
+
+	check := func(config image.Config) error {
+		if int64(config.Width)*int64(config.Height) > int64(maxPixels) {
+			return errors.Wrapf(ErrTooManyPixels, "%dx%d", config.Width, config.Height)
+		}
+		return nil
+	}
+
+	switch frameFormat(file) {
+	case "pdf":
+		// pages are scaled down to fit maxPagePixels, while images in them are decoded as they are
+		var doc *pdf.Document
+		doc, err = pdf.Open(file)
+		if err != nil {
+			err = errors.Wrap(err, "PDF")
+			break
+		}
+		err = doc.ImageSizes(func(width, height int) error {
+			return check(image.Config{Width: width, Height: height})
+		})
+		if err != nil {
+			err = errors.Wrap(err, "PDF image")
+		}
+	case "tiff":
+		err = tiffDirectories(file, func(index int, page []byte) (err error) {
+			config, err := tiff.DecodeConfig(bytes.NewReader(page))
+			if err == nil {
+				err = check(config)
+			}
+			if err != nil {
+				err = errors.Wrapf(err, "TIFF page %d", index)
+			}
+			return
+		})
+	case "gif":
+		var config image.Config
+		config, err = gif.DecodeConfig(bytes.NewReader(file))
+		if err != nil {
+			break
+		}
+		frames := 0
+		err = gifBlocks(file, func(index int, block []byte) error {
+			frames++
+			return nil
+		})
+		if err == nil && int64(frames)*int64(config.Width)*int64(config.Height) > int64(maxPixels) {
+			err = errors.Wrapf(ErrTooManyPixels, "%d frames of %dx%d", frames, config.Width, config.Height)
+		}
+	default:
+		var config image.Config
+		config, _, err = image.DecodeConfig(bytes.NewReader(file))
+		if err == nil {
+			err = check(config)
+		}
+	}
+	if err != nil && errors.Cause(err) != ErrTooManyPixels {
+		err = errors.Wrap(err, "file decoding error")
+	}
+	return
+}
+
+// frameFormat tells format of file which may have multiple pages or frames,
+// pdf, tiff or gif, empty for others.
+func frameFormat(file []byte) string {
+	head := file
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	switch {
+	case bytes.Contains(head, []byte("%PDF-")):
+		return "pdf"
+	case bytes.HasPrefix(file, []byte("II*\x00")) || bytes.HasPrefix(file, []byte("MM\x00*")):
+		return "tiff"
+	case bytes.HasPrefix(file, []byte("GIF8")):
+		return "gif"
+	}
+	return ""
+}
+
+// eachFrame calls fn on pages or frames in file,
+// stopping at the first error of fn, which is returned as is.
+func eachFrame(file []byte, fn func(index int, frame image.Image) error) (err error) {
+	switch frameFormat(file) {
+	case "pdf":
+		return pdfPages(file, fn)
+	case "tiff":
+		return tiffPages(file, fn)
+	case "gif":
+		return gifFrames(file, fn)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(file))
+	if err != nil {
+		return
+	}
+	return fn(0, img)
+}
+
+// pdfPages renders pages of PDF document
+func pdfPages(file []byte, fn func(index int, frame image.Image) error) (err error) {
+	doc, err := pdf.Open(file)
+	if err != nil {
+		err = errors.Wrap(err, "PDF")
+		return
+	}
+	for index := 0; index < doc.NumPages() && index < MaxFrames; index++ {
+		var page image.Image
+		page, err = doc.Render(index, pdfDPI, maxPagePixels)
+		if err != nil {
+			err = errors.Wrapf(err, "PDF page %d", index)
+			return
+		}
+		err = fn(index, page)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// tiffPages decodes every image file directory of TIFF image
+func tiffPages(file []byte, fn func(index int, frame image.Image) error) (err error) {
+	return tiffDirectories(file, func(index int, page []byte) (err error) {
+		img, err := tiff.Decode(bytes.NewReader(page))
+		if err != nil {
+			err = errors.Wrapf(err, "TIFF page %d", index)
+			return
+		}
+		return fn(index, img)
+	})
+}
+
+// tiffDirectories calls fn on TIFF image of every image file directory in file,
+// by pointing the header to each of them in turn.
+func tiffDirectories(file []byte, fn func(index int, page []byte) error) (err error) {
+	if len(file) < 8 {
+		err = errors.New("TIFF: header is truncated")
+		return
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if file[0] == 'M' {
+		order = binary.BigEndian
+	}
+
+	visited := make(map[uint32]bool)
+	page := make([]byte, len(file))
+	copy(page, file)
+	for index, offset := 0, order.Uint32(file[4:8]); offset != 0 && index < MaxFrames; index++ {
+		if visited[offset] || uint64(offset)+2 > uint64(len(file)) {
+			break
+		}
+		visited[offset] = true
+
+		order.PutUint32(page[4:8], offset)
+		err = fn(index, page)
+		if err != nil {
+			return
+		}
+
+		entries := uint64(order.Uint16(file[offset : offset+2]))
+		next := uint64(offset) + 2 + entries*12
+		if next+4 > uint64(len(file)) {
+			break
+		}
+		offset = order.Uint32(file[next : next+4])
+	}
+	return
+}
+
+// gifFrames composes frames of GIF animation as they are shown,
+// decoding one frame at a time.
+//
+// Frames are composed on white, in case transparent pixels
+// are taken as black when scanning.
+func gifFrames(file []byte, fn func(index int, frame image.Image) error) (err error) {
+	config, err := gif.DecodeConfig(bytes.NewReader(file))
+	if err != nil {
+		err = errors.Wrap(err, "GIF")
+		return
+	}
+
+	// frames lie in logical screen, or gif refuses to decode
+	bounds := image.Rect(0, 0, config.Width, config.Height)
+	canvas := image.NewRGBA(bounds)
+	draw.Draw(canvas, bounds, image.White, image.ZP, draw.Src)
+
+	return gifBlocks(file, func(index int, block []byte) (err error) {
+		animation, err := gif.DecodeAll(bytes.NewReader(block))
+		if err != nil || len(animation.Image) == 0 {
+			err = errors.Wrapf(err, "GIF frame %d", index)
+			return
+		}
+		frame := animation.Image[0]
+		var disposal byte
+		if len(animation.Disposal) > 0 {
+			disposal = animation.Disposal[0]
+		}
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		err = fn(index, canvas)
+		if err != nil {
+			return
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.White, image.ZP, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+		return
+	})
+}
+
+// errGIFTruncated GIF data ends in the middle of a block
+var errGIFTruncated = errors.New("GIF: data is truncated")
+
+// gifBlocks calls fn on every frame of GIF animation in file, up to MaxFrames,
+// as a GIF image of its own made of the header, the global color table
+// and blocks of the frame, stopping at the first error of fn, which is returned as is.
+func gifBlocks(file []byte, fn func(index int, block []byte) error) (err error) {
+	// header and logical screen descriptor
+	pos := 13
+	if len(file) < pos {
+		err = errGIFTruncated
+		return
+	}
+	if file[10]&0x80 != 0 {
+		pos += 3 << (file[10]&0x07 + 1)
+	}
+	if pos > len(file) {
+		err = errGIFTruncated
+		return
+	}
+	head := file[:pos]
+
+	// graphic control extension of the next frame
+	var control []byte
+	for index := 0; pos < len(file) && index < MaxFrames; {
+		start := pos
+		switch file[pos] {
+		case 0x21:
+			// extension
+			end := skipSubBlocks(file, pos+2)
+			if end < 0 {
+				err = errGIFTruncated
+				return
+			}
+			if file[pos+1] == 0xf9 {
+				control = file[start:end]
+			}
+			pos = end
+		case 0x2c:
+			// image descriptor, local color table and image data
+			pos += 10
+			if pos > len(file) {
+				err = errGIFTruncated
+				return
+			}
+			if file[pos-1]&0x80 != 0 {
+				pos += 3 << (file[pos-1]&0x07 + 1)
+			}
+			// skips LZW minimum code size
+			end := skipSubBlocks(file, pos+1)
+			if end < 0 {
+				err = errGIFTruncated
+				return
+			}
+			block := make([]byte, 0, len(head)+len(control)+end-start+1)
+			block = append(block, head...)
+			block = append(block, control...)
+			block = append(block, file[start:end]...)
+			block = append(block, 0x3b)
+			err = fn(index, block)
+			if err != nil {
+				return
+			}
+			index++
+			control = nil
+			pos = end
+		case 0x3b:
+			// trailer
+			return
+		default:
+			err = errors.Errorf("GIF: unknown block %#x", file[pos])
+			return
+		}
+	}
+	return
+}
+
+// skipSubBlocks returns position after data sub-blocks starting at pos,
+// -1 if they are truncated.
+func skipSubBlocks(file []byte, pos int) int {
+	for pos < len(file) {
+		size := int(file[pos])
+		pos += 1 + size
+		if size == 0 {
+			return pos
+		}
+	}
+	return -1
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qrcode
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestCheckFilePixels(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		data      []byte
+		maxPixels int
+		err       error
+	}{
+		{name: "within", file: "qr.png", maxPixels: 148 * 148},
+		{name: "beyond", file: "qr.png", maxPixels: 148*148 - 1, err: ErrTooManyPixels},
+		{name: "frames within", file: "qr-frames.gif", maxPixels: 2 * 132 * 132},
+		{name: "frames beyond", file: "qr-frames.gif", maxPixels: 2*132*132 - 1, err: ErrTooManyPixels},
+		{name: "pdf without image", file: "qr.pdf", maxPixels: 1},
+		{
+			name:      "malformed pdf",
+			data:      []byte("%PDF-1.4\n1 0 obj << /Type /ObjStm /N 1 /First 4 /Length 9 >>\nstream\n2 0 true\nendstream\nendobj\n1 0 obj 5 endobj\n"),
+			maxPixels: 1,
+			err:       errors.New("any"),
+		},
+	}
+	for _, tt := range tests {
+		data := tt.data
+		if len(tt.file) > 0 {
+			var err error
+			data, err = ioutil.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := CheckFilePixels(data, tt.maxPixels)
+		switch {
+		case tt.err == nil && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.err == ErrTooManyPixels && errors.Cause(err) != ErrTooManyPixels:
+			t.Errorf("%s: err = %v, want %v", tt.name, err, ErrTooManyPixels)
+		case tt.err != nil && err == nil:
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
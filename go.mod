@@ -1,16 +1,46 @@
 module github.com/nanmu42/qrcode-api
 
+go 1.27.1
+
 require (
-	github.com/PeterCxy/gozbar v0.0.0-20151016114418-0b38584c8ebd
 	github.com/bearyinnovative/bearychat-go v0.0.0-20181023025336-2a589fab3c0d
+	github.com/boombuler/barcode v1.0.0
 	github.com/gin-gonic/gin v1.3.0
 	github.com/nanmu42/bearychat-go v0.0.0-20181029073754-89d18cb5fcf8
-	github.com/nanmu42/orly v1.0.1 // indirect
 	github.com/pelletier/go-toml v1.2.0
 	github.com/pkg/errors v0.8.0
-	github.com/skip2/go-qrcode v0.0.0-20171229120447-cf5f9fa2f0d8
 	github.com/spf13/viper v1.2.1
-	github.com/ugorji/go/codec v0.0.0-20181022190402-e5e69e061d4f // indirect
 	go.uber.org/zap v1.9.1
+	golang.org/x/image v0.0.0-20180708004352-c73c2afc3b81
+	golang.org/x/text v0.3.0
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0
 )
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/disintegration/imaging v1.5.1-0.20180915215809-32df9565b4e0 // indirect
+	github.com/fsnotify/fsnotify v1.4.7 // indirect
+	github.com/gin-contrib/sse v0.0.0-20170109093832-22d885f9ecc7 // indirect
+	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
+	github.com/golang/protobuf v1.2.0 // indirect
+	github.com/gorilla/websocket v1.4.0 // indirect
+	github.com/hashicorp/hcl v1.0.0 // indirect
+	github.com/magiconair/properties v1.8.0 // indirect
+	github.com/mattn/go-isatty v0.0.4 // indirect
+	github.com/mitchellh/mapstructure v1.0.0 // indirect
+	github.com/nanmu42/orly v1.0.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/spf13/afero v1.1.2 // indirect
+	github.com/spf13/cast v1.2.0 // indirect
+	github.com/spf13/jwalterweatherman v1.0.0 // indirect
+	github.com/spf13/pflag v1.0.2 // indirect
+	github.com/stretchr/objx v0.1.1 // indirect
+	github.com/stretchr/testify v1.2.2 // indirect
+	github.com/ugorji/go/codec v0.0.0-20181022190402-e5e69e061d4f // indirect
+	go.uber.org/atomic v1.3.2 // indirect
+	go.uber.org/multierr v1.1.0 // indirect
+	golang.org/x/sys v0.0.0-20180906133057-8cf3aee42992 // indirect
+	gopkg.in/check.v1 v0.0.0-20161208181325-20d25e280405 // indirect
+	gopkg.in/go-playground/validator.v8 v8.18.2 // indirect
+	gopkg.in/yaml.v2 v2.2.1 // indirect
+)
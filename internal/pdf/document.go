@@ -0,0 +1,353 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+// Package pdf reads PDF documents and rasterizes their pages,
+// drawing paths and images but not text, which is enough for bar codes.
+//
+// Objects are found by scanning the file instead of following
+// cross-reference tables, so damaged documents can be read as well.
+package pdf
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// maxPages max pages of document to look into
+const maxPages = 10000
+
+var (
+	// ErrEncrypted document is encrypted
+	ErrEncrypted = errors.New("encrypted document is not supported")
+	// ErrNoPage document has no page, or is not a PDF document at all
+	ErrNoPage = errors.New("no page found in document")
+)
+
+// objectPattern matches the beginning of an indirect object
+var objectPattern = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// Document a PDF document
+type Document struct {
+	// objects by object number
+	objects map[int64]interface{}
+	pages   []page
+}
+
+// page a page with attributes inherited from page tree
+type page struct {
+	// contents stream, or array of streams
+	contents  interface{}
+	resources dict
+	// box visible region of page, as llx, lly, urx and ury
+	box [4]float64
+}
+
+// trailer dict of trailer or cross-reference stream
+type trailer struct {
+	offset int
+	dict   dict
+}
+
+// Open reads document in data
+func Open(data []byte) (doc *Document, err error) {
+	doc = &Document{
+		objects: make(map[int64]interface{}),
+	}
+
+	var (
+		trailers   []trailer
+		objStreams []int64
+	)
+	for pos := 0; pos < len(data); {
+		loc := objectPattern.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		start := pos + loc[0]
+		num, isInt := parseWord(data[pos+loc[2] : pos+loc[3]]).(int64)
+		l := &lexer{data: data, pos: pos + loc[1]}
+		obj, objErr := l.object(0)
+		if objErr != nil || !isInt {
+			pos = start + 1
+			continue
+		}
+		if d, ok := obj.(dict); ok {
+			if s, ok := readStream(l, d); ok {
+				obj = s
+				switch d["Type"] {
+				case name("ObjStm"):
+					objStreams = append(objStreams, num)
+				case name("XRef"):
+					trailers = append(trailers, trailer{offset: start, dict: d})
+				}
+			}
+		}
+		doc.objects[num] = obj
+		pos = l.pos
+	}
+
+	for pos := 0; ; {
+		index := bytes.Index(data[pos:], []byte("trailer"))
+		if index < 0 {
+			break
+		}
+		pos += index + len("trailer")
+		l := &lexer{data: data, pos: pos}
+		if d, ok := mustObject(l.object(0)).(dict); ok {
+			trailers = append(trailers, trailer{offset: pos, dict: d})
+		}
+	}
+
+	// objects written directly take precedence
+	compressed := make(map[int64]interface{})
+	for _, num := range objStreams {
+		// number may be taken by a later object which is not a stream
+		s, ok := doc.objects[num].(*stream)
+		if !ok {
+			continue
+		}
+		doc.readObjectStream(s, compressed)
+	}
+	for num, obj := range compressed {
+		if _, ok := doc.objects[num]; !ok {
+			doc.objects[num] = obj
+		}
+	}
+
+	// the last trailer is the latest update
+	sort.Slice(trailers, func(i, j int) bool {
+		return trailers[i].offset > trailers[j].offset
+	})
+	var root interface{}
+	for _, t := range trailers {
+		if _, ok := t.dict["Encrypt"]; ok {
+			err = ErrEncrypted
+			return
+		}
+		if root == nil {
+			root = t.dict["Root"]
+		}
+	}
+	catalog, ok := doc.resolve(root).(dict)
+	if !ok {
+		catalog = doc.findCatalog()
+	}
+
+	doc.walkPages(catalog["Pages"], nil, nil, make(map[int64]bool), 0)
+	if len(doc.pages) == 0 {
+		err = ErrNoPage
+		return
+	}
+	return
+}
+
+// NumPages returns count of pages
+func (doc *Document) NumPages() int {
+	return len(doc.pages)
+}
+
+// mustObject drops error of reading object
+func mustObject(obj interface{}, err error) interface{} {
+	if err != nil {
+		return nil
+	}
+	return obj
+}
+
+// readStream reads stream data after its dict d, if any
+func readStream(l *lexer, d dict) (s *stream, ok bool) {
+	saved := l.pos
+	l.skipSpace()
+	if !bytes.HasPrefix(l.data[l.pos:], []byte("stream")) {
+		l.pos = saved
+		return
+	}
+	l.pos += len("stream")
+	if bytes.HasPrefix(l.data[l.pos:], []byte("\r\n")) {
+		l.pos += 2
+	} else if !l.eof() && (l.data[l.pos] == '\n' || l.data[l.pos] == '\r') {
+		l.pos++
+	}
+	start := l.pos
+
+	// trust Length if it ends right before endstream
+	if length, isInt := d["Length"].(int64); isInt && length >= 0 && int64(start)+length <= int64(len(l.data)) {
+		end := start + int(length)
+		rest := bytes.TrimLeft(l.data[end:], "\x00\t\n\f\r ")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			l.pos = len(l.data) - len(rest) + len("endstream")
+			return &stream{dict: d, data: l.data[start:end]}, true
+		}
+	}
+
+	index := bytes.Index(l.data[start:], []byte("endstream"))
+	if index < 0 {
+		l.pos = saved
+		return
+	}
+	data := l.data[start : start+index]
+	if bytes.HasSuffix(data, []byte("\r\n")) {
+		data = data[:len(data)-2]
+	} else if bytes.HasSuffix(data, []byte("\n")) || bytes.HasSuffix(data, []byte("\r")) {
+		data = data[:len(data)-1]
+	}
+	l.pos = start + index + len("endstream")
+	return &stream{dict: d, data: data}, true
+}
+
+// readObjectStream reads objects compressed in s into objects
+func (doc *Document) readObjectStream(s *stream, objects map[int64]interface{}) {
+	data, _, err := doc.decodeStream(s)
+	if err != nil {
+		return
+	}
+	count, _ := doc.resolve(s.dict["N"]).(int64)
+	first, _ := doc.resolve(s.dict["First"]).(int64)
+	if first < 0 || first > int64(len(data)) {
+		return
+	}
+
+	header := &lexer{data: data[:first]}
+	for i := int64(0); i < count; i++ {
+		num, numErr := header.next(0)
+		offset, offsetErr := header.next(0)
+		n, ok1 := num.(int64)
+		o, ok2 := offset.(int64)
+		if numErr != nil || offsetErr != nil || !ok1 || !ok2 {
+			return
+		}
+		if o < 0 || first+o >= int64(len(data)) {
+			continue
+		}
+		l := &lexer{data: data, pos: int(first + o)}
+		obj, objErr := l.object(0)
+		if objErr != nil {
+			continue
+		}
+		objects[n] = obj
+	}
+}
+
+// findCatalog looks for document catalog when trailer is missing
+func (doc *Document) findCatalog() dict {
+	nums := make([]int64, 0, len(doc.objects))
+	for num := range doc.objects {
+		nums = append(nums, num)
+	}
+	sort.Slice(nums, func(i, j int) bool { return nums[i] < nums[j] })
+	for _, num := range nums {
+		if d, ok := doc.objects[num].(dict); ok && d["Type"] == name("Catalog") {
+			return d
+		}
+	}
+	return nil
+}
+
+// resolve follows references to the object
+func (doc *Document) resolve(obj interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		r, ok := obj.(ref)
+		if !ok {
+			return obj
+		}
+		obj = doc.objects[r.num]
+	}
+	return nil
+}
+
+// dict resolves obj as a dict, or stream's dict
+func (doc *Document) dict(obj interface{}) dict {
+	switch v := doc.resolve(obj).(type) {
+	case dict:
+		return v
+	case *stream:
+		return v.dict
+	}
+	return nil
+}
+
+// number resolves obj as a number
+func (doc *Document) number(obj interface{}) (value float64, ok bool) {
+	switch v := doc.resolve(obj).(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return
+}
+
+// box resolves obj as a rectangle
+func (doc *Document) box(obj interface{}) (box [4]float64, ok bool) {
+	items, isArray := doc.resolve(obj).(array)
+	if !isArray || len(items) != 4 {
+		return
+	}
+	for i, item := range items {
+		box[i], ok = doc.number(item)
+		if !ok {
+			return
+		}
+	}
+	if box[0] > box[2] {
+		box[0], box[2] = box[2], box[0]
+	}
+	if box[1] > box[3] {
+		box[1], box[3] = box[3], box[1]
+	}
+	ok = box[2] > box[0] && box[3] > box[1]
+	return
+}
+
+// walkPages collects pages in page tree node,
+// resources and box are inherited from ancestors.
+func (doc *Document) walkPages(node interface{}, resources dict, box *[4]float64, visited map[int64]bool, depth int) {
+	if len(doc.pages) >= maxPages || depth > maxNesting {
+		return
+	}
+	if r, ok := node.(ref); ok {
+		if visited[r.num] {
+			return
+		}
+		visited[r.num] = true
+	}
+	d := doc.dict(node)
+	if d == nil {
+		return
+	}
+
+	if res := doc.dict(d["Resources"]); res != nil {
+		resources = res
+	}
+	if b, ok := doc.box(d["MediaBox"]); ok {
+		box = &b
+	}
+	if b, ok := doc.box(d["CropBox"]); ok {
+		box = &b
+	}
+
+	kids, isTree := doc.resolve(d["Kids"]).(array)
+	if d["Type"] == name("Page") || (!isTree && d["Type"] != name("Pages")) {
+		p := page{
+			contents:  d["Contents"],
+			resources: resources,
+			// US Letter by default
+			box: [4]float64{0, 0, 612, 792},
+		}
+		if box != nil {
+			p.box = *box
+		}
+		doc.pages = append(doc.pages, p)
+		return
+	}
+	for _, kid := range kids {
+		doc.walkPages(kid, resources, box, visited, depth+1)
+	}
+}
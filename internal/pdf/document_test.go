@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package pdf
+
+import (
+	"testing"
+)
+
+// onePage is a document of one page with a black square
+const onePage = `%PDF-1.4
+1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj
+2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj
+3 0 obj << /Type /Page /Parent 2 0 R /MediaBox [0 0 72 72] /Contents 4 0 R >> endobj
+4 0 obj << /Length 18 >>
+stream
+0 g 18 18 36 36 re f
+endstream
+endobj
+trailer << /Root 1 0 R >>
+%%EOF
+`
+
+func TestOpen(t *testing.T) {
+	doc, err := Open([]byte(onePage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.NumPages() != 1 {
+		t.Fatalf("NumPages() = %d, want 1", doc.NumPages())
+	}
+	img, err := doc.Render(0, 72, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _, _ := img.At(36, 36).RGBA(); r != 0 {
+		t.Errorf("center of page is not black")
+	}
+	if r, _, _, _ := img.At(4, 4).RGBA(); r != 0xffff {
+		t.Errorf("corner of page is not white")
+	}
+}
+
+func TestOpenMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"not PDF", "GIF89a"},
+		{
+			"object stream redefined by plain object",
+			"1 0 obj << /Type /ObjStm /N 1 /First 4 /Length 9 >>\nstream\n2 0 true\nendstream\nendobj\n1 0 obj 5 endobj\n",
+		},
+		{
+			"object stream of garbage",
+			"1 0 obj << /Type /ObjStm /N 9 /First 99 /Length 3 >>\nstream\n1 x\nendstream\nendobj\n",
+		},
+		{
+			"catalog is not dict",
+			"1 0 obj 7 endobj\ntrailer << /Root 1 0 R >>\n",
+		},
+		{
+			"pages is a stream",
+			"1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n2 0 obj << /Length 1 /Kids 2 0 R >>\nstream\nx\nendstream\nendobj\n",
+		},
+		{
+			"page refers to itself",
+			"1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n2 0 obj << /Type /Pages /Kids [2 0 R 3 0 R] >> endobj\n" +
+				"3 0 obj << /Type /Page /Contents 3 0 R /Resources 3 0 R /MediaBox 3 0 R >> endobj\n",
+		},
+		{
+			"image of wrong types",
+			"1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n2 0 obj << /Type /Pages /Kids [3 0 R] >> endobj\n" +
+				"3 0 obj << /Type /Page /MediaBox [0 0 9 9] /Contents 4 0 R /Resources << /XObject << /I 5 0 R >> >> >> endobj\n" +
+				"4 0 obj << /Length 7 >>\nstream\n/I Do Q\nendstream\nendobj\n" +
+				"5 0 obj << /Subtype /Image /Width /W /Height [1] /ColorSpace [] /Filter 5 /Length 1 >>\nstream\nx\nendstream\nendobj\n",
+		},
+		{"truncated", onePage[:len(onePage)/2]},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if fatal := recover(); fatal != nil {
+					t.Errorf("%s: panic: %v", tt.name, fatal)
+				}
+			}()
+			doc, err := Open([]byte(tt.data))
+			if err != nil {
+				return
+			}
+			for i := 0; i < doc.NumPages(); i++ {
+				_, _ = doc.Render(i, 72, 1<<20)
+			}
+			_ = doc.ImageSizes(func(width, height int) error {
+				return nil
+			})
+		}()
+	}
+}
@@ -0,0 +1,283 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/lzw"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// maxStreamSize max size of decoded stream, against decompression bombs
+const maxStreamSize = 64 << 20
+
+// errStreamTooBig decoded stream is bigger than maxStreamSize
+var errStreamTooBig = errors.New("stream is too big")
+
+// imageFilters are left to image decoders
+var imageFilters = map[name]bool{
+	"DCTDecode":      true,
+	"JPXDecode":      true,
+	"CCITTFaxDecode": true,
+	"JBIG2Decode":    true,
+}
+
+// filterAbbreviations used by inline images
+var filterAbbreviations = map[name]name{
+	"AHx": "ASCIIHexDecode",
+	"A85": "ASCII85Decode",
+	"LZW": "LZWDecode",
+	"Fl":  "FlateDecode",
+	"RL":  "RunLengthDecode",
+	"CCF": "CCITTFaxDecode",
+	"DCT": "DCTDecode",
+}
+
+// decodeStream applies filters of s to its data,
+// stopping at image filter, which is returned as imageFilter.
+func (doc *Document) decodeStream(s *stream) (data []byte, imageFilter name, err error) {
+	filters := doc.resolve(s.dict["Filter"])
+	params := doc.resolve(s.dict["DecodeParms"])
+	if filters == nil {
+		filters = doc.resolve(s.dict["F"])
+		params = doc.resolve(s.dict["DP"])
+	}
+	if f, ok := filters.(name); ok {
+		filters, params = array{f}, array{params}
+	}
+	filterList, _ := filters.(array)
+	paramList, _ := params.(array)
+
+	data = s.data
+	for index, item := range filterList {
+		filter, _ := doc.resolve(item).(name)
+		if full, ok := filterAbbreviations[filter]; ok {
+			filter = full
+		}
+		if imageFilters[filter] {
+			imageFilter = filter
+			return
+		}
+		var param dict
+		if index < len(paramList) {
+			param = doc.dict(paramList[index])
+		}
+		data, err = doc.applyFilter(filter, param, data)
+		if err != nil {
+			err = errors.Wrap(err, string(filter))
+			return
+		}
+	}
+	return
+}
+
+// applyFilter decodes data by filter
+func (doc *Document) applyFilter(filter name, param dict, data []byte) (out []byte, err error) {
+	switch filter {
+	case "FlateDecode":
+		out, err = inflate(data)
+	case "LZWDecode":
+		out, err = readLimited(lzw.NewReader(bytes.NewReader(data), lzw.MSB, 8))
+	case "ASCIIHexDecode":
+		end := bytes.IndexByte(data, '>')
+		if end >= 0 {
+			data = data[:end]
+		}
+		out = decodeHex(data)
+		return
+	case "ASCII85Decode":
+		out = decodeASCII85(data)
+		return
+	case "RunLengthDecode":
+		out, err = decodeRunLength(data)
+		return
+	default:
+		err = errors.New("unsupported filter")
+		return
+	}
+	if err != nil {
+		return
+	}
+	return doc.unpredict(param, out)
+}
+
+// readLimited reads r up to maxStreamSize,
+// keeping what is read if r is truncated.
+func readLimited(r io.Reader) (out []byte, err error) {
+	out, err = ioutil.ReadAll(io.LimitReader(r, maxStreamSize+1))
+	if len(out) > maxStreamSize {
+		out, err = nil, errStreamTooBig
+		return
+	}
+	if err != nil && len(out) > 0 {
+		err = nil
+	}
+	return
+}
+
+// inflate decodes zlib data, or deflate data without zlib header
+func inflate(data []byte) (out []byte, err error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return readLimited(flate.NewReader(bytes.NewReader(data)))
+	}
+	defer zr.Close()
+	return readLimited(zr)
+}
+
+// unpredict reverses PNG or TIFF predictor
+func (doc *Document) unpredict(param dict, data []byte) (out []byte, err error) {
+	predictor, _ := doc.number(param["Predictor"])
+	if predictor < 2 {
+		return data, nil
+	}
+	colors, bpc, columns := 1.0, 8.0, 1.0
+	if v, ok := doc.number(param["Colors"]); ok && v >= 1 && v <= 32 {
+		colors = v
+	}
+	if v, ok := doc.number(param["BitsPerComponent"]); ok && v >= 1 && v <= 16 {
+		bpc = v
+	}
+	if v, ok := doc.number(param["Columns"]); ok && v >= 1 && v <= 1<<20 {
+		columns = v
+	}
+	pixelBytes := (int(colors)*int(bpc) + 7) / 8
+	rowBytes := (int(colors)*int(bpc)*int(columns) + 7) / 8
+
+	if predictor == 2 {
+		// TIFF predictor, 8 bits per component only
+		if bpc != 8 {
+			return data, nil
+		}
+		for row := 0; row+rowBytes <= len(data); row += rowBytes {
+			for i := row + pixelBytes; i < row+rowBytes; i++ {
+				data[i] += data[i-pixelBytes]
+			}
+		}
+		return data, nil
+	}
+
+	// PNG predictors, every row starts with its filter type
+	out = make([]byte, 0, len(data)/(rowBytes+1)*rowBytes)
+	previous := make([]byte, rowBytes)
+	for pos := 0; pos+rowBytes+1 <= len(data); pos += rowBytes + 1 {
+		filterType := data[pos]
+		row := data[pos+1 : pos+1+rowBytes]
+		for i := range row {
+			var left, upLeft byte
+			if i >= pixelBytes {
+				left, upLeft = row[i-pixelBytes], previous[i-pixelBytes]
+			}
+			up := previous[i]
+			switch filterType {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			}
+		}
+		out = append(out, row...)
+		previous = row
+	}
+	return
+}
+
+// paeth predictor of PNG
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// decodeASCII85 decodes ASCII base-85 data up to ~>
+func decodeASCII85(data []byte) (out []byte) {
+	var (
+		group uint32
+		count int
+	)
+loop:
+	for _, c := range data {
+		switch {
+		case c == '~':
+			break loop
+		case c == 'z' && count == 0:
+			out = append(out, 0, 0, 0, 0)
+		case c >= '!' && c <= 'u':
+			group = group*85 + uint32(c-'!')
+			count++
+			if count == 5 {
+				out = append(out, byte(group>>24), byte(group>>16), byte(group>>8), byte(group))
+				group, count = 0, 0
+			}
+		}
+	}
+	if count > 1 {
+		// pads partial group with u
+		for i := count; i < 5; i++ {
+			group = group*85 + 84
+		}
+		for i := 0; i < count-1; i++ {
+			out = append(out, byte(group>>uint(24-8*i)))
+		}
+	}
+	return
+}
+
+// decodeRunLength decodes RunLengthDecode data
+func decodeRunLength(data []byte) (out []byte, err error) {
+	for pos := 0; pos < len(data); {
+		length := int(data[pos])
+		pos++
+		switch {
+		case length == 128:
+			return
+		case length < 128:
+			end := pos + length + 1
+			if end > len(data) {
+				end = len(data)
+			}
+			out = append(out, data[pos:end]...)
+			pos = end
+		default:
+			if pos >= len(data) {
+				return
+			}
+			for i := 0; i < 257-length; i++ {
+				out = append(out, data[pos])
+			}
+			pos++
+		}
+		if len(out) > maxStreamSize {
+			err = errStreamTooBig
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,504 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+)
+
+// maxImagePixels max pixels of an image in document
+const maxImagePixels = 1 << 24
+
+// colorSpace families, as far as rendering goes
+const (
+	familyGray       = "DeviceGray"
+	familyRGB        = "DeviceRGB"
+	familyCMYK       = "DeviceCMYK"
+	familyIndexed    = "Indexed"
+	familySeparation = "Separation"
+	familyLab        = "Lab"
+	familyPattern    = "Pattern"
+)
+
+// colorSpace converts color components to color
+type colorSpace struct {
+	family     string
+	components int
+	// base, hival and lookup of indexed color space
+	base   *colorSpace
+	hival  int
+	lookup []byte
+}
+
+// device color spaces
+var (
+	deviceGray = &colorSpace{family: familyGray, components: 1}
+	deviceRGB  = &colorSpace{family: familyRGB, components: 3}
+	deviceCMYK = &colorSpace{family: familyCMYK, components: 4}
+	lab        = &colorSpace{family: familyLab, components: 3}
+	pattern    = &colorSpace{family: familyPattern}
+)
+
+// colorSpace resolves color space obj, nil if it is unknown
+func (doc *Document) colorSpace(obj interface{}, resources dict, depth int) *colorSpace {
+	if depth > 4 {
+		return nil
+	}
+	obj = doc.resolve(obj)
+	if n, ok := obj.(name); ok {
+		switch n {
+		case "DeviceGray", "G", "CalGray":
+			return deviceGray
+		case "DeviceRGB", "RGB", "CalRGB":
+			return deviceRGB
+		case "DeviceCMYK", "CMYK":
+			return deviceCMYK
+		case "Pattern":
+			return pattern
+		}
+		if named, ok := doc.dict(resources["ColorSpace"])[n]; ok {
+			return doc.colorSpace(named, resources, depth+1)
+		}
+		return nil
+	}
+
+	items, _ := obj.(array)
+	if len(items) == 0 {
+		return nil
+	}
+	family, _ := doc.resolve(items[0]).(name)
+	switch family {
+	case "CalGray":
+		return deviceGray
+	case "CalRGB":
+		return deviceRGB
+	case "Lab":
+		return lab
+	case "Pattern":
+		return pattern
+	case "ICCBased":
+		if len(items) < 2 {
+			return nil
+		}
+		profile := doc.dict(items[1])
+		count, _ := doc.number(profile["N"])
+		switch count {
+		case 1:
+			return deviceGray
+		case 3:
+			return deviceRGB
+		case 4:
+			return deviceCMYK
+		}
+		return doc.colorSpace(profile["Alternate"], resources, depth+1)
+	case "Separation", "DeviceN":
+		if len(items) < 2 {
+			return nil
+		}
+		components := 1
+		if names, ok := doc.resolve(items[1]).(array); ok && family == "DeviceN" {
+			components = len(names)
+		}
+		if components < 1 || components > 32 {
+			return nil
+		}
+		return &colorSpace{family: familySeparation, components: components}
+	case "Indexed", "I":
+		if len(items) < 4 {
+			return nil
+		}
+		base := doc.colorSpace(items[1], resources, depth+1)
+		hival, ok := doc.number(items[2])
+		if base == nil || base.family == familyIndexed || base.family == familyPattern || !ok || hival < 0 || hival > 255 {
+			return nil
+		}
+		space := &colorSpace{family: familyIndexed, components: 1, base: base, hival: int(hival)}
+		switch lookup := doc.resolve(items[3]).(type) {
+		case string:
+			space.lookup = []byte(lookup)
+		case *stream:
+			space.lookup, _, _ = doc.decodeStream(lookup)
+		}
+		return space
+	}
+	return nil
+}
+
+// initial color components set by cs, which is black or the first index
+func (cs *colorSpace) initial() []float64 {
+	values := make([]float64, cs.components)
+	switch cs.family {
+	case familyCMYK:
+		values[3] = 1
+	case familySeparation:
+		for i := range values {
+			values[i] = 1
+		}
+	}
+	return values
+}
+
+// colorOf converts operands of sc and scn to color,
+// which is transparent for patterns.
+func (cs *colorSpace) colorOf(operands []interface{}) color.NRGBA {
+	values := allNumbers(operands)
+	if cs.family == familyPattern || len(values) < cs.components {
+		return color.NRGBA{}
+	}
+	return cs.color(values[:cs.components])
+}
+
+// color converts color components to color,
+// components are between 0 and 1 except for indexed color space.
+func (cs *colorSpace) color(values []float64) color.NRGBA {
+	switch cs.family {
+	case familyGray:
+		v := channel(values[0])
+		return color.NRGBA{R: v, G: v, B: v, A: 0xff}
+	case familyRGB:
+		return color.NRGBA{R: channel(values[0]), G: channel(values[1]), B: channel(values[2]), A: 0xff}
+	case familyCMYK:
+		k := 1 - clamp(values[3])
+		return color.NRGBA{
+			R: channel((1 - clamp(values[0])) * k),
+			G: channel((1 - clamp(values[1])) * k),
+			B: channel((1 - clamp(values[2])) * k),
+			A: 0xff,
+		}
+	case familySeparation:
+		// tints of inks, in which full tint is dark
+		var tint float64
+		for _, v := range values {
+			tint = math.Max(tint, clamp(v))
+		}
+		v := channel(1 - tint)
+		return color.NRGBA{R: v, G: v, B: v, A: 0xff}
+	case familyLab:
+		v := channel(values[0] / 100)
+		return color.NRGBA{R: v, G: v, B: v, A: 0xff}
+	case familyIndexed:
+		index := int(math.Max(0, math.Min(float64(cs.hival), values[0])))
+		n := cs.base.components
+		if (index+1)*n > len(cs.lookup) {
+			return color.NRGBA{A: 0xff}
+		}
+		base := make([]float64, n)
+		for i := range base {
+			base[i] = float64(cs.lookup[index*n+i]) / 0xff
+		}
+		return cs.base.color(base)
+	}
+	return color.NRGBA{}
+}
+
+// clamp limits v between 0 and 1
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// channel converts v between 0 and 1 to color channel
+func channel(v float64) uint8 {
+	return uint8(clamp(v)*0xff + 0.5)
+}
+
+// imageKeys full names of inline image keys
+var imageKeys = map[name]name{
+	"W":   "Width",
+	"H":   "Height",
+	"BPC": "BitsPerComponent",
+	"CS":  "ColorSpace",
+	"D":   "Decode",
+	"DP":  "DecodeParms",
+	"F":   "Filter",
+	"IM":  "ImageMask",
+	"I":   "Interpolate",
+}
+
+// inlineImage reads inline image after BI and draws it
+func (r *renderer) inlineImage(l *lexer, resources dict) {
+	d := make(dict)
+	for {
+		key, err := l.object(0)
+		if err != nil {
+			return
+		}
+		if key == keyword("ID") {
+			break
+		}
+		k, ok := key.(name)
+		if !ok {
+			continue
+		}
+		value, err := l.object(0)
+		if err != nil {
+			return
+		}
+		if full, ok := imageKeys[k]; ok {
+			k = full
+		}
+		d[k] = value
+	}
+	// a single white-space after ID
+	if !l.eof() && isSpace(l.data[l.pos]) {
+		l.pos++
+	}
+	start := l.pos
+
+	// unfiltered data has a known length
+	length := -1
+	if d["Filter"] == nil {
+		width, _ := r.doc.number(d["Width"])
+		height, _ := r.doc.number(d["Height"])
+		bpc, _ := r.doc.number(d["BitsPerComponent"])
+		components := 1
+		if mask, _ := d["ImageMask"].(bool); mask {
+			bpc = 1
+		} else if space := r.doc.colorSpace(d["ColorSpace"], resources, 0); space != nil {
+			components = space.components
+		}
+		if width > 0 && height > 0 && width*height <= maxImagePixels && bpc > 0 && bpc <= 16 {
+			length = int(height) * ((int(width)*components*int(bpc) + 7) / 8)
+		}
+	}
+	if length < 0 || start+length > len(l.data) {
+		// looking for EI between white-spaces
+		length = len(l.data) - start
+		for pos := start; pos+1 < len(l.data); pos++ {
+			if l.data[pos] == 'E' && l.data[pos+1] == 'I' && pos > start && isSpace(l.data[pos-1]) &&
+				(pos+2 == len(l.data) || isSpace(l.data[pos+2])) {
+				length = pos - 1 - start
+				break
+			}
+		}
+	}
+	l.pos = start + length
+
+	// skips to EI
+	for !l.eof() {
+		obj, err := l.next(0)
+		if err != nil || obj == keyword("EI") {
+			break
+		}
+	}
+	r.drawImage(&stream{dict: d, data: l.data[start : start+length]}, resources)
+}
+
+// drawImage draws image XObject or inline image,
+// which is ignored if it can not be decoded.
+func (r *renderer) drawImage(s *stream, resources dict) {
+	if r.work > maxWork {
+		return
+	}
+	img, err := r.decodeImage(s, resources)
+	if err != nil {
+		return
+	}
+
+	// image space is a unit square, with its first row at the top
+	ctm := r.state.ctm
+	bounds := img.Bounds()
+	w, h := float64(bounds.Dx()), float64(bounds.Dy())
+	if math.Abs(ctm[0]*ctm[3]-ctm[1]*ctm[2]) < 1e-9 {
+		return
+	}
+	transform := f64.Aff3{
+		ctm[0] / w, -ctm[2] / h, ctm[2] + ctm[4],
+		ctm[1] / w, -ctm[3] / h, ctm[3] + ctm[5],
+	}
+	corners := make([]point, 0, 4)
+	for _, corner := range [][2]float64{{0, 0}, {0, 1}, {1, 0}, {1, 1}} {
+		corners = append(corners, ctm.apply(corner[0], corner[1]))
+	}
+	painted := r.bounds([][]point{corners}, 1)
+	r.work += bounds.Dx()*bounds.Dy() + painted.Dx()*painted.Dy()
+	if r.work > maxWork {
+		return
+	}
+
+	// keeps pixels sharp when scaling up
+	var interpolator xdraw.Transformer = xdraw.ApproxBiLinear
+	if math.Abs(transform[0]*transform[4]-transform[1]*transform[3]) >= 1 {
+		interpolator = xdraw.NearestNeighbor
+	}
+	interpolator.Transform(r.dst, transform, img, bounds, xdraw.Over, nil)
+}
+
+// decodeImage decodes image in s
+func (r *renderer) decodeImage(s *stream, resources dict) (img image.Image, err error) {
+	d := s.dict
+	width, _ := r.doc.number(d["Width"])
+	height, _ := r.doc.number(d["Height"])
+	if width < 1 || height < 1 || width*height > maxImagePixels {
+		err = errors.New("image size is invalid")
+		return
+	}
+
+	data, imageFilter, err := r.doc.decodeStream(s)
+	if err != nil {
+		return
+	}
+	switch imageFilter {
+	case "":
+	case "DCTDecode":
+		return decodeJPEG(data)
+	default:
+		err = errors.Errorf("%s is not supported", imageFilter)
+		return
+	}
+
+	bpc, _ := r.doc.number(d["BitsPerComponent"])
+	mask, _ := r.doc.resolve(d["ImageMask"]).(bool)
+	if mask {
+		bpc = 1
+	}
+	if bpc != 1 && bpc != 2 && bpc != 4 && bpc != 8 && bpc != 16 {
+		err = errors.New("bits per component is invalid")
+		return
+	}
+
+	space := deviceGray
+	if !mask {
+		space = r.doc.colorSpace(d["ColorSpace"], resources, 0)
+		if space == nil || space.family == familyPattern {
+			err = errors.New("color space is not supported")
+			return
+		}
+	}
+
+	// maps samples to components by Decode
+	maxSample := math.Exp2(bpc) - 1
+	decode := make([]float64, 2*space.components)
+	for i := 0; i < space.components; i++ {
+		decode[2*i+1] = 1
+		if space.family == familyIndexed {
+			decode[2*i+1] = maxSample
+		}
+	}
+	if items, ok := r.doc.resolve(d["Decode"]).(array); ok && len(items) == len(decode) {
+		for i, item := range items {
+			if v, ok := r.doc.number(item); ok {
+				decode[i] = v
+			}
+		}
+	}
+
+	w, h, bits := int(width), int(height), uint(bpc)
+	rowBytes := (w*space.components*int(bits) + 7) / 8
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	values := make([]float64, space.components)
+	paint := r.state.fill
+	for y := 0; y < h && (y+1)*rowBytes <= len(data); y++ {
+		row := data[y*rowBytes : (y+1)*rowBytes]
+		bit := uint(0)
+		for x := 0; x < w; x++ {
+			for i := range values {
+				var sample uint
+				if bits == 16 {
+					sample = uint(row[bit/8])<<8 | uint(row[bit/8+1])
+				} else {
+					sample = uint(row[bit/8]>>(8-bits-bit%8)) & (1<<bits - 1)
+				}
+				bit += bits
+				values[i] = decode[2*i] + float64(sample)*(decode[2*i+1]-decode[2*i])/maxSample
+			}
+			if mask {
+				// stencil paints where sample decodes to 0
+				if values[0] < 0.5 {
+					dst.SetNRGBA(x, y, paint)
+				}
+				continue
+			}
+			dst.SetNRGBA(x, y, space.color(values))
+		}
+	}
+	img = dst
+	return
+}
+
+// decodeJPEG decodes JPEG image in data,
+// whose size in header may differ from the one in image dict.
+func decodeJPEG(data []byte) (img image.Image, err error) {
+	config, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	if int64(config.Width)*int64(config.Height) > maxImagePixels {
+		err = errors.New("image size is invalid")
+		return
+	}
+	return jpeg.Decode(bytes.NewReader(data))
+}
+
+// ImageSizes calls fn on width and height of every image XObject in document,
+// as declared in its dict and, for JPEG, in its header, without decoding it,
+// stopping at the first error of fn, which is returned as is.
+//
+// Inline images are left out, which are decoded no larger than 4096x4096 pixels anyway.
+func (doc *Document) ImageSizes(fn func(width, height int) error) (err error) {
+	nums := make([]int64, 0, len(doc.objects))
+	for num := range doc.objects {
+		nums = append(nums, num)
+	}
+	sort.Slice(nums, func(i, j int) bool {
+		return nums[i] < nums[j]
+	})
+
+	for _, num := range nums {
+		s, ok := doc.objects[num].(*stream)
+		if !ok || s.dict["Subtype"] != name("Image") {
+			continue
+		}
+		width, _ := doc.number(s.dict["Width"])
+		height, _ := doc.number(s.dict["Height"])
+		err = fn(int(math.Min(width, math.MaxInt32)), int(math.Min(height, math.MaxInt32)))
+		if err != nil {
+			return
+		}
+
+		if !doc.filteredBy(s, "DCTDecode") {
+			continue
+		}
+		data, imageFilter, decodeErr := doc.decodeStream(s)
+		if decodeErr != nil || imageFilter != "DCTDecode" {
+			continue
+		}
+		config, configErr := jpeg.DecodeConfig(bytes.NewReader(data))
+		if configErr != nil {
+			continue
+		}
+		err = fn(config.Width, config.Height)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// filteredBy tells whether filter is one of filters of s
+func (doc *Document) filteredBy(s *stream, filter name) bool {
+	filters := doc.resolve(s.dict["Filter"])
+	if f, ok := filters.(name); ok {
+		filters = array{f}
+	}
+	items, _ := filters.(array)
+	for _, item := range items {
+		if doc.resolve(item) == filter {
+			return true
+		}
+	}
+	return false
+}
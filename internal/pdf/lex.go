@@ -0,0 +1,362 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// objects in PDF are one of:
+// nil, bool, int64, float64, string, name, keyword, array, dict, ref and *stream
+type (
+	// name e.g. /Type
+	name string
+	// keyword e.g. obj, or operator in content stream
+	keyword string
+	// array e.g. [1 2 3]
+	array []interface{}
+	// dict e.g. << /Type /Page >>
+	dict map[name]interface{}
+	// ref indirect reference e.g. 1 0 R
+	ref struct {
+		num, gen int64
+	}
+	// stream dict with data, which is not decoded
+	stream struct {
+		dict dict
+		data []byte
+	}
+)
+
+// maxNesting max depth of arrays and dicts
+const maxNesting = 64
+
+// errSyntax malformed object
+var errSyntax = errors.New("syntax error")
+
+// lexer reads objects from data
+type lexer struct {
+	data []byte
+	pos  int
+}
+
+// isSpace tells whether c is white-space in PDF
+func isSpace(c byte) bool {
+	switch c {
+	case 0, '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+// isDelimiter tells whether c is delimiter in PDF
+func isDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+// isRegular tells whether c can be part of a name or keyword
+func isRegular(c byte) bool {
+	return !isSpace(c) && !isDelimiter(c)
+}
+
+// eof tells whether there is nothing left
+func (l *lexer) eof() bool {
+	return l.pos >= len(l.data)
+}
+
+// skipSpace skips white-spaces and comments
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\r' && l.data[l.pos] != '\n' {
+				l.pos++
+			}
+			continue
+		}
+		if !isSpace(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+// regular reads a run of regular characters
+func (l *lexer) regular() []byte {
+	start := l.pos
+	for l.pos < len(l.data) && isRegular(l.data[l.pos]) {
+		l.pos++
+	}
+	return l.data[start:l.pos]
+}
+
+// next reads an object, references are not recognized
+// and arrays and dicts are read as a whole.
+func (l *lexer) next(depth int) (obj interface{}, err error) {
+	if depth > maxNesting {
+		err = errSyntax
+		return
+	}
+	l.skipSpace()
+	if l.eof() {
+		err = errSyntax
+		return
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '/':
+		l.pos++
+		obj = name(unescapeName(l.regular()))
+	case c == '(':
+		obj, err = l.literal()
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		obj, err = l.dict(depth)
+	case c == '<':
+		obj, err = l.hex()
+	case c == '[':
+		l.pos++
+		obj, err = l.array(depth)
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		l.pos++
+		obj = keyword([]byte{c})
+	default:
+		word := l.regular()
+		if len(word) == 0 {
+			l.pos++
+			err = errSyntax
+			return
+		}
+		obj = parseWord(word)
+	}
+	return
+}
+
+// object reads an object, recognizing references
+func (l *lexer) object(depth int) (obj interface{}, err error) {
+	obj, err = l.next(depth)
+	if err != nil {
+		return
+	}
+	num, ok := obj.(int64)
+	if !ok {
+		return
+	}
+
+	// looking for "gen R"
+	saved := l.pos
+	gen, err := l.next(depth)
+	if g, ok := gen.(int64); err == nil && ok {
+		r, err := l.next(depth)
+		if r == keyword("R") && err == nil {
+			obj = ref{num: num, gen: g}
+			return obj, nil
+		}
+	}
+	l.pos = saved
+	return obj, nil
+}
+
+// array reads array after [
+func (l *lexer) array(depth int) (obj interface{}, err error) {
+	var items array
+	for {
+		var item interface{}
+		item, err = l.object(depth + 1)
+		if err != nil {
+			return
+		}
+		if item == keyword("]") {
+			break
+		}
+		items = append(items, item)
+	}
+	obj = items
+	return
+}
+
+// dict reads dict after <<
+func (l *lexer) dict(depth int) (obj interface{}, err error) {
+	d := make(dict)
+	for {
+		l.skipSpace()
+		if l.pos+1 < len(l.data) && l.data[l.pos] == '>' && l.data[l.pos+1] == '>' {
+			l.pos += 2
+			break
+		}
+		var key, value interface{}
+		key, err = l.next(depth + 1)
+		if err != nil {
+			return
+		}
+		k, ok := key.(name)
+		if !ok {
+			err = errSyntax
+			return
+		}
+		value, err = l.object(depth + 1)
+		if err != nil {
+			return
+		}
+		d[k] = value
+	}
+	obj = d
+	return
+}
+
+// literal reads string in parentheses
+func (l *lexer) literal() (obj interface{}, err error) {
+	var buf bytes.Buffer
+	l.pos++
+	nesting := 0
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			nesting++
+		case ')':
+			if nesting == 0 {
+				obj = buf.String()
+				return
+			}
+			nesting--
+		case '\\':
+			if l.eof() {
+				break
+			}
+			c = l.data[l.pos]
+			l.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if !l.eof() && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					value := int(c - '0')
+					for i := 0; i < 2 && !l.eof() && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						value = value*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(value)
+				}
+			}
+		}
+		buf.WriteByte(c)
+	}
+	err = errSyntax
+	return
+}
+
+// hex reads string in angle brackets
+func (l *lexer) hex() (obj interface{}, err error) {
+	l.pos++
+	end := bytes.IndexByte(l.data[l.pos:], '>')
+	if end < 0 {
+		err = errSyntax
+		return
+	}
+	obj = string(decodeHex(l.data[l.pos : l.pos+end]))
+	l.pos += end + 1
+	return
+}
+
+// decodeHex decodes hex digits, ignoring anything else
+func decodeHex(src []byte) []byte {
+	var (
+		out  []byte
+		half = -1
+	)
+	for _, c := range src {
+		var v int
+		switch {
+		case c >= '0' && c <= '9':
+			v = int(c - '0')
+		case c >= 'a' && c <= 'f':
+			v = int(c-'a') + 10
+		case c >= 'A' && c <= 'F':
+			v = int(c-'A') + 10
+		default:
+			continue
+		}
+		if half < 0 {
+			half = v
+			continue
+		}
+		out = append(out, byte(half<<4|v))
+		half = -1
+	}
+	if half >= 0 {
+		out = append(out, byte(half<<4))
+	}
+	return out
+}
+
+// unescapeName decodes #xx in name
+func unescapeName(raw []byte) string {
+	if bytes.IndexByte(raw, '#') < 0 {
+		return string(raw)
+	}
+	var out []byte
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '#' && i+2 < len(raw) {
+			if v := decodeHex(raw[i+1 : i+3]); len(v) == 1 {
+				out = append(out, v[0])
+				i += 2
+				continue
+			}
+		}
+		out = append(out, raw[i])
+	}
+	return string(out)
+}
+
+// parseWord parses number, boolean, null or keyword
+func parseWord(word []byte) interface{} {
+	switch string(word) {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	c := word[0]
+	if (c >= '0' && c <= '9') || c == '+' || c == '-' || c == '.' {
+		if i, err := strconv.ParseInt(string(word), 10, 64); err == nil {
+			return i
+		}
+		if f, err := strconv.ParseFloat(string(word), 64); err == nil {
+			return f
+		}
+		return float64(0)
+	}
+	return keyword(word)
+}
@@ -0,0 +1,643 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/pkg/errors"
+	"golang.org/x/image/vector"
+)
+
+const (
+	// maxOperators max operators executed when rendering a page
+	maxOperators = 1 << 22
+	// maxFormDepth max nesting of form XObjects
+	maxFormDepth = 8
+	// curveSegments lines a Bézier curve is flattened into
+	curveSegments = 16
+	// maxPathPoints max points of a path
+	maxPathPoints = 1 << 20
+	// maxWork max pixels decoded and painted when rendering a page
+	maxWork = 1 << 28
+)
+
+// errTooComplex page has too many operators
+var errTooComplex = errors.New("page is too complex")
+
+// matrix affine transformation [a b c d e f], as in PDF
+type matrix [6]float64
+
+// multiply returns transformation of m then n
+func (m matrix) multiply(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+// apply transforms point (x, y)
+func (m matrix) apply(x, y float64) point {
+	return point{
+		x: m[0]*x + m[2]*y + m[4],
+		y: m[1]*x + m[3]*y + m[5],
+	}
+}
+
+// point in device space
+type point struct {
+	x, y float64
+}
+
+// finite tells whether p is neither infinite nor NaN
+func (p point) finite() bool {
+	return !math.IsInf(p.x, 0) && !math.IsInf(p.y, 0) && !math.IsNaN(p.x) && !math.IsNaN(p.y)
+}
+
+// subpath a run of connected points
+type subpath struct {
+	points []point
+	closed bool
+}
+
+// graphicsState what q and Q save and restore
+type graphicsState struct {
+	ctm         matrix
+	fill        color.NRGBA
+	stroke      color.NRGBA
+	fillSpace   *colorSpace
+	strokeSpace *colorSpace
+	lineWidth   float64
+}
+
+// renderer draws content streams onto dst
+type renderer struct {
+	doc    *Document
+	dst    *image.RGBA
+	state  graphicsState
+	saved  []graphicsState
+	path   []subpath
+	raster vector.Rasterizer
+	// points in path
+	points int
+	// operators executed
+	operators int
+	// work pixels decoded and painted
+	work int
+	// depth nesting of form XObjects
+	depth int
+}
+
+// Render rasterizes page at index, counting from 0, in dpi,
+// and scales it down to no more than maxPixels pixels.
+//
+// Paths and images are drawn, while text, shadings and clipping are not.
+func (doc *Document) Render(index int, dpi float64, maxPixels int) (img *image.RGBA, err error) {
+	if index < 0 || index >= len(doc.pages) {
+		err = fmt.Errorf("page %d does not exist", index)
+		return
+	}
+	defer func() {
+		if fatal := recover(); fatal != nil {
+			err = fmt.Errorf("rendering error: %v", fatal)
+		}
+	}()
+
+	p := doc.pages[index]
+	scale := dpi / 72
+	width, height := (p.box[2]-p.box[0])*scale, (p.box[3]-p.box[1])*scale
+	if pixels := width * height; pixels > float64(maxPixels) {
+		shrink := math.Sqrt(float64(maxPixels) / pixels)
+		scale, width, height = scale*shrink, width*shrink, height*shrink
+	}
+	img = image.NewRGBA(image.Rect(0, 0, int(math.Max(1, width)), int(math.Max(1, height))))
+	draw.Draw(img, img.Bounds(), image.White, image.ZP, draw.Src)
+
+	r := &renderer{
+		doc: doc,
+		dst: img,
+		state: graphicsState{
+			// flips y axis, origin of page box at bottom-left
+			ctm:         matrix{scale, 0, 0, -scale, -p.box[0] * scale, p.box[3] * scale},
+			fill:        color.NRGBA{A: 0xff},
+			stroke:      color.NRGBA{A: 0xff},
+			fillSpace:   deviceGray,
+			strokeSpace: deviceGray,
+			lineWidth:   1,
+		},
+	}
+	err = r.run(doc.contents(p.contents), p.resources)
+	return
+}
+
+// contents concatenates content streams
+func (doc *Document) contents(obj interface{}) []byte {
+	obj = doc.resolve(obj)
+	if s, ok := obj.(*stream); ok {
+		obj = array{s}
+	}
+	items, _ := obj.(array)
+
+	var buf bytes.Buffer
+	for _, item := range items {
+		s, ok := doc.resolve(item).(*stream)
+		if !ok {
+			continue
+		}
+		data, imageFilter, err := doc.decodeStream(s)
+		if err != nil || imageFilter != "" {
+			continue
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// run executes operators in content with resources
+func (r *renderer) run(content []byte, resources dict) (err error) {
+	l := &lexer{data: content}
+	var operands []interface{}
+	for {
+		l.skipSpace()
+		if l.eof() {
+			return
+		}
+		pos := l.pos
+		obj, objErr := l.next(0)
+		if objErr != nil {
+			if l.pos == pos {
+				l.pos++
+			}
+			operands = operands[:0]
+			continue
+		}
+		op, ok := obj.(keyword)
+		if !ok {
+			if len(operands) >= 64 {
+				operands = operands[:0]
+			}
+			operands = append(operands, obj)
+			continue
+		}
+
+		r.operators++
+		if r.operators > maxOperators || r.work > maxWork {
+			err = errTooComplex
+			return
+		}
+		if op == "BI" {
+			r.inlineImage(l, resources)
+		} else {
+			err = r.execute(op, operands, resources)
+			if err != nil {
+				return
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+// numbers takes the last n operands as numbers
+func numbers(operands []interface{}, n int) (values []float64, ok bool) {
+	if len(operands) < n {
+		return
+	}
+	values = make([]float64, n)
+	for i, operand := range operands[len(operands)-n:] {
+		switch v := operand.(type) {
+		case int64:
+			values[i] = float64(v)
+		case float64:
+			values[i] = v
+		default:
+			return nil, false
+		}
+	}
+	ok = true
+	return
+}
+
+// allNumbers takes operands as numbers, until a non-number
+func allNumbers(operands []interface{}) (values []float64) {
+	for _, operand := range operands {
+		switch v := operand.(type) {
+		case int64:
+			values = append(values, float64(v))
+		case float64:
+			values = append(values, v)
+		default:
+			return
+		}
+	}
+	return
+}
+
+// execute executes operator op
+func (r *renderer) execute(op keyword, operands []interface{}, resources dict) (err error) {
+	s := &r.state
+	switch op {
+	case "q":
+		if len(r.saved) < 256 {
+			r.saved = append(r.saved, *s)
+		}
+	case "Q":
+		if len(r.saved) > 0 {
+			*s = r.saved[len(r.saved)-1]
+			r.saved = r.saved[:len(r.saved)-1]
+		}
+	case "cm":
+		if v, ok := numbers(operands, 6); ok {
+			s.ctm = matrix{v[0], v[1], v[2], v[3], v[4], v[5]}.multiply(s.ctm)
+		}
+	case "w":
+		if v, ok := numbers(operands, 1); ok {
+			s.lineWidth = v[0]
+		}
+
+	case "m":
+		if v, ok := numbers(operands, 2); ok {
+			r.addSubpath(subpath{points: []point{s.ctm.apply(v[0], v[1])}})
+		}
+	case "l":
+		if v, ok := numbers(operands, 2); ok {
+			r.lineTo(s.ctm.apply(v[0], v[1]))
+		}
+	case "c":
+		if v, ok := numbers(operands, 6); ok {
+			r.curveTo(s.ctm.apply(v[0], v[1]), s.ctm.apply(v[2], v[3]), s.ctm.apply(v[4], v[5]))
+		}
+	case "v":
+		if v, ok := numbers(operands, 4); ok {
+			if current, exists := r.currentPoint(); exists {
+				r.curveTo(current, s.ctm.apply(v[0], v[1]), s.ctm.apply(v[2], v[3]))
+			}
+		}
+	case "y":
+		if v, ok := numbers(operands, 4); ok {
+			end := s.ctm.apply(v[2], v[3])
+			r.curveTo(s.ctm.apply(v[0], v[1]), end, end)
+		}
+	case "h":
+		r.closePath()
+	case "re":
+		if v, ok := numbers(operands, 4); ok {
+			x, y, w, h := v[0], v[1], v[2], v[3]
+			r.addSubpath(subpath{
+				points: []point{
+					s.ctm.apply(x, y),
+					s.ctm.apply(x+w, y),
+					s.ctm.apply(x+w, y+h),
+					s.ctm.apply(x, y+h),
+				},
+				closed: true,
+			})
+		}
+
+	case "f", "F", "f*":
+		r.fillPath()
+		r.clearPath()
+	case "S":
+		r.strokePath()
+		r.clearPath()
+	case "s":
+		r.closePath()
+		r.strokePath()
+		r.clearPath()
+	case "B", "B*":
+		r.fillPath()
+		r.strokePath()
+		r.clearPath()
+	case "b", "b*":
+		r.closePath()
+		r.fillPath()
+		r.strokePath()
+		r.clearPath()
+	case "n":
+		r.clearPath()
+
+	case "g", "G", "rg", "RG", "k", "K":
+		space := map[keyword]*colorSpace{
+			"g": deviceGray, "G": deviceGray,
+			"rg": deviceRGB, "RG": deviceRGB,
+			"k": deviceCMYK, "K": deviceCMYK,
+		}[op]
+		v, ok := numbers(operands, space.components)
+		if !ok {
+			return
+		}
+		if op == "g" || op == "rg" || op == "k" {
+			s.fillSpace, s.fill = space, space.color(v)
+		} else {
+			s.strokeSpace, s.stroke = space, space.color(v)
+		}
+	case "cs", "CS":
+		if len(operands) == 0 {
+			return
+		}
+		space := r.doc.colorSpace(operands[len(operands)-1], resources, 0)
+		if space == nil {
+			space = deviceGray
+		}
+		if op == "cs" {
+			s.fillSpace, s.fill = space, space.color(space.initial())
+		} else {
+			s.strokeSpace, s.stroke = space, space.color(space.initial())
+		}
+	case "sc", "scn":
+		s.fill = s.fillSpace.colorOf(operands)
+	case "SC", "SCN":
+		s.stroke = s.strokeSpace.colorOf(operands)
+
+	case "Do":
+		if len(operands) == 0 {
+			return
+		}
+		key, _ := operands[len(operands)-1].(name)
+		xobjects := r.doc.dict(resources["XObject"])
+		xobject, ok := r.doc.resolve(xobjects[key]).(*stream)
+		if !ok {
+			return
+		}
+		switch xobject.dict["Subtype"] {
+		case name("Image"):
+			r.drawImage(xobject, resources)
+		case name("Form"):
+			err = r.drawForm(xobject, resources)
+		}
+	}
+	return
+}
+
+// currentPoint returns the last point of path
+func (r *renderer) currentPoint() (p point, ok bool) {
+	if len(r.path) == 0 {
+		return
+	}
+	last := r.path[len(r.path)-1]
+	if last.closed {
+		return last.points[0], true
+	}
+	return last.points[len(last.points)-1], true
+}
+
+// addSubpath appends sub to path, unless path is too long
+func (r *renderer) addSubpath(sub subpath) {
+	if r.points+len(sub.points) > maxPathPoints {
+		return
+	}
+	r.points += len(sub.points)
+	r.path = append(r.path, sub)
+}
+
+// clearPath ends path
+func (r *renderer) clearPath() {
+	r.path, r.points = nil, 0
+}
+
+// lineTo appends p to path,
+// starting a new subpath if the current one is closed.
+func (r *renderer) lineTo(p point) {
+	current, ok := r.currentPoint()
+	if !ok || r.points >= maxPathPoints {
+		return
+	}
+	last := &r.path[len(r.path)-1]
+	if last.closed {
+		r.addSubpath(subpath{points: []point{current}})
+		last = &r.path[len(r.path)-1]
+	}
+	last.points = append(last.points, p)
+	r.points++
+}
+
+// curveTo appends a cubic Bézier curve to path, as lines
+func (r *renderer) curveTo(c1, c2, end point) {
+	start, ok := r.currentPoint()
+	if !ok {
+		return
+	}
+	for i := 1; i <= curveSegments; i++ {
+		t := float64(i) / curveSegments
+		u := 1 - t
+		a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+		r.lineTo(point{
+			x: a*start.x + b*c1.x + c*c2.x + d*end.x,
+			y: a*start.y + b*c1.y + c*c2.y + d*end.y,
+		})
+	}
+}
+
+// closePath closes the current subpath
+func (r *renderer) closePath() {
+	if len(r.path) > 0 {
+		r.path[len(r.path)-1].closed = true
+	}
+}
+
+// bounds returns pixels covered by polygons, clipped by dst
+func (r *renderer) bounds(polygons [][]point, margin float64) image.Rectangle {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, polygon := range polygons {
+		for _, p := range polygon {
+			if !p.finite() {
+				continue
+			}
+			minX, minY = math.Min(minX, p.x), math.Min(minY, p.y)
+			maxX, maxY = math.Max(maxX, p.x), math.Max(maxY, p.y)
+		}
+	}
+	if minX > maxX {
+		return image.ZR
+	}
+	// keeps away from overflow of int
+	limit := float64(1 << 24)
+	rect := image.Rect(
+		int(math.Max(-limit, math.Floor(minX-margin))),
+		int(math.Max(-limit, math.Floor(minY-margin))),
+		int(math.Min(limit, math.Ceil(maxX+margin))),
+		int(math.Min(limit, math.Ceil(maxY+margin))),
+	)
+	return rect.Intersect(r.dst.Bounds())
+}
+
+// paint fills polygons with c by non-zero winding rule
+func (r *renderer) paint(polygons [][]point, margin float64, c color.NRGBA) {
+	if c.A == 0 {
+		return
+	}
+	rect := r.bounds(polygons, margin)
+	if rect.Empty() {
+		return
+	}
+	r.work += rect.Dx() * rect.Dy()
+	if r.work > maxWork {
+		return
+	}
+
+	r.raster.Reset(rect.Dx(), rect.Dy())
+	offsetX, offsetY := float64(rect.Min.X), float64(rect.Min.Y)
+	for _, polygon := range polygons {
+		// far away points cost the rasterizer a lot
+		polygon = clip(polygon, rect.Inset(-1))
+		if len(polygon) < 2 {
+			continue
+		}
+		r.raster.MoveTo(float32(polygon[0].x-offsetX), float32(polygon[0].y-offsetY))
+		for _, p := range polygon[1:] {
+			r.raster.LineTo(float32(p.x-offsetX), float32(p.y-offsetY))
+		}
+		r.raster.ClosePath()
+	}
+	r.raster.Draw(r.dst, rect, image.NewUniform(c), image.ZP)
+}
+
+// clip clips polygon by rect, in the way of Sutherland-Hodgman,
+// which keeps the area inside rect and winding of it.
+// Polygons with points not finite are dropped.
+func clip(polygon []point, rect image.Rectangle) []point {
+	for _, p := range polygon {
+		if !p.finite() {
+			return nil
+		}
+	}
+
+	minX, minY := float64(rect.Min.X), float64(rect.Min.Y)
+	maxX, maxY := float64(rect.Max.X), float64(rect.Max.Y)
+	edges := []struct {
+		inside    func(p point) bool
+		intersect func(a, b point) point
+	}{
+		{
+			func(p point) bool { return p.x >= minX },
+			func(a, b point) point { return point{minX, a.y + (b.y-a.y)*(minX-a.x)/(b.x-a.x)} },
+		},
+		{
+			func(p point) bool { return p.x <= maxX },
+			func(a, b point) point { return point{maxX, a.y + (b.y-a.y)*(maxX-a.x)/(b.x-a.x)} },
+		},
+		{
+			func(p point) bool { return p.y >= minY },
+			func(a, b point) point { return point{a.x + (b.x-a.x)*(minY-a.y)/(b.y-a.y), minY} },
+		},
+		{
+			func(p point) bool { return p.y <= maxY },
+			func(a, b point) point { return point{a.x + (b.x-a.x)*(maxY-a.y)/(b.y-a.y), maxY} },
+		},
+	}
+	for _, edge := range edges {
+		if len(polygon) == 0 {
+			return nil
+		}
+		input := polygon
+		polygon = make([]point, 0, len(input)+4)
+		previous := input[len(input)-1]
+		for _, current := range input {
+			switch {
+			case edge.inside(current):
+				if !edge.inside(previous) {
+					polygon = append(polygon, edge.intersect(previous, current))
+				}
+				polygon = append(polygon, current)
+			case edge.inside(previous):
+				polygon = append(polygon, edge.intersect(previous, current))
+			}
+			previous = current
+		}
+	}
+	return polygon
+}
+
+// fillPath fills path with fill color
+func (r *renderer) fillPath() {
+	polygons := make([][]point, 0, len(r.path))
+	for _, sub := range r.path {
+		polygons = append(polygons, sub.points)
+	}
+	r.paint(polygons, 1, r.state.fill)
+}
+
+// strokePath strokes path with stroke color,
+// as a quadrilateral per line without joins and caps.
+func (r *renderer) strokePath() {
+	// line width is scaled by the mean of axes
+	ctm := r.state.ctm
+	width := r.state.lineWidth * math.Sqrt(math.Abs(ctm[0]*ctm[3]-ctm[1]*ctm[2]))
+	if width < 1 {
+		width = 1
+	}
+	half := width / 2
+
+	var polygons [][]point
+	for _, sub := range r.path {
+		points := sub.points
+		if sub.closed && len(points) > 1 {
+			points = append(points[:len(points):len(points)], points[0])
+		}
+		for i := 1; i < len(points); i++ {
+			a, b := points[i-1], points[i]
+			dx, dy := b.x-a.x, b.y-a.y
+			length := math.Hypot(dx, dy)
+			if length == 0 {
+				continue
+			}
+			// normal of line, turning clockwise in device space
+			// keeps every quadrilateral in the same winding
+			nx, ny := -dy/length*half, dx/length*half
+			polygons = append(polygons, []point{
+				{a.x + nx, a.y + ny},
+				{b.x + nx, b.y + ny},
+				{b.x - nx, b.y - ny},
+				{a.x - nx, a.y - ny},
+			})
+		}
+	}
+	r.paint(polygons, half+1, r.state.stroke)
+}
+
+// drawForm runs content of form XObject
+func (r *renderer) drawForm(form *stream, resources dict) (err error) {
+	if r.depth >= maxFormDepth {
+		return
+	}
+	data, imageFilter, decodeErr := r.doc.decodeStream(form)
+	if decodeErr != nil || imageFilter != "" {
+		return
+	}
+	if res := r.doc.dict(form.dict["Resources"]); res != nil {
+		resources = res
+	}
+
+	saved, savedStack, savedPath := r.state, r.saved, r.path
+	if items, ok := r.doc.resolve(form.dict["Matrix"]).(array); ok {
+		var values []interface{}
+		for _, item := range items {
+			values = append(values, r.doc.resolve(item))
+		}
+		if v, ok := numbers(values, 6); ok && len(values) == 6 {
+			r.state.ctm = matrix{v[0], v[1], v[2], v[3], v[4], v[5]}.multiply(r.state.ctm)
+		}
+	}
+	r.saved = nil
+	r.clearPath()
+	r.depth++
+	err = r.run(data, resources)
+	r.depth--
+	r.state, r.saved, r.path = saved, savedStack, savedPath
+	return
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qr
+
+import (
+	"unicode/utf8"
+)
+
+// Parity is the Structured Append parity of data,
+// XOR of every byte.
+func Parity(data []byte) (parity byte) {
+	for _, b := range data {
+		parity ^= b
+	}
+	return
+}
+
+// EncodeAppend builds as few symbols as possible for data,
+// linking them with Structured Append when one is not enough.
+//
+// data is split on UTF-8 boundaries when possible.
+func EncodeAppend(data []byte, opts Options) (codes []*Code, err error) {
+	parts, ok := split(data, opts)
+	if !ok {
+		err = ErrTooLong
+		return
+	}
+	if len(parts) == 1 {
+		var code *Code
+		code, err = Encode([]Segment{MakeSegment(data)}, opts)
+		codes = []*Code{code}
+		return
+	}
+
+	parity := Parity(data)
+	for index, part := range parts {
+		partOpts := opts
+		partOpts.Append = &StructuredAppend{
+			Index:  index,
+			Total:  len(parts),
+			Parity: parity,
+		}
+		var code *Code
+		code, err = Encode([]Segment{MakeSegment(part)}, partOpts)
+		if err != nil {
+			return
+		}
+		codes = append(codes, code)
+	}
+	return
+}
+
+// split cuts data into fewest parts which fit in symbols per opts
+func split(data []byte, opts Options) (parts [][]byte, ok bool) {
+	for count := 1; count <= MaxAppendSymbols; count++ {
+		parts = cut(data, count)
+		ok = true
+		for _, part := range parts {
+			if !fits(MakeSegment(part), opts, count > 1) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return
+		}
+	}
+	parts = nil
+	return
+}
+
+// cut splits data into count parts of about the same length,
+// moving cuts backward to rune starts.
+func cut(data []byte, count int) (parts [][]byte) {
+	start := 0
+	for i := 1; i <= count; i++ {
+		end := len(data) * i / count
+		for end > start && end < len(data) && !utf8.RuneStart(data[end]) {
+			end--
+		}
+		parts = append(parts, data[start:end])
+		start = end
+	}
+	return
+}
+
+// fits tells whether segment fits in version and level of opts
+func fits(segment Segment, opts Options, withAppend bool) bool {
+	version := opts.Version
+	if version == 0 {
+		version = MaxVersion
+	}
+	if withAppend {
+		opts.Append = &StructuredAppend{}
+	}
+	used, ok := bitLength([]Segment{segment}, version)
+	return ok && len(opts.header())+used <= dataCodewords(version, opts.Level)*8
+}
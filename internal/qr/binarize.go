@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qr
+
+import (
+	"image"
+)
+
+const (
+	// blockSize side of block sharing a threshold
+	blockSize = 8
+	// minDynamicRange blocks of less contrast are taken as background
+	minDynamicRange = 24
+)
+
+// bitmap is a binarized image
+type bitmap struct {
+	width  int
+	height int
+	// dark pixels are true, row by row
+	dark []bool
+}
+
+// at tells whether pixel at x, y is dark, out of bounds are light
+func (b *bitmap) at(x, y int) bool {
+	if x < 0 || y < 0 || x >= b.width || y >= b.height {
+		return false
+	}
+	return b.dark[y*b.width+x]
+}
+
+// binarize thresholds img by local average of 5x5 blocks around,
+// which copes with shadow and gradient. Small images use a global threshold.
+func binarize(img *image.Gray) *bitmap {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	b := &bitmap{
+		width:  width,
+		height: height,
+		dark:   make([]bool, width*height),
+	}
+	pixel := func(x, y int) int {
+		return int(img.Pix[y*img.Stride+x])
+	}
+
+	if width < 5*blockSize || height < 5*blockSize {
+		low, high := 255, 0
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				low, high = minInt(low, pixel(x, y)), maxInt(high, pixel(x, y))
+			}
+		}
+		threshold := (low + high) / 2
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				b.dark[y*width+x] = pixel(x, y) <= threshold
+			}
+		}
+		return b
+	}
+
+	// black point of every block, the last row and column
+	// of blocks are aligned to the edge and overlap others.
+	subWidth, subHeight := (width+blockSize-1)/blockSize, (height+blockSize-1)/blockSize
+	blackPoints := make([][]int, subHeight)
+	for by := range blackPoints {
+		blackPoints[by] = make([]int, subWidth)
+		top := minInt(by*blockSize, height-blockSize)
+		for bx := range blackPoints[by] {
+			left := minInt(bx*blockSize, width-blockSize)
+			sum, low, high := 0, 255, 0
+			for y := top; y < top+blockSize; y++ {
+				for x := left; x < left+blockSize; x++ {
+					v := pixel(x, y)
+					sum += v
+					low, high = minInt(low, v), maxInt(high, v)
+				}
+			}
+			average := sum / (blockSize * blockSize)
+			if high-low <= minDynamicRange {
+				// flat block is likely background, lighter than dark modules
+				average = low / 2
+				if by > 0 && bx > 0 {
+					neighbors := (blackPoints[by-1][bx] + 2*blackPoints[by][bx-1] + blackPoints[by-1][bx-1]) / 4
+					if low < neighbors {
+						average = neighbors
+					}
+				}
+			}
+			blackPoints[by][bx] = average
+		}
+	}
+
+	for by := 0; by < subHeight; by++ {
+		top := minInt(by*blockSize, height-blockSize)
+		cy := minInt(maxInt(by, 2), subHeight-3)
+		for bx := 0; bx < subWidth; bx++ {
+			left := minInt(bx*blockSize, width-blockSize)
+			cx := minInt(maxInt(bx, 2), subWidth-3)
+			sum := 0
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					sum += blackPoints[cy+dy][cx+dx]
+				}
+			}
+			threshold := sum / 25
+			for y := top; y < top+blockSize; y++ {
+				for x := left; x < left+blockSize; x++ {
+					b.dark[y*width+x] = pixel(x, y) <= threshold
+				}
+			}
+		}
+	}
+	return b
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qr
+
+// bitBuffer is a sequence of bits, most significant bit first
+type bitBuffer []bool
+
+// append adds the lowest n bits of v
+func (b *bitBuffer) append(v uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, (v>>uint(i))&1 == 1)
+	}
+}
+
+// bytes packs bits into bytes, the last byte is padded with zeros
+func (b bitBuffer) bytes() []byte {
+	result := make([]byte, (len(b)+7)/8)
+	for i, bit := range b {
+		if bit {
+			result[i>>3] |= 0x80 >> uint(i&7)
+		}
+	}
+	return result
+}
+
+// pad fills b up to capacity bits with terminator of at most
+// terminator bits, zeros to byte boundary and then pad codewords.
+//
+// capacity may end in half a byte as in M1 and M3, which is left zero.
+func (b *bitBuffer) pad(capacity, terminator int) {
+	if rest := capacity - len(*b); terminator > rest {
+		terminator = rest
+	}
+	b.append(0, terminator)
+	if boundary := (8 - len(*b)%8) % 8; len(*b)+boundary <= capacity {
+		b.append(0, boundary)
+	}
+	for pad := uint32(0xec); len(*b)+8 <= capacity; pad ^= 0xec ^ 0x11 {
+		b.append(pad, 8)
+	}
+	b.append(0, capacity-len(*b))
+}
+
+// bitReader reads bytes as bits, most significant bit first
+type bitReader struct {
+	data []byte
+	// pos is index of next bit
+	pos int
+}
+
+// left is how many bits are not read yet
+func (r *bitReader) left() int {
+	return len(r.data)*8 - r.pos
+}
+
+// read takes next n bits, at most 32, ok is false if not enough bits left
+func (r *bitReader) read(n int) (v uint32, ok bool) {
+	if n > r.left() {
+		return
+	}
+	for i := 0; i < n; i++ {
+		v = v<<1 | uint32(r.data[r.pos>>3]>>uint(7-r.pos&7)&1)
+		r.pos++
+	}
+	ok = true
+	return
+}
+
+// zeros tells whether next n bits, or all bits left if fewer, are zero,
+// without reading them.
+func (r *bitReader) zeros(n int) bool {
+	for i := r.pos; i < r.pos+n && i < len(r.data)*8; i++ {
+		if r.data[i>>3]>>uint(7-i&7)&1 == 1 {
+			return false
+		}
+	}
+	return true
+}
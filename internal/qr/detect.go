@@ -0,0 +1,417 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qr
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+const (
+	// maxFinders most finder patterns to consider, the most confirmed ones
+	maxFinders = 48
+	// maxAttempts most groups of finder patterns to try decoding
+	maxAttempts = 256
+	// maxSkew how far, in ratio, three finder patterns may be off
+	// an isosceles right triangle, allowing perspective.
+	maxSkew = 0.3
+)
+
+// Found is a QR Code found in image
+type Found struct {
+	*Decoded
+	// Corners of the symbol, top-left, bottom-left, bottom-right and top-right,
+	// turning along with the symbol, in pixel of the image.
+	Corners [4]image.Point
+}
+
+// Scan finds and decodes QR Codes in img, symbols which can not be
+// decoded are left out.
+func Scan(img *image.Gray) (found []Found) {
+	b := binarize(img)
+	finders := findFinders(b)
+	sort.SliceStable(finders, func(i, j int) bool {
+		return finders[i].count > finders[j].count
+	})
+	if len(finders) > maxFinders {
+		finders = finders[:maxFinders]
+	}
+
+	min := img.Bounds().Min
+	used := make(map[*pattern]bool)
+	for attempt, group := range groupFinders(finders) {
+		if attempt >= maxAttempts {
+			break
+		}
+		if used[group[0]] || used[group[1]] || used[group[2]] {
+			continue
+		}
+		result, ok := decodeAt(b, group[0], group[1], group[2])
+		if !ok {
+			continue
+		}
+		for i := range result.Corners {
+			result.Corners[i] = result.Corners[i].Add(min)
+		}
+		found = append(found, result)
+		used[group[0]], used[group[1]], used[group[2]] = true, true, true
+	}
+	return
+}
+
+// groupFinders lists groups of three finder patterns which may make a symbol,
+// as top-left, top-right and bottom-left, the most likely first.
+func groupFinders(finders []*pattern) (groups [][3]*pattern) {
+	var skews []float64
+	for i := 0; i < len(finders); i++ {
+		for j := i + 1; j < len(finders); j++ {
+			for k := j + 1; k < len(finders); k++ {
+				a, b, c := finders[i], finders[j], finders[k]
+				low := math.Min(a.moduleSize, math.Min(b.moduleSize, c.moduleSize))
+				high := math.Max(a.moduleSize, math.Max(b.moduleSize, c.moduleSize))
+				if high > 1.5*low {
+					continue
+				}
+
+				topLeft, topRight, bottomLeft := orderFinders(a, b, c)
+				top, left := distance(topLeft, topRight), distance(topLeft, bottomLeft)
+				diagonal := distance(topRight, bottomLeft)
+				shorter := math.Min(top, left)
+				// finders of the smallest symbol are 14 modules apart,
+				// module size is overestimated in rows when rotated.
+				if shorter < 10*low {
+					continue
+				}
+				legSkew := math.Abs(top-left) / shorter
+				rightSkew := math.Abs(diagonal-math.Hypot(top, left)) / diagonal
+				if legSkew > maxSkew || rightSkew > maxSkew/2 {
+					continue
+				}
+				groups = append(groups, [3]*pattern{topLeft, topRight, bottomLeft})
+				skews = append(skews, legSkew+2*rightSkew)
+			}
+		}
+	}
+	order := make([]int, len(groups))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return skews[order[i]] < skews[order[j]]
+	})
+	sorted := make([][3]*pattern, len(groups))
+	for i, index := range order {
+		sorted[i] = groups[index]
+	}
+	return sorted
+}
+
+// orderFinders tells which finder is at which corner,
+// top-left is opposite the longest side, and the rest
+// follow clockwise in image coordinate, where y goes down.
+func orderFinders(a, b, c *pattern) (topLeft, topRight, bottomLeft *pattern) {
+	ab, bc, ac := distance(a, b), distance(b, c), distance(a, c)
+	switch {
+	case bc >= ab && bc >= ac:
+		topLeft, bottomLeft, topRight = a, b, c
+	case ac >= bc && ac >= ab:
+		topLeft, bottomLeft, topRight = b, a, c
+	default:
+		topLeft, bottomLeft, topRight = c, a, b
+	}
+	// cross product tells the turn from top right to bottom left
+	if (topRight.x-topLeft.x)*(bottomLeft.y-topLeft.y)-(topRight.y-topLeft.y)*(bottomLeft.x-topLeft.x) < 0 {
+		topRight, bottomLeft = bottomLeft, topRight
+	}
+	return
+}
+
+// decodeAt samples and decodes the symbol marked by three finder patterns
+func decodeAt(b *bitmap, topLeft, topRight, bottomLeft *pattern) (found Found, ok bool) {
+	moduleSize := (runModuleSize(b, topLeft, topRight) + runModuleSize(b, topLeft, bottomLeft)) / 2
+	if math.IsNaN(moduleSize) || moduleSize < 1 {
+		return
+	}
+	top := math.Floor(distance(topLeft, topRight)/moduleSize + 0.5)
+	left := math.Floor(distance(topLeft, bottomLeft)/moduleSize + 0.5)
+	estimated := int((top+left)/2) + 7
+
+	// nearest sizes of QR Code, which are 4n+1
+	var sizes []int
+	switch estimated & 3 {
+	case 0:
+		sizes = []int{estimated + 1, estimated - 3}
+	case 1:
+		sizes = []int{estimated, estimated + 4, estimated - 4}
+	case 2:
+		sizes = []int{estimated - 1, estimated + 3}
+	default:
+		sizes = []int{estimated - 2, estimated + 2}
+	}
+
+	for _, size := range sizes {
+		version := (size - 17) / 4
+		if version < MinVersion || version > MaxVersion {
+			continue
+		}
+
+		// bottom right is where alignment pattern lies,
+		// the grid is guessed by parallelogram if it is not found or misleading.
+		alignments := []*pattern{nil}
+		if version >= 2 {
+			correction := 1 - 3/float64(size-7)
+			x := topLeft.x + correction*(topRight.x-topLeft.x+bottomLeft.x-topLeft.x)
+			y := topLeft.y + correction*(topRight.y-topLeft.y+bottomLeft.y-topLeft.y)
+			var alignment *pattern
+			for allowance := 4.0; allowance <= 16 && alignment == nil; allowance *= 2 {
+				alignment = findAlignment(b, x, y, moduleSize, allowance)
+			}
+			if alignment != nil {
+				alignments = []*pattern{alignment, nil}
+			}
+		}
+
+		for _, alignment := range alignments {
+			t := gridTransform(topLeft, topRight, bottomLeft, alignment, size)
+			if found, ok = readGrid(b, t, size); ok {
+				return
+			}
+		}
+	}
+	return
+}
+
+// readGrid samples and decodes symbol of size on grid by t
+func readGrid(b *bitmap, t perspective, size int) (found Found, ok bool) {
+	modules, ok := sample(b, t, size)
+	if !ok {
+		return
+	}
+	corners := [4][2]float64{{0, 0}, {0, 1}, {1, 1}, {1, 0}}
+	decoded, err := Read(modules)
+	if err != nil {
+		// symbol may be mirrored, which is read transposed
+		decoded, err = Read(transpose(modules))
+		corners = [4][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+	}
+	if err != nil {
+		ok = false
+		return
+	}
+
+	found.Decoded = decoded
+	for i, corner := range corners {
+		x, y := t.apply(corner[0]*float64(size), corner[1]*float64(size))
+		found.Corners[i] = image.Pt(int(math.Floor(x+0.5)), int(math.Floor(y+0.5)))
+	}
+	return
+}
+
+// sample reads modules of symbol of size on grid by t,
+// ok is false if the grid runs off the image.
+func sample(b *bitmap, t perspective, size int) (modules [][]bool, ok bool) {
+	modules = make([][]bool, size)
+	for y := range modules {
+		modules[y] = make([]bool, size)
+		for x := range modules[y] {
+			px, py := t.apply(float64(x)+0.5, float64(y)+0.5)
+			if math.IsNaN(px) || math.IsNaN(py) || px < -1 || py < -1 || px > float64(b.width) || py > float64(b.height) {
+				return
+			}
+			modules[y][x] = b.at(int(px), int(py))
+		}
+	}
+	ok = true
+	return
+}
+
+// transpose swaps rows and columns of modules
+func transpose(modules [][]bool) [][]bool {
+	result := make([][]bool, len(modules))
+	for y := range result {
+		result[y] = make([]bool, len(modules))
+		for x := range result[y] {
+			result[y][x] = modules[x][y]
+		}
+	}
+	return result
+}
+
+// runModuleSize estimates module size by dark-light-dark runs
+// of finder pattern a looking toward b, and of b toward a.
+func runModuleSize(bm *bitmap, a, b *pattern) float64 {
+	one := runBothWays(bm, int(a.x), int(a.y), int(b.x), int(b.y))
+	other := runBothWays(bm, int(b.x), int(b.y), int(a.x), int(a.y))
+	switch {
+	case math.IsNaN(one):
+		return other / 7
+	case math.IsNaN(other):
+		return one / 7
+	}
+	return (one + other) / 14
+}
+
+// runBothWays measures dark-light-dark runs from x, y toward toX, toY,
+// and the opposite way, which spans the finder pattern at x, y.
+func runBothWays(b *bitmap, x, y, toX, toY int) float64 {
+	result := run(b, x, y, toX, toY)
+
+	// the opposite way, stopping at the edge of image
+	scale := 1.0
+	otherX := x - (toX - x)
+	if otherX < 0 {
+		scale = float64(x) / float64(x-otherX)
+		otherX = 0
+	} else if otherX >= b.width {
+		scale = float64(b.width-1-x) / float64(otherX-x)
+		otherX = b.width - 1
+	}
+	otherY := int(float64(y) - float64(toY-y)*scale)
+	scale = 1
+	if otherY < 0 {
+		scale = float64(y) / float64(y-otherY)
+		otherY = 0
+	} else if otherY >= b.height {
+		scale = float64(b.height-1-y) / float64(otherY-y)
+		otherY = b.height - 1
+	}
+	otherX = int(float64(x) + float64(otherX-x)*scale)
+
+	// the pixel at x, y is counted twice
+	return result + run(b, x, y, otherX, otherY) - 1
+}
+
+// run walks from x, y toward toX, toY by Bresenham's algorithm,
+// and returns the length of dark, light and dark runs,
+// NaN if they are not all there.
+func run(b *bitmap, x, y, toX, toY int) float64 {
+	steep := abs(toY-y) > abs(toX-x)
+	if steep {
+		x, y, toX, toY = y, x, toY, toX
+	}
+	dx, dy := abs(toX-x), abs(toY-y)
+	stepX, stepY := 1, 1
+	if x > toX {
+		stepX = -1
+	}
+	if y > toY {
+		stepY = -1
+	}
+
+	// 0 and 2 are in dark runs, 1 is in light run
+	state := 0
+	err := -dx / 2
+	for cx, cy := x, y; cx != toX+stepX; cx += stepX {
+		realX, realY := cx, cy
+		if steep {
+			realX, realY = cy, cx
+		}
+		if (state == 1) == b.at(realX, realY) {
+			if state == 2 {
+				return math.Hypot(float64(cx-x), float64(cy-y))
+			}
+			state++
+		}
+		err += dy
+		if err > 0 {
+			if cy == toY {
+				break
+			}
+			cy += stepY
+			err -= dx
+		}
+	}
+	if state == 2 {
+		// the one beyond is taken as light
+		return math.Hypot(float64(toX+stepX-x), float64(toY-y))
+	}
+	return math.NaN()
+}
+
+// perspective is a projective transform of the plane
+type perspective [3][3]float64
+
+// apply transforms point x, y
+func (t perspective) apply(x, y float64) (float64, float64) {
+	w := t[2][0]*x + t[2][1]*y + t[2][2]
+	return (t[0][0]*x + t[0][1]*y + t[0][2]) / w, (t[1][0]*x + t[1][1]*y + t[1][2]) / w
+}
+
+// multiply is the transform of applying u, then t
+func (t perspective) multiply(u perspective) (result perspective) {
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				result[i][j] += t[i][k] * u[k][j]
+			}
+		}
+	}
+	return
+}
+
+// inverse of t up to scale, by adjugate
+func (t perspective) inverse() (result perspective) {
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			a, b := (j+1)%3, (j+2)%3
+			c, d := (i+1)%3, (i+2)%3
+			result[i][j] = t[a][c]*t[b][d] - t[a][d]*t[b][c]
+		}
+	}
+	return
+}
+
+// squareToQuad maps unit square, corner (0, 0), (1, 0), (1, 1) and (0, 1),
+// onto quadrilateral of points in the same order.
+func squareToQuad(points [4][2]float64) perspective {
+	x0, y0 := points[0][0], points[0][1]
+	x1, y1 := points[1][0], points[1][1]
+	x2, y2 := points[2][0], points[2][1]
+	x3, y3 := points[3][0], points[3][1]
+	dx3, dy3 := x0-x1+x2-x3, y0-y1+y2-y3
+	if dx3 == 0 && dy3 == 0 {
+		return perspective{
+			{x1 - x0, x2 - x1, x0},
+			{y1 - y0, y2 - y1, y0},
+			{0, 0, 1},
+		}
+	}
+	dx1, dx2, dy1, dy2 := x1-x2, x3-x2, y1-y2, y3-y2
+	denominator := dx1*dy2 - dx2*dy1
+	g := (dx3*dy2 - dx2*dy3) / denominator
+	h := (dx1*dy3 - dx3*dy1) / denominator
+	return perspective{
+		{x1 - x0 + g*x1, x3 - x0 + h*x3, x0},
+		{y1 - y0 + g*y1, y3 - y0 + h*y3, y0},
+		{g, h, 1},
+	}
+}
+
+// quadToQuad maps quadrilateral from onto to
+func quadToQuad(from, to [4][2]float64) perspective {
+	return squareToQuad(to).multiply(squareToQuad(from).inverse())
+}
+
+// gridTransform maps module coordinate of symbol of size onto image,
+// by centers of finder patterns and alignment pattern.
+// Bottom right is guessed by parallelogram if alignment is nil.
+func gridTransform(topLeft, topRight, bottomLeft, alignment *pattern, size int) perspective {
+	far := float64(size) - 3.5
+	bottomRight := [2]float64{topRight.x - topLeft.x + bottomLeft.x, topRight.y - topLeft.y + bottomLeft.y}
+	sourceBottomRight := far
+	if alignment != nil {
+		bottomRight = [2]float64{alignment.x, alignment.y}
+		// center of the bottom right alignment pattern
+		sourceBottomRight = far - 3
+	}
+	return quadToQuad(
+		[4][2]float64{{3.5, 3.5}, {far, 3.5}, {sourceBottomRight, sourceBottomRight}, {3.5, far}},
+		[4][2]float64{{topLeft.x, topLeft.y}, {topRight.x, topRight.y}, bottomRight, {bottomLeft.x, bottomLeft.y}},
+	)
+}
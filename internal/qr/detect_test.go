@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qr
+
+import (
+	"image"
+	"image/draw"
+	"testing"
+)
+
+// render draws modules in scale pixels each with quiet zone of quiet modules,
+// turned clockwise by turns of 90 degrees after mirrored left to right if mirror.
+//
+// corners are where corners of symbol end up,
+// top-left, bottom-left, bottom-right and top-right.
+func render(modules [][]bool, scale, quiet, turns int, mirror bool) (img *image.Gray, corners [4]image.Point) {
+	size := len(modules)
+	side := (size + quiet*2) * scale
+	// maps pixel boundary from upright symbol to image
+	transform := func(x, y int) (int, int) {
+		if mirror {
+			x = side - x
+		}
+		for i := 0; i < turns; i++ {
+			x, y = side-y, x
+		}
+		return x, y
+	}
+
+	img = image.NewGray(image.Rect(0, 0, side, side))
+	for y := 0; y < side; y++ {
+		for x := 0; x < side; x++ {
+			mx, my := x/scale-quiet, y/scale-quiet
+			dark := mx >= 0 && my >= 0 && mx < size && my < size && modules[my][mx]
+			// pixel is the square right and below its boundary point
+			px, py := transform(x, y)
+			qx, qy := transform(x+1, y+1)
+			px, py = minInt(px, qx), minInt(py, qy)
+			if !dark {
+				img.Pix[py*img.Stride+px] = 0xff
+			}
+		}
+	}
+
+	low, high := quiet*scale, (quiet+size)*scale
+	for i, corner := range [4][2]int{{low, low}, {low, high}, {high, high}, {high, low}} {
+		x, y := transform(corner[0], corner[1])
+		corners[i] = image.Pt(x, y)
+	}
+	return
+}
+
+// near tells whether a and b are within tolerance pixels
+func near(a, b image.Point, tolerance int) bool {
+	return abs(a.X-b.X) <= tolerance && abs(a.Y-b.Y) <= tolerance
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		scale   int
+		turns   int
+		mirror  bool
+	}{
+		{"upright", 1, 4, 0, false},
+		{"turned right", 2, 3, 1, false},
+		{"upside down", 4, 3, 2, false},
+		{"turned left", 7, 2, 3, false},
+		{"mirrored", 3, 4, 0, true},
+		{"mirrored and turned", 5, 3, 1, true},
+		{"large", 25, 2, 0, false},
+	}
+	for _, tt := range tests {
+		code, err := Encode([]Segment{ByteSegment([]byte(tt.name))}, Options{Version: tt.version, Level: Medium, Mask: AutoMask})
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, corners := render(code.Modules, tt.scale, 4, tt.turns, tt.mirror)
+
+		found := Scan(img)
+		if len(found) != 1 {
+			t.Errorf("%s: found %d symbols, want 1", tt.name, len(found))
+			continue
+		}
+		if got := string(found[0].Content()); got != tt.name {
+			t.Errorf("%s: content = %q", tt.name, got)
+		}
+		for i, corner := range found[0].Corners {
+			if !near(corner, corners[i], 1) {
+				t.Errorf("%s: corners = %v, want %v", tt.name, found[0].Corners, corners)
+				break
+			}
+		}
+	}
+}
+
+func TestScanMany(t *testing.T) {
+	var (
+		images  []*image.Gray
+		corners [][4]image.Point
+		texts   = []string{"left", "right"}
+	)
+	for i, text := range texts {
+		code, err := Encode([]Segment{ByteSegment([]byte(text))}, Options{Level: Low, Mask: AutoMask})
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, c := render(code.Modules, 3, 4, i, false)
+		images, corners = append(images, img), append(corners, c)
+	}
+
+	// side by side in an image whose bounds do not start from zero
+	side := images[0].Bounds().Dx()
+	canvas := image.NewGray(image.Rect(100, 50, 100+side*2, 50+side))
+	for i, img := range images {
+		offset := image.Pt(100+side*i, 50)
+		draw.Draw(canvas, img.Bounds().Add(offset), img, image.ZP, draw.Src)
+		for j := range corners[i] {
+			corners[i][j] = corners[i][j].Add(offset)
+		}
+	}
+
+	found := Scan(canvas)
+	if len(found) != len(texts) {
+		t.Fatalf("found %d symbols, want %d", len(found), len(texts))
+	}
+	for _, symbol := range found {
+		i := 0
+		if string(symbol.Content()) == texts[1] {
+			i = 1
+		}
+		if got := string(symbol.Content()); got != texts[i] {
+			t.Errorf("content = %q", got)
+			continue
+		}
+		for j, corner := range symbol.Corners {
+			if !near(corner, corners[i][j], 1) {
+				t.Errorf("%s: corners = %v, want %v", texts[i], symbol.Corners, corners[i])
+				break
+			}
+		}
+	}
+}
+
+func TestScanNothing(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 64, 48))
+	for i := range img.Pix {
+		img.Pix[i] = byte(i * 7)
+	}
+	if found := Scan(img); len(found) != 0 {
+		t.Errorf("found %d symbols in noise", len(found))
+	}
+	if found := Scan(image.NewGray(image.Rect(0, 0, 0, 0))); len(found) != 0 {
+		t.Errorf("found %d symbols in empty image", len(found))
+	}
+}
@@ -0,0 +1,391 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qr
+
+import (
+	"math"
+)
+
+// pattern is a finder or alignment pattern found in image
+type pattern struct {
+	x, y float64
+	// moduleSize estimated module size in pixel
+	moduleSize float64
+	// count of scan lines confirming the pattern
+	count int
+}
+
+// near tells whether p is about the same pattern as the one at x, y
+func (p *pattern) near(x, y, moduleSize float64) bool {
+	if math.Abs(x-p.x) > moduleSize || math.Abs(y-p.y) > moduleSize {
+		return false
+	}
+	diff := math.Abs(moduleSize - p.moduleSize)
+	return diff <= 1 || diff <= p.moduleSize
+}
+
+// merge averages p with another confirmation at x, y
+func (p *pattern) merge(x, y, moduleSize float64) {
+	count := float64(p.count)
+	p.x = (p.x*count + x) / (count + 1)
+	p.y = (p.y*count + y) / (count + 1)
+	p.moduleSize = (p.moduleSize*count + moduleSize) / (count + 1)
+	p.count++
+}
+
+// distance between centers of patterns
+func distance(a, b *pattern) float64 {
+	return math.Hypot(a.x-b.x, a.y-b.y)
+}
+
+// crossRatio tells whether run lengths of dark-light-dark-light-dark
+// are about 1:1:3:1:1, within variance of a module.
+func crossRatio(counts [5]int, variance float64) bool {
+	total := 0
+	for _, count := range counts {
+		if count == 0 {
+			return false
+		}
+		total += count
+	}
+	if total < 7 {
+		return false
+	}
+	moduleSize := float64(total) / 7
+	maxVariance := moduleSize / variance
+	return math.Abs(moduleSize-float64(counts[0])) < maxVariance &&
+		math.Abs(moduleSize-float64(counts[1])) < maxVariance &&
+		math.Abs(3*moduleSize-float64(counts[2])) < 3*maxVariance &&
+		math.Abs(moduleSize-float64(counts[3])) < maxVariance &&
+		math.Abs(moduleSize-float64(counts[4])) < maxVariance
+}
+
+// centerFromEnd is the center of the middle run, runs end before end
+func centerFromEnd(counts []int, end int) float64 {
+	middle := len(counts) / 2
+	center := float64(end) - float64(counts[middle])/2
+	for _, count := range counts[middle+1:] {
+		center -= float64(count)
+	}
+	return center
+}
+
+// findFinders scans every row for finder patterns,
+// confirming them across column and diagonal.
+func findFinders(b *bitmap) (finders []*pattern) {
+	for y := 0; y < b.height; y++ {
+		var counts [5]int
+		state := 0
+		for x := 0; x < b.width; x++ {
+			if b.at(x, y) {
+				if state&1 == 1 {
+					// light run ends
+					state++
+				}
+				counts[state]++
+				continue
+			}
+			if state&1 == 1 {
+				counts[state]++
+				continue
+			}
+			if state != 4 {
+				state++
+				counts[state]++
+				continue
+			}
+			if crossRatio(counts, 2) && handleFinder(b, &finders, counts, x, y) {
+				counts, state = [5]int{}, 0
+				continue
+			}
+			// keeps the last dark-light-dark for next try
+			counts = [5]int{counts[2], counts[3], counts[4], 1, 0}
+			state = 3
+		}
+		if crossRatio(counts, 2) {
+			handleFinder(b, &finders, counts, b.width, y)
+		}
+	}
+	return
+}
+
+// handleFinder confirms the finder pattern found in row y ending at x,
+// and records it in finders.
+func handleFinder(b *bitmap, finders *[]*pattern, counts [5]int, x, y int) bool {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	centerX := centerFromEnd(counts[:], x)
+	centerY, ok := crossCheck(b, int(centerX), y, 0, 1, counts[2], total, 0.4)
+	if !ok {
+		return false
+	}
+	centerX, ok = crossCheck(b, int(centerX), int(centerY), 1, 0, counts[2], total, 0.2)
+	if !ok || !crossCheckDiagonal(b, int(centerX), int(centerY)) {
+		return false
+	}
+
+	moduleSize := float64(total) / 7
+	for _, finder := range *finders {
+		if finder.near(centerX, centerY, moduleSize) {
+			finder.merge(centerX, centerY, moduleSize)
+			return true
+		}
+	}
+	*finders = append(*finders, &pattern{x: centerX, y: centerY, moduleSize: moduleSize, count: 1})
+	return true
+}
+
+// crossCheck counts runs of 1:1:3:1:1 through x, y along dx, dy, which is
+// a column or a row, and returns center of the pattern on that line.
+//
+// Runs are limited to maxCount, and their total should differ
+// by less than tolerance times originalTotal from the other direction.
+func crossCheck(b *bitmap, x, y, dx, dy, maxCount, originalTotal int, tolerance float64) (center float64, ok bool) {
+	var counts [5]int
+	limit := b.height
+	pos := y
+	if dx != 0 {
+		limit, pos = b.width, x
+	}
+	dark := func(i int) bool {
+		if dx != 0 {
+			return b.at(i, y)
+		}
+		return b.at(x, i)
+	}
+
+	i := pos
+	for ; i >= 0 && dark(i); i-- {
+		counts[2]++
+	}
+	if i < 0 {
+		return
+	}
+	for ; i >= 0 && !dark(i) && counts[1] <= maxCount; i-- {
+		counts[1]++
+	}
+	if i < 0 || counts[1] > maxCount {
+		return
+	}
+	for ; i >= 0 && dark(i) && counts[0] <= maxCount; i-- {
+		counts[0]++
+	}
+	if counts[0] > maxCount {
+		return
+	}
+
+	i = pos + 1
+	for ; i < limit && dark(i); i++ {
+		counts[2]++
+	}
+	if i == limit {
+		return
+	}
+	for ; i < limit && !dark(i) && counts[3] < maxCount; i++ {
+		counts[3]++
+	}
+	if i == limit || counts[3] >= maxCount {
+		return
+	}
+	for ; i < limit && dark(i) && counts[4] < maxCount; i++ {
+		counts[4]++
+	}
+	if counts[4] >= maxCount {
+		return
+	}
+
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	if math.Abs(float64(total-originalTotal)) >= tolerance*float64(originalTotal) {
+		return
+	}
+	if !crossRatio(counts, 2) {
+		return
+	}
+	center, ok = centerFromEnd(counts[:], i), true
+	return
+}
+
+// crossCheckDiagonal tells whether runs through x, y from top left
+// to bottom right are about 1:1:3:1:1 as well.
+func crossCheckDiagonal(b *bitmap, x, y int) bool {
+	var counts [5]int
+	i := 0
+	for ; b.at(x-i, y-i); i++ {
+		counts[2]++
+	}
+	for ; x >= i && y >= i && !b.at(x-i, y-i); i++ {
+		counts[1]++
+	}
+	for ; b.at(x-i, y-i); i++ {
+		counts[0]++
+	}
+	i = 1
+	for ; b.at(x+i, y+i); i++ {
+		counts[2]++
+	}
+	for ; x+i < b.width && y+i < b.height && !b.at(x+i, y+i); i++ {
+		counts[3]++
+	}
+	for ; b.at(x+i, y+i); i++ {
+		counts[4]++
+	}
+	return crossRatio(counts, 1.333)
+}
+
+// findAlignment searches for alignment pattern around x, y,
+// within allowance modules, from the middle row outward.
+func findAlignment(b *bitmap, x, y, moduleSize, allowance float64) (found *pattern) {
+	reach := int(allowance * moduleSize)
+	left, right := maxInt(0, int(x)-reach), minInt(b.width-1, int(x)+reach)
+	top, bottom := maxInt(0, int(y)-reach), minInt(b.height-1, int(y)+reach)
+	if float64(right-left) < moduleSize*3 || float64(bottom-top) < moduleSize*3 {
+		return
+	}
+
+	// 1:1:1 light-dark-light around the center module
+	matches := func(counts [3]int) bool {
+		for _, count := range counts {
+			if math.Abs(moduleSize-float64(count)) >= moduleSize/2 {
+				return false
+			}
+		}
+		return true
+	}
+	var candidates []*pattern
+	confirm := func(counts [3]int, row, end int) *pattern {
+		total := counts[0] + counts[1] + counts[2]
+		centerX := centerFromEnd(counts[:], end)
+		centerY, ok := crossCheckAlignment(b, int(centerX), row, 0, 1, 2*counts[1], total, matches)
+		if !ok {
+			return nil
+		}
+		// refines center along the row through center
+		centerX, ok = crossCheckAlignment(b, int(centerX), int(centerY), 1, 0, 2*counts[1], total, matches)
+		if !ok {
+			return nil
+		}
+		size := float64(total) / 3
+		for _, candidate := range candidates {
+			if candidate.near(centerX, centerY, size) {
+				// seen twice
+				candidate.merge(centerX, centerY, size)
+				return candidate
+			}
+		}
+		candidates = append(candidates, &pattern{x: centerX, y: centerY, moduleSize: size, count: 1})
+		return nil
+	}
+
+	middle := (top + bottom) / 2
+	for step := 0; step <= bottom-top; step++ {
+		row := middle + (step+1)/2
+		if step&1 == 1 {
+			row = middle - (step+1)/2
+		}
+		if row < top || row > bottom {
+			continue
+		}
+
+		var counts [3]int
+		col := left
+		for col <= right && !b.at(col, row) {
+			col++
+		}
+		state := 0
+		for ; col <= right; col++ {
+			if !b.at(col, row) {
+				if state == 1 {
+					state++
+				}
+				counts[state]++
+				continue
+			}
+			if state == 1 {
+				counts[1]++
+				continue
+			}
+			if state == 2 {
+				if matches(counts) {
+					if found = confirm(counts, row, col); found != nil {
+						return
+					}
+				}
+				counts = [3]int{counts[2], 1, 0}
+				state = 1
+				continue
+			}
+			state++
+			counts[state]++
+		}
+		if matches(counts) {
+			if found = confirm(counts, row, right+1); found != nil {
+				return
+			}
+		}
+	}
+	// takes the one seen once nearest to x, y, if any
+	for _, candidate := range candidates {
+		if found == nil || math.Hypot(candidate.x-x, candidate.y-y) < math.Hypot(found.x-x, found.y-y) {
+			found = candidate
+		}
+	}
+	return
+}
+
+// crossCheckAlignment counts light-dark-light runs through x, y along dx, dy,
+// which is a column or a row, and returns center of the pattern on that line.
+func crossCheckAlignment(b *bitmap, x, y, dx, dy, maxCount, originalTotal int, matches func([3]int) bool) (center float64, ok bool) {
+	var counts [3]int
+	limit, pos := b.height, y
+	if dx != 0 {
+		limit, pos = b.width, x
+	}
+	dark := func(i int) bool {
+		if dx != 0 {
+			return b.at(i, y)
+		}
+		return b.at(x, i)
+	}
+
+	i := pos
+	for ; i >= 0 && dark(i) && counts[1] <= maxCount; i-- {
+		counts[1]++
+	}
+	if i < 0 || counts[1] > maxCount {
+		return
+	}
+	for ; i >= 0 && !dark(i) && counts[0] <= maxCount; i-- {
+		counts[0]++
+	}
+	if counts[0] > maxCount {
+		return
+	}
+	for i = pos + 1; i < limit && dark(i) && counts[1] <= maxCount; i++ {
+		counts[1]++
+	}
+	if i == limit || counts[1] > maxCount {
+		return
+	}
+	for ; i < limit && !dark(i) && counts[2] <= maxCount; i++ {
+		counts[2]++
+	}
+	if counts[2] > maxCount {
+		return
+	}
+	total := counts[0] + counts[1] + counts[2]
+	if 5*abs(total-originalTotal) >= 2*originalTotal || !matches(counts) {
+		return
+	}
+	center, ok = centerFromEnd(counts[:], i), true
+	return
+}
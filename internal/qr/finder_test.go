@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qr
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCrossRatio(t *testing.T) {
+	tests := []struct {
+		counts [5]int
+		want   bool
+	}{
+		{[5]int{1, 1, 3, 1, 1}, true},
+		{[5]int{4, 4, 12, 4, 4}, true},
+		{[5]int{3, 5, 11, 4, 4}, true},
+		{[5]int{4, 4, 4, 4, 4}, false},
+		{[5]int{4, 4, 12, 4, 0}, false},
+		{[5]int{8, 4, 12, 4, 4}, false},
+	}
+	for _, tt := range tests {
+		if got := crossRatio(tt.counts, 2); got != tt.want {
+			t.Errorf("crossRatio(%v) = %v, want %v", tt.counts, got, tt.want)
+		}
+	}
+}
+
+func TestFindPatterns(t *testing.T) {
+	const scale, quiet = 4, 4
+	code, err := Encode([]Segment{ByteSegment([]byte("finder"))}, Options{Version: 2, Level: Low, Mask: AutoMask})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _ := render(code.Modules, scale, quiet, 0, false)
+	b := binarize(img)
+	// center of module in pixel
+	center := func(module int) float64 {
+		return float64((module+quiet)*scale) + scale/2.0
+	}
+
+	finders := findFinders(b)
+	want := [][2]float64{
+		{center(3), center(3)},
+		{center(21), center(3)},
+		{center(3), center(21)},
+	}
+	if len(finders) != len(want) {
+		t.Fatalf("found %d finder patterns, want %d", len(finders), len(want))
+	}
+	for _, w := range want {
+		matched := false
+		for _, finder := range finders {
+			if math.Abs(finder.x-w[0]) <= 1 && math.Abs(finder.y-w[1]) <= 1 {
+				matched = true
+				if math.Abs(finder.moduleSize-scale) > 0.5 {
+					t.Errorf("finder at %v has module size %v, want %d", w, finder.moduleSize, scale)
+				}
+			}
+		}
+		if !matched {
+			t.Errorf("no finder pattern at %v", w)
+		}
+	}
+
+	// alignment pattern of version 2 is at module 18, 18
+	alignment := findAlignment(b, center(18)+3, center(18)-2, scale, 4)
+	if alignment == nil {
+		t.Fatal("alignment pattern is not found")
+	}
+	if math.Abs(alignment.x-center(18)) > 1 || math.Abs(alignment.y-center(18)) > 1 {
+		t.Errorf("alignment pattern at %v, %v, want %v, %v", alignment.x, alignment.y, center(18), center(18))
+	}
+}
@@ -0,0 +1,377 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qr
+
+// matrix is symbol under construction
+type matrix struct {
+	version int
+	width   int
+	height  int
+	modules [][]bool
+	// reserved marks function modules which are neither data nor masked
+	reserved [][]bool
+}
+
+// newMatrix starts a QR Code of version
+func newMatrix(version int) *matrix {
+	size := version*4 + 17
+	m := newGrid(size, size)
+	m.version = version
+	return m
+}
+
+// newGrid starts a blank symbol of width x height modules
+func newGrid(width, height int) *matrix {
+	m := &matrix{
+		width:    width,
+		height:   height,
+		modules:  make([][]bool, height),
+		reserved: make([][]bool, height),
+	}
+	for i := 0; i < height; i++ {
+		m.modules[i] = make([]bool, width)
+		m.reserved[i] = make([]bool, width)
+	}
+	return m
+}
+
+// set puts function module at x, y
+func (m *matrix) set(x, y int, dark bool) {
+	m.modules[y][x] = dark
+	m.reserved[y][x] = true
+}
+
+func (m *matrix) drawFunctionPatterns() {
+	// timing patterns
+	for i := 0; i < m.width; i++ {
+		m.set(6, i, i%2 == 0)
+		m.set(i, 6, i%2 == 0)
+	}
+
+	m.drawFinder(3, 3)
+	m.drawFinder(m.width-4, 3)
+	m.drawFinder(3, m.width-4)
+
+	positions := alignmentPositions(m.version)
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			// overlapping finder patterns
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			m.drawAlignment(x, y)
+		}
+	}
+
+	// reserve format area, real bits come after masking
+	m.drawFormat(Low, 0)
+	m.drawVersion()
+}
+
+// drawFinder draws finder pattern with separator centered at x, y
+func (m *matrix) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= m.width || yy < 0 || yy >= m.height {
+				continue
+			}
+			distance := maxInt(abs(dx), abs(dy))
+			m.set(xx, yy, distance != 2 && distance != 4)
+		}
+	}
+}
+
+// drawAlignment draws alignment pattern centered at x, y
+func (m *matrix) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			m.set(x+dx, y+dy, maxInt(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// formatCode is 15-bit format information of level and mask, BCH coded and masked
+func formatCode(level Level, mask int) uint32 {
+	data := level.formatBits()<<3 | uint32(mask)
+	remainder := data
+	for i := 0; i < 10; i++ {
+		remainder = (remainder << 1) ^ ((remainder >> 9) * 0x537)
+	}
+	return (data<<10 | remainder) ^ 0x5412
+}
+
+// formatPositions are where bit i of both copies of format information lie
+func formatPositions(width int) (first, second [15][2]int) {
+	for i := 0; i < 15; i++ {
+		// around top left finder
+		switch {
+		case i <= 5:
+			first[i] = [2]int{8, i}
+		case i <= 7:
+			first[i] = [2]int{8, i + 1}
+		case i == 8:
+			first[i] = [2]int{7, 8}
+		default:
+			first[i] = [2]int{14 - i, 8}
+		}
+		// split between the other two finders
+		if i < 8 {
+			second[i] = [2]int{width - 1 - i, 8}
+		} else {
+			second[i] = [2]int{8, width - 15 + i}
+		}
+	}
+	return
+}
+
+// drawFormat draws both copies of format information
+func (m *matrix) drawFormat(level Level, mask int) {
+	bits := formatCode(level, mask)
+	first, second := formatPositions(m.width)
+	for i := range first {
+		dark := (bits>>uint(i))&1 == 1
+		m.set(first[i][0], first[i][1], dark)
+		m.set(second[i][0], second[i][1], dark)
+	}
+	// the dark module
+	m.set(8, m.width-8, true)
+}
+
+// versionCode is 18-bit version information, BCH coded
+func versionCode(version int) uint32 {
+	remainder := uint32(version)
+	for i := 0; i < 12; i++ {
+		remainder = (remainder << 1) ^ ((remainder >> 11) * 0x1f25)
+	}
+	return uint32(version)<<12 | remainder
+}
+
+// drawVersion draws version information for version 7 and up
+func (m *matrix) drawVersion() {
+	if m.version < 7 {
+		return
+	}
+	bits := versionCode(m.version)
+	for i := 0; i < 18; i++ {
+		dark := (bits>>uint(i))&1 == 1
+		a, b := m.width-11+i%3, i/3
+		m.set(a, b, dark)
+		m.set(b, a, dark)
+	}
+}
+
+// drawCodewords places data in zigzag, two columns at a time
+// from bottom right, skipping the vertical timing pattern.
+func (m *matrix) drawCodewords(data []byte) {
+	var bits bitBuffer
+	for _, b := range data {
+		bits.append(uint32(b), 8)
+	}
+	m.drawBits(bits, m.width-1, 6)
+}
+
+// drawBits places bits in zigzag of two columns, starting upward
+// from column right at the bottom. Column skip is jumped over,
+// function modules are left untouched and remainder bits stay light.
+func (m *matrix) drawBits(bits bitBuffer, right, skip int) {
+	i := 0
+	upward := true
+	for ; right >= 1; right -= 2 {
+		if right == skip {
+			right--
+		}
+		for vert := 0; vert < m.height; vert++ {
+			y := vert
+			if upward {
+				y = m.height - 1 - vert
+			}
+			for x := right; x > right-2; x-- {
+				if m.reserved[y][x] {
+					continue
+				}
+				if i < len(bits) {
+					m.modules[y][x] = bits[i]
+					i++
+				}
+			}
+		}
+		upward = !upward
+	}
+}
+
+// readBits reads data modules in the same zigzag order as drawBits
+func (m *matrix) readBits(right, skip int) (bits bitBuffer) {
+	upward := true
+	for ; right >= 1; right -= 2 {
+		if right == skip {
+			right--
+		}
+		for vert := 0; vert < m.height; vert++ {
+			y := vert
+			if upward {
+				y = m.height - 1 - vert
+			}
+			for x := right; x > right-2; x-- {
+				if !m.reserved[y][x] {
+					bits = append(bits, m.modules[y][x])
+				}
+			}
+		}
+		upward = !upward
+	}
+	return
+}
+
+// applyMask flips data modules per mask pattern
+func (m *matrix) applyMask(mask int) {
+	for y := 0; y < m.height; y++ {
+		for x := 0; x < m.width; x++ {
+			if m.reserved[y][x] {
+				continue
+			}
+			var flip bool
+			switch mask {
+			case 0:
+				flip = (x+y)%2 == 0
+			case 1:
+				flip = y%2 == 0
+			case 2:
+				flip = x%3 == 0
+			case 3:
+				flip = (x+y)%3 == 0
+			case 4:
+				flip = (x/3+y/2)%2 == 0
+			case 5:
+				flip = x*y%2+x*y%3 == 0
+			case 6:
+				flip = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				flip = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if flip {
+				m.modules[y][x] = !m.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty weights per ISO/IEC 18004 7.8.3
+const (
+	penaltyRun     = 3
+	penaltyBlock   = 3
+	penaltyFinder  = 40
+	penaltyBalance = 10
+)
+
+// penalty scores how hard the symbol is to read, lower is better
+func (m *matrix) penalty() (result int) {
+	// runs of same color and finder-like patterns, in rows and columns
+	line := make([]bool, m.width)
+	for _, vertical := range []bool{false, true} {
+		for i := 0; i < m.width; i++ {
+			for j := 0; j < m.width; j++ {
+				if vertical {
+					line[j] = m.modules[j][i]
+				} else {
+					line[j] = m.modules[i][j]
+				}
+			}
+			result += runPenalty(line) + finderPenalty(line)
+		}
+	}
+
+	// 2x2 blocks of same color
+	for y := 0; y < m.width-1; y++ {
+		for x := 0; x < m.width-1; x++ {
+			c := m.modules[y][x]
+			if c == m.modules[y][x+1] && c == m.modules[y+1][x] && c == m.modules[y+1][x+1] {
+				result += penaltyBlock
+			}
+		}
+	}
+
+	// balance of dark and light modules
+	dark := 0
+	for _, row := range m.modules {
+		for _, module := range row {
+			if module {
+				dark++
+			}
+		}
+	}
+	total := m.width * m.width
+	// smallest k such that dark ratio is within (45-5k)% and (55+5k)%
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	if k < 0 {
+		k = 0
+	}
+	result += k * penaltyBalance
+	return
+}
+
+// runPenalty scores runs of five or more same-colored modules
+func runPenalty(line []bool) (result int) {
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			result += penaltyRun + run - 5
+		}
+		run = 1
+	}
+	return
+}
+
+// finderPenalty scores 1:1:3:1:1 dark-light pattern with
+// four light modules on either side, quiet zone counts as light.
+func finderPenalty(line []bool) (result int) {
+	pattern := []bool{true, false, true, true, true, false, true}
+	at := func(i int) bool {
+		return i >= 0 && i < len(line) && line[i]
+	}
+	for i := 0; i+len(pattern) <= len(line); i++ {
+		matched := true
+		for j, dark := range pattern {
+			if line[i+j] != dark {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		lightBefore, lightAfter := true, true
+		for j := 1; j <= 4; j++ {
+			lightBefore = lightBefore && !at(i-j)
+			lightAfter = lightAfter && !at(i+len(pattern)-1+j)
+		}
+		if lightBefore || lightAfter {
+			result += penaltyFinder
+		}
+	}
+	return
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,331 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"math/bits"
+)
+
+const (
+	// MaxMicroVersion largest Micro QR version, which is M4
+	MaxMicroVersion = 4
+	// MaxMicroMask largest Micro QR mask pattern
+	MaxMicroMask = 3
+)
+
+// microSymbol describes a Micro QR version at an error correction level
+type microSymbol struct {
+	// number in format information
+	number uint32
+	// dataBits capacity of data, M1 and M3 end in half a byte
+	dataBits int
+	// eccCodewords count of error correction codewords
+	eccCodewords int
+}
+
+// microSymbols[version-1][level], zero dataBits for unsupported level.
+// M1 only detects errors, which is taken as Low.
+var microSymbols = [MaxMicroVersion][3]microSymbol{
+	{{0, 20, 2}},
+	{{1, 40, 5}, {2, 32, 6}},
+	{{3, 84, 6}, {4, 68, 8}},
+	{{5, 128, 8}, {6, 112, 10}, {7, 80, 14}},
+}
+
+// microMasks maps Micro QR mask pattern to QR one
+var microMasks = [MaxMicroMask + 1]int{1, 4, 6, 7}
+
+// microCharCountBits[mode][version-1], zero for unsupported mode
+var microCharCountBits = [3][MaxMicroVersion]int{
+	ModeNumeric:      {3, 4, 5, 6},
+	ModeAlphanumeric: {0, 3, 4, 5},
+	ModeByte:         {0, 0, 4, 5},
+}
+
+// microKanjiCountBits[version-1] for reading, zero for unsupported version
+var microKanjiCountBits = [MaxMicroVersion]int{0, 0, 3, 4}
+
+// microModes maps Micro QR mode indicator to QR Code one
+var microModes = map[uint32]uint32{
+	0: 0x1,
+	1: 0x2,
+	2: 0x4,
+	3: modeKanji,
+}
+
+// EncodeMicro builds Micro QR Code from segments.
+//
+// opts.Version is 1 to 4 for M1 to M4, opts.Mask is 0 to 3.
+// Quartile level is M4 only, and High is not supported by Micro QR.
+// Structured Append and ECI are not supported either.
+func EncodeMicro(segments []Segment, opts Options) (code *Code, err error) {
+	if opts.Level < Low || opts.Level > Quartile {
+		err = errors.New("Micro QR supports error correction level L, M and Q")
+		return
+	}
+	if opts.Mask < AutoMask || opts.Mask > MaxMicroMask {
+		err = errors.New("Micro QR mask should be between 0 and 3")
+		return
+	}
+	if opts.Version < 0 || opts.Version > MaxMicroVersion {
+		err = errors.New("Micro QR version should be between 1 and 4")
+		return
+	}
+	if opts.Append != nil {
+		err = errors.New("Micro QR does not support Structured Append")
+		return
+	}
+	if opts.ECI != 0 {
+		err = errors.New("Micro QR does not support ECI")
+		return
+	}
+
+	minVersion, maxVersion := 1, MaxMicroVersion
+	if opts.Version != 0 {
+		minVersion, maxVersion = opts.Version, opts.Version
+	}
+	version := 0
+	for v := minVersion; v <= maxVersion; v++ {
+		symbol := microSymbols[v-1][opts.Level]
+		if used, ok := microBitLength(segments, v); ok && symbol.dataBits > 0 && used <= symbol.dataBits {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		err = ErrTooLong
+		return
+	}
+	symbol := microSymbols[version-1][opts.Level]
+
+	var bits bitBuffer
+	for _, segment := range segments {
+		bits.append(uint32(segment.Mode), version-1)
+		bits.append(uint32(segment.Count), microCharCountBits[segment.Mode][version-1])
+		bits = append(bits, segment.bits...)
+	}
+	bits.pad(symbol.dataBits, version*2+1)
+
+	// half byte is the high nibble of the last data codeword
+	data := bits.bytes()
+	ecc := rsRemainder(data, rsDivisor(symbol.eccCodewords))
+	for _, b := range ecc {
+		bits.append(uint32(b), 8)
+	}
+
+	m := newGrid(version*2+9, version*2+9)
+	m.version = version
+	m.drawMicroFunctionPatterns()
+	m.drawBits(bits, m.width-1, -1)
+
+	mask := opts.Mask
+	if mask == AutoMask {
+		highest := -1
+		for candidate := 0; candidate <= MaxMicroMask; candidate++ {
+			m.applyMask(microMasks[candidate])
+			if score := m.microScore(); score > highest {
+				mask, highest = candidate, score
+			}
+			m.applyMask(microMasks[candidate])
+		}
+	}
+	m.applyMask(microMasks[mask])
+	m.drawMicroFormat(symbol.number, mask)
+
+	code = &Code{
+		Version: version,
+		Level:   opts.Level,
+		Mask:    mask,
+		Modules: m.modules,
+	}
+	return
+}
+
+// microBitLength is how many bits segments take in Micro QR version,
+// ok is false if any mode is not supported or any count overflows.
+func microBitLength(segments []Segment, version int) (bits int, ok bool) {
+	for _, segment := range segments {
+		width := microCharCountBits[segment.Mode][version-1]
+		if width == 0 || segment.Count >= 1<<uint(width) {
+			return
+		}
+		bits += version - 1 + width + len(segment.bits)
+	}
+	ok = true
+	return
+}
+
+// drawMicroFunctionPatterns draws finder, timing patterns
+// and reserves format area of Micro QR.
+func (m *matrix) drawMicroFunctionPatterns() {
+	for i := 8; i < m.width; i++ {
+		m.set(i, 0, i%2 == 0)
+		m.set(0, i, i%2 == 0)
+	}
+	m.drawFinder(3, 3)
+	m.drawMicroFormat(0, 0)
+}
+
+// microFormatCode is 15-bit format information of symbol number and mask, BCH coded and masked
+func microFormatCode(number uint32, mask int) uint32 {
+	data := number<<2 | uint32(mask)
+	remainder := data
+	for i := 0; i < 10; i++ {
+		remainder = (remainder << 1) ^ ((remainder >> 9) * 0x537)
+	}
+	return (data<<10 | remainder) ^ 0x4445
+}
+
+// microFormatPosition is where bit i of format information lies
+func microFormatPosition(i int) (x, y int) {
+	if i < 7 {
+		return 8, i + 1
+	}
+	return 15 - i, 8
+}
+
+// drawMicroFormat draws format information around the finder
+func (m *matrix) drawMicroFormat(number uint32, mask int) {
+	bits := microFormatCode(number, mask)
+	for i := 0; i < 15; i++ {
+		x, y := microFormatPosition(i)
+		m.set(x, y, (bits>>uint(i))&1 == 1)
+	}
+}
+
+// microScore rates masked Micro QR by dark modules on right and bottom edges,
+// higher is better.
+func (m *matrix) microScore() int {
+	var right, bottom int
+	for i := 1; i < m.width; i++ {
+		if m.modules[i][m.width-1] {
+			right++
+		}
+		if m.modules[m.height-1][i] {
+			bottom++
+		}
+	}
+	if right <= bottom {
+		return right*16 + bottom
+	}
+	return bottom*16 + right
+}
+
+// ReadMicro decodes Micro QR Code symbol from its modules, dark ones are true,
+// without quiet zone. Version of decoded is 1 to 4 for M1 to M4.
+//
+// M1 only detects errors, which are not corrected.
+func ReadMicro(modules [][]bool) (decoded *Decoded, err error) {
+	size := len(modules)
+	version := (size - 9) / 2
+	if version < 1 || version > MaxMicroVersion || version*2+9 != size {
+		err = errors.New("size of symbol is invalid")
+		return
+	}
+	for _, row := range modules {
+		if len(row) != size {
+			err = errors.New("symbol is not square")
+			return
+		}
+	}
+
+	number, mask, ok := readMicroFormat(modules)
+	if !ok {
+		err = ErrBadFormat
+		return
+	}
+	// symbol number tells version as well
+	level := Level(-1)
+	for l, symbol := range microSymbols[version-1] {
+		if symbol.dataBits > 0 && symbol.number == number {
+			level = Level(l)
+		}
+	}
+	if level < Low {
+		err = ErrBadFormat
+		return
+	}
+	symbol := microSymbols[version-1][level]
+
+	m := newGrid(size, size)
+	m.version = version
+	m.drawMicroFunctionPatterns()
+	for y, row := range modules {
+		copy(m.modules[y], row)
+	}
+	m.applyMask(microMasks[mask])
+	placed := m.readBits(m.width-1, -1)
+	eccBits := symbol.eccCodewords * 8
+	if len(placed) < symbol.dataBits+eccBits {
+		err = ErrBadData
+		return
+	}
+
+	// half byte is the high nibble of the last data codeword
+	data := placed[:symbol.dataBits].bytes()
+	ecc := placed[symbol.dataBits : symbol.dataBits+eccBits].bytes()
+	if version == 1 {
+		if !bytes.Equal(rsRemainder(data, rsDivisor(symbol.eccCodewords)), ecc) {
+			err = errUncorrectable
+			return
+		}
+	} else {
+		block := append(data, ecc...)
+		err = rsCorrect(block, symbol.eccCodewords)
+		if err != nil {
+			return
+		}
+		data = block[:len(data)]
+	}
+
+	decoded, err = parse(data, modeScheme{
+		modeBits:       version - 1,
+		terminatorBits: version*2 + 1,
+		modes:          microModes,
+		countBits: func(mode uint32) int {
+			switch mode {
+			case 0x1:
+				return microCharCountBits[ModeNumeric][version-1]
+			case 0x2:
+				return microCharCountBits[ModeAlphanumeric][version-1]
+			case 0x4:
+				return microCharCountBits[ModeByte][version-1]
+			}
+			return microKanjiCountBits[version-1]
+		},
+	})
+	if err != nil {
+		return
+	}
+	decoded.Version, decoded.Level, decoded.Mask = version, level, mask
+	return
+}
+
+// readMicroFormat finds symbol number and mask closest to format information
+func readMicroFormat(modules [][]bool) (number uint32, mask int, ok bool) {
+	var read uint32
+	for i := 0; i < 15; i++ {
+		x, y := microFormatPosition(i)
+		if modules[y][x] {
+			read |= 1 << uint(i)
+		}
+	}
+
+	best := maxInfoErrors + 1
+	for candidate := uint32(0); candidate < 8; candidate++ {
+		for candidateMask := 0; candidateMask <= MaxMicroMask; candidateMask++ {
+			if distance := bits.OnesCount32(microFormatCode(candidate, candidateMask) ^ read); distance < best {
+				number, mask, best = candidate, candidateMask, distance
+			}
+		}
+	}
+	ok = best <= maxInfoErrors
+	return
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qr
+
+import (
+	"bytes"
+	"testing"
+)
+
+// flipped copies modules with modules at points inverted
+func flipped(modules [][]bool, points ...[2]int) [][]bool {
+	result := make([][]bool, len(modules))
+	for y, row := range modules {
+		result[y] = append([]bool(nil), row...)
+	}
+	for _, p := range points {
+		result[p[1]][p[0]] = !result[p[1]][p[0]]
+	}
+	return result
+}
+
+func TestMicroFormatCode(t *testing.T) {
+	// masked format information of ISO/IEC 18004 Table C.1
+	tests := []struct {
+		number uint32
+		mask   int
+		want   uint32
+	}{
+		{0, 0, 0x4445},
+		{0, 1, 0x4172},
+		{1, 0, 0x55ae},
+		{7, 3, 0x3bba},
+	}
+	for _, tt := range tests {
+		if got := microFormatCode(tt.number, tt.mask); got != tt.want {
+			t.Errorf("microFormatCode(%d, %d) = %#x, want %#x", tt.number, tt.mask, got, tt.want)
+		}
+	}
+}
+
+func TestMicroDataModules(t *testing.T) {
+	for version := 1; version <= MaxMicroVersion; version++ {
+		m := newGrid(version*2+9, version*2+9)
+		m.version = version
+		m.drawMicroFunctionPatterns()
+		modules := len(m.readBits(m.width-1, -1))
+		for level, symbol := range microSymbols[version-1] {
+			if symbol.dataBits == 0 {
+				continue
+			}
+			if want := symbol.dataBits + symbol.eccCodewords*8; modules != want {
+				t.Errorf("M%d level %d has %d data modules, want %d", version, level, modules, want)
+			}
+		}
+	}
+}
+
+// microCodewords reads unmasked data and error correction bits of code
+func microCodewords(code *Code) []byte {
+	size := len(code.Modules)
+	m := newGrid(size, size)
+	m.version = code.Version
+	m.drawMicroFunctionPatterns()
+	for y, row := range code.Modules {
+		copy(m.modules[y], row)
+	}
+	m.applyMask(microMasks[code.Mask])
+	return m.readBits(m.width-1, -1).bytes()
+}
+
+func TestMicroGolden(t *testing.T) {
+	// "01234567" in M2-L, ISO/IEC 18004 Annex I.3
+	code, err := EncodeMicro([]Segment{NumericSegment("01234567")}, Options{Version: 2, Level: Low, Mask: AutoMask})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x40, 0x18, 0xac, 0xc3, 0x00,
+		0x86, 0x0d, 0x22, 0xae, 0x30,
+	}
+	if got := microCodewords(code); !bytes.Equal(got, want) {
+		t.Errorf("codewords = % x, want % x", got, want)
+	}
+	decoded, err := ReadMicro(code.Modules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(decoded.Content()); got != "01234567" {
+		t.Errorf("content = %q, want 01234567", got)
+	}
+}
+
+func TestMicroRoundTrip(t *testing.T) {
+	tests := []struct {
+		version  int
+		level    Level
+		segments []Segment
+		want     string
+	}{
+		{1, Low, []Segment{NumericSegment("12345")}, "12345"},
+		{2, Low, []Segment{AlphanumericSegment("AC-42")}, "AC-42"},
+		{2, Medium, []Segment{NumericSegment("98765432")}, "98765432"},
+		{3, Low, []Segment{ByteSegment([]byte("hello")), NumericSegment("42")}, "hello42"},
+		{3, Medium, []Segment{AlphanumericSegment("MICRO QR")}, "MICRO QR"},
+		{4, Low, []Segment{ByteSegment([]byte("Micro QR Code"))}, "Micro QR Code"},
+		{4, Medium, []Segment{AlphanumericSegment("HTTP://A.B/"), NumericSegment("2018")}, "HTTP://A.B/2018"},
+		{4, Quartile, []Segment{NumericSegment("31415926535")}, "31415926535"},
+	}
+	for _, tt := range tests {
+		for mask := 0; mask <= MaxMicroMask; mask++ {
+			code, err := EncodeMicro(tt.segments, Options{Version: tt.version, Level: tt.level, Mask: mask})
+			if err != nil {
+				t.Errorf("M%d level %d mask %d: %v", tt.version, tt.level, mask, err)
+				continue
+			}
+			decoded, err := ReadMicro(code.Modules)
+			if err != nil {
+				t.Errorf("M%d level %d mask %d: %v", tt.version, tt.level, mask, err)
+				continue
+			}
+			if decoded.Version != tt.version || decoded.Level != tt.level || decoded.Mask != mask {
+				t.Errorf("M%d level %d mask %d: read M%d level %d mask %d",
+					tt.version, tt.level, mask, decoded.Version, decoded.Level, decoded.Mask)
+			}
+			if got := string(decoded.Content()); got != tt.want {
+				t.Errorf("M%d level %d mask %d: content = %q, want %q", tt.version, tt.level, mask, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestReadMicroErrors(t *testing.T) {
+	code, err := EncodeMicro([]Segment{AlphanumericSegment("ERRORS")}, Options{Version: 3, Level: Low, Mask: AutoMask})
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := len(code.Modules)
+
+	// first codeword starts at bottom right corner
+	decoded, err := ReadMicro(flipped(code.Modules, [2]int{size - 1, size - 1}, [2]int{size - 2, size - 1}))
+	if err != nil {
+		t.Fatalf("corrupt codeword: %v", err)
+	}
+	if got := string(decoded.Content()); got != "ERRORS" {
+		t.Errorf("corrupt codeword: content = %q, want ERRORS", got)
+	}
+
+	// two bits of format information
+	decoded, err = ReadMicro(flipped(code.Modules, [2]int{8, 1}, [2]int{8, 2}))
+	if err != nil {
+		t.Fatalf("corrupt format: %v", err)
+	}
+	if decoded.Version != 3 || decoded.Level != Low || decoded.Mask != code.Mask {
+		t.Errorf("corrupt format: read M%d level %d mask %d", decoded.Version, decoded.Level, decoded.Mask)
+	}
+
+	if _, err = ReadMicro(code.Modules[1:]); err == nil {
+		t.Error("symbol of invalid size is read")
+	}
+}
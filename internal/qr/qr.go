@@ -0,0 +1,222 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+// Package qr is a QR Code Model 2 encoder which gives control
+// over version, mask and Structured Append, and a decoder which
+// finds and reads QR Codes in image without cgo.
+package qr
+
+import (
+	"errors"
+)
+
+// Level is the error correction level
+type Level int
+
+// error correction levels, in ascending order of recovery capacity
+const (
+	Low Level = iota
+	Medium
+	Quartile
+	High
+)
+
+// formatBits is the 2-bit indicator of level in format information
+func (l Level) formatBits() uint32 {
+	return [4]uint32{1, 0, 3, 2}[l]
+}
+
+const (
+	// MinVersion smallest QR Code version
+	MinVersion = 1
+	// MaxVersion largest QR Code version
+	MaxVersion = 40
+
+	// AutoMask lets encoder pick the mask with lowest penalty
+	AutoMask = -1
+
+	// MaxAppendSymbols is how many symbols Structured Append can link
+	MaxAppendSymbols = 16
+	// MaxECI largest ECI assignment number
+	MaxECI = 999999
+)
+
+// ErrTooLong content does not fit in requested version and level
+var ErrTooLong = errors.New("content is too long for QR Code")
+
+// StructuredAppend marks a symbol as part of a linked sequence
+type StructuredAppend struct {
+	// Index position of this symbol, starts from 0
+	Index int
+	// Total count of symbols in sequence
+	Total int
+	// Parity XOR of every byte of the whole content
+	Parity byte
+}
+
+// Options controls how symbol is built
+type Options struct {
+	Level Level
+	// Version 0 means the smallest version which fits
+	Version int
+	// Mask 0-7, or AutoMask
+	Mask int
+	// Append header to put before segments, nil if standalone
+	Append *StructuredAppend
+	// ECI assignment number declaring character set
+	// of byte segments, e.g. 20 for Shift JIS, 26 for UTF-8.
+	// 0 means none, which is ISO-8859-1 by standard
+	// though many readers assume UTF-8.
+	ECI int
+}
+
+// header is bits to put before segments,
+// Structured Append header comes first, then ECI designator.
+func (opts Options) header() (bits bitBuffer) {
+	if opts.Append != nil {
+		bits.append(0x3, 4)
+		bits.append(uint32(opts.Append.Index), 4)
+		bits.append(uint32(opts.Append.Total-1), 4)
+		bits.append(uint32(opts.Append.Parity), 8)
+	}
+	if opts.ECI > 0 {
+		bits = append(bits, eciBits(opts.ECI, 4)...)
+	}
+	return
+}
+
+// Code is an encoded QR Code
+type Code struct {
+	Version int
+	Level   Level
+	Mask    int
+	// Modules dark ones are true, without quiet zone
+	Modules [][]bool
+}
+
+// Encode builds QR Code from segments
+func Encode(segments []Segment, opts Options) (code *Code, err error) {
+	if opts.Level < Low || opts.Level > High {
+		err = errors.New("invalid error correction level")
+		return
+	}
+	if opts.Mask < AutoMask || opts.Mask > 7 {
+		err = errors.New("mask should be between 0 and 7")
+		return
+	}
+	if opts.Version != 0 && (opts.Version < MinVersion || opts.Version > MaxVersion) {
+		err = errors.New("version should be between 1 and 40")
+		return
+	}
+	if opts.ECI < 0 || opts.ECI > MaxECI {
+		err = errors.New("ECI should be between 0 and 999999")
+		return
+	}
+
+	minVersion, maxVersion := MinVersion, MaxVersion
+	if opts.Version != 0 {
+		minVersion, maxVersion = opts.Version, opts.Version
+	}
+	bits := opts.header()
+	version := 0
+	for v := minVersion; v <= maxVersion; v++ {
+		if used, ok := bitLength(segments, v); ok && len(bits)+used <= dataCodewords(v, opts.Level)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		err = ErrTooLong
+		return
+	}
+
+	for _, segment := range segments {
+		bits.append(modeIndicator(segment.Mode), 4)
+		bits.append(uint32(segment.Count), charCountBits(segment.Mode, version))
+		bits = append(bits, segment.bits...)
+	}
+	bits.pad(dataCodewords(version, opts.Level)*8, 4)
+
+	m := newMatrix(version)
+	m.drawFunctionPatterns()
+	m.drawCodewords(addECC(bits.bytes(), version, opts.Level))
+
+	mask := opts.Mask
+	if mask == AutoMask {
+		lowest := -1
+		for candidate := 0; candidate < 8; candidate++ {
+			m.applyMask(candidate)
+			m.drawFormat(opts.Level, candidate)
+			if penalty := m.penalty(); lowest < 0 || penalty < lowest {
+				mask, lowest = candidate, penalty
+			}
+			// masking is XOR, applying again reverts it
+			m.applyMask(candidate)
+		}
+	}
+	m.applyMask(mask)
+	m.drawFormat(opts.Level, mask)
+
+	code = &Code{
+		Version: version,
+		Level:   opts.Level,
+		Mask:    mask,
+		Modules: m.modules,
+	}
+	return
+}
+
+// bitLength is how many bits segments take in version,
+// ok is false if any count overflows its indicator.
+func bitLength(segments []Segment, version int) (bits int, ok bool) {
+	for _, segment := range segments {
+		width := charCountBits(segment.Mode, version)
+		if segment.Count >= 1<<uint(width) {
+			return
+		}
+		bits += 4 + width + len(segment.bits)
+	}
+	ok = true
+	return
+}
+
+// addECC splits data into blocks, appends error correction
+// codewords to each, then interleaves them.
+func addECC(data []byte, version int, level Level) (result []byte) {
+	blockCount := eccBlocks[level][version]
+	eccLen := eccCodewordsPerBlock[level][version]
+	rawCodewords := rawDataModules(version) / 8
+	shortBlocks := blockCount - rawCodewords%blockCount
+	shortLen := rawCodewords / blockCount
+
+	divisor := rsDivisor(eccLen)
+	blocks := make([][]byte, blockCount)
+	for i, k := 0, 0; i < blockCount; i++ {
+		dataLen := shortLen - eccLen
+		if i >= shortBlocks {
+			dataLen++
+		}
+		block := append([]byte(nil), data[k:k+dataLen]...)
+		k += dataLen
+		ecc := rsRemainder(block, divisor)
+		if i < shortBlocks {
+			// placeholder to line up with long blocks, skipped below
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, ecc...)
+	}
+
+	result = make([]byte, 0, rawCodewords)
+	for i := 0; i < len(blocks[0]); i++ {
+		for j, block := range blocks {
+			if i != shortLen-eccLen || j >= shortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return
+}
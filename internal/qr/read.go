@@ -0,0 +1,444 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qr
+
+import (
+	"errors"
+	"math/bits"
+	"strings"
+)
+
+// maxInfoErrors most bit errors to tolerate in format and version information
+const maxInfoErrors = 3
+
+// errors of reading symbol
+var (
+	// ErrBadFormat format or version information is unreadable
+	ErrBadFormat = errors.New("format information is unreadable")
+	// ErrBadData data bits do not follow the standard
+	ErrBadData = errors.New("data is malformed")
+)
+
+// Decoded is what a QR Code symbol holds
+type Decoded struct {
+	Version int
+	Level   Level
+	Mask    int
+	// Parts content split where ECI changes
+	Parts []Part
+	// Append header of Structured Append, nil if standalone
+	Append *StructuredAppend
+	// GS1 tells whether FNC1 in first position is present,
+	// in which case group separators are 0x1d in Parts.
+	GS1 bool
+}
+
+// Part is a run of content in the same character set
+type Part struct {
+	// ECI assignment number in effect, 0 if none designated
+	ECI int
+	// Data bytes as they are, Kanji mode is in Shift JIS
+	Data []byte
+	// Kanji tells whether Kanji mode is used in Data
+	Kanji bool
+}
+
+// Content joins data of every part
+func (d *Decoded) Content() (content []byte) {
+	for _, part := range d.Parts {
+		content = append(content, part.Data...)
+	}
+	return
+}
+
+// Read decodes QR Code symbol from its modules, dark ones are true,
+// without quiet zone. See ReadMicro and ReadRMQR for Micro QR and rMQR.
+func Read(modules [][]bool) (decoded *Decoded, err error) {
+	size := len(modules)
+	version := (size - 17) / 4
+	if version < MinVersion || version > MaxVersion || version*4+17 != size {
+		err = errors.New("size of symbol is invalid")
+		return
+	}
+	for _, row := range modules {
+		if len(row) != size {
+			err = errors.New("symbol is not square")
+			return
+		}
+	}
+
+	level, mask, ok := readFormat(modules)
+	if !ok {
+		err = ErrBadFormat
+		return
+	}
+	if version >= 7 {
+		// size tells version when version information is unreadable
+		if read, ok := readVersion(modules); ok && read != version {
+			err = ErrBadFormat
+			return
+		}
+	}
+
+	m := newMatrix(version)
+	m.drawFunctionPatterns()
+	for y, row := range modules {
+		copy(m.modules[y], row)
+	}
+	m.applyMask(mask)
+	codewords := m.readBits(m.width-1, 6).bytes()[:rawDataModules(version)/8]
+
+	data, err := correctBlocks(codewords, version, level)
+	if err != nil {
+		return
+	}
+	decoded, err = parse(data, qrScheme(version))
+	if err != nil {
+		return
+	}
+	decoded.Version, decoded.Level, decoded.Mask = version, level, mask
+	return
+}
+
+// readFormat finds level and mask closest to either copy of format information
+func readFormat(modules [][]bool) (level Level, mask int, ok bool) {
+	first, second := formatPositions(len(modules))
+	var a, b uint32
+	for i := range first {
+		if modules[first[i][1]][first[i][0]] {
+			a |= 1 << uint(i)
+		}
+		if modules[second[i][1]][second[i][0]] {
+			b |= 1 << uint(i)
+		}
+	}
+
+	best := maxInfoErrors + 1
+	for l := Low; l <= High; l++ {
+		for candidate := 0; candidate < 8; candidate++ {
+			code := formatCode(l, candidate)
+			distance := bits.OnesCount32(code ^ a)
+			if d := bits.OnesCount32(code ^ b); d < distance {
+				distance = d
+			}
+			if distance < best {
+				level, mask, best = l, candidate, distance
+			}
+		}
+	}
+	ok = best <= maxInfoErrors
+	return
+}
+
+// readVersion finds version closest to either copy of version information
+func readVersion(modules [][]bool) (version int, ok bool) {
+	width := len(modules)
+	var a, b uint32
+	for i := 0; i < 18; i++ {
+		x, y := width-11+i%3, i/3
+		if modules[y][x] {
+			a |= 1 << uint(i)
+		}
+		if modules[x][y] {
+			b |= 1 << uint(i)
+		}
+	}
+
+	best := maxInfoErrors + 1
+	for candidate := 7; candidate <= MaxVersion; candidate++ {
+		code := versionCode(candidate)
+		distance := bits.OnesCount32(code ^ a)
+		if d := bits.OnesCount32(code ^ b); d < distance {
+			distance = d
+		}
+		if distance < best {
+			version, best = candidate, distance
+		}
+	}
+	ok = best <= maxInfoErrors
+	return
+}
+
+// correctBlocks reverses interleaving of addECC, corrects errors
+// in every block and joins data codewords of them.
+func correctBlocks(codewords []byte, version int, level Level) (data []byte, err error) {
+	blockCount := eccBlocks[level][version]
+	eccLen := eccCodewordsPerBlock[level][version]
+	shortBlocks := blockCount - len(codewords)%blockCount
+	shortLen := len(codewords) / blockCount
+
+	blocks := make([][]byte, blockCount)
+	for i := range blocks {
+		blocks[i] = make([]byte, shortLen+1)
+	}
+	k := 0
+	for i := 0; i <= shortLen; i++ {
+		for j, block := range blocks {
+			if i != shortLen-eccLen || j >= shortBlocks {
+				block[i] = codewords[k]
+				k++
+			}
+		}
+	}
+
+	for i, block := range blocks {
+		if i < shortBlocks {
+			// drops placeholder lining up with long blocks
+			block = append(block[:shortLen-eccLen], block[shortLen-eccLen+1:]...)
+		}
+		err = rsCorrect(block, eccLen)
+		if err != nil {
+			return
+		}
+		data = append(data, block[:len(block)-eccLen]...)
+	}
+	return
+}
+
+// mode indicators only for decoding
+const (
+	modeTerminator = 0x0
+	modeAppend     = 0x3
+	modeFNC1First  = 0x5
+	modeECI        = 0x7
+	modeKanji      = 0x8
+	modeFNC1Second = 0x9
+)
+
+// kanjiCountBits is the width of character count indicator of Kanji mode
+func kanjiCountBits(version int) int {
+	switch {
+	case version >= 27:
+		return 12
+	case version >= 10:
+		return 10
+	}
+	return 8
+}
+
+// modeScheme tells how segments are headed in a symbology of QR Code family
+type modeScheme struct {
+	// modeBits width of mode indicator
+	modeBits int
+	// terminatorBits width of terminator
+	terminatorBits int
+	// modes maps mode indicator to QR Code one, unknown ones are malformed
+	modes map[uint32]uint32
+	// countBits is width of character count indicator of QR Code mode,
+	// 0 if the mode is not supported.
+	countBits func(mode uint32) int
+}
+
+// qrModes maps QR Code mode indicators to themselves
+var qrModes = map[uint32]uint32{
+	0x1:            0x1,
+	0x2:            0x2,
+	modeAppend:     modeAppend,
+	0x4:            0x4,
+	modeFNC1First:  modeFNC1First,
+	modeECI:        modeECI,
+	modeKanji:      modeKanji,
+	modeFNC1Second: modeFNC1Second,
+}
+
+// qrScheme is modeScheme of QR Code version
+func qrScheme(version int) modeScheme {
+	return modeScheme{
+		modeBits:       4,
+		terminatorBits: 4,
+		modes:          qrModes,
+		countBits: func(mode uint32) int {
+			switch mode {
+			case 0x1:
+				return charCountBits(ModeNumeric, version)
+			case 0x2:
+				return charCountBits(ModeAlphanumeric, version)
+			case 0x4:
+				return charCountBits(ModeByte, version)
+			}
+			return kanjiCountBits(version)
+		},
+	}
+}
+
+// parse reads segments from data codewords per scheme
+func parse(data []byte, scheme modeScheme) (decoded *Decoded, err error) {
+	decoded = new(Decoded)
+	r := &bitReader{data: data}
+	part := Part{}
+	flush := func() {
+		if len(part.Data) > 0 {
+			decoded.Parts = append(decoded.Parts, part)
+		}
+	}
+
+	for r.left() > 0 && !r.zeros(scheme.terminatorBits) {
+		indicator, ok := r.read(scheme.modeBits)
+		if !ok {
+			// fewer bits left than a mode indicator
+			break
+		}
+		mode, ok := scheme.modes[indicator]
+		if !ok {
+			err = ErrBadData
+			return
+		}
+
+		switch mode {
+		case modeAppend:
+			v, ok := r.read(16)
+			if !ok {
+				err = ErrBadData
+				return
+			}
+			decoded.Append = &StructuredAppend{
+				Index:  int(v >> 12),
+				Total:  int(v>>8&0xf) + 1,
+				Parity: byte(v),
+			}
+		case modeFNC1First:
+			decoded.GS1 = true
+		case modeFNC1Second:
+			// application indicator
+			if _, ok := r.read(8); !ok {
+				err = ErrBadData
+				return
+			}
+		case modeECI:
+			var eci int
+			eci, err = readECI(r)
+			if err != nil {
+				return
+			}
+			flush()
+			part = Part{ECI: eci}
+		default:
+			width := scheme.countBits(mode)
+			if width == 0 {
+				err = ErrBadData
+				return
+			}
+			var segment []byte
+			segment, err = readSegment(r, mode, width, decoded.GS1)
+			if err != nil {
+				return
+			}
+			part.Data = append(part.Data, segment...)
+			part.Kanji = part.Kanji || mode == modeKanji
+		}
+	}
+	flush()
+	return
+}
+
+// readECI reads ECI designator of one to three bytes
+func readECI(r *bitReader) (eci int, err error) {
+	first, ok := r.read(8)
+	if !ok {
+		err = ErrBadData
+		return
+	}
+	var rest uint32
+	switch {
+	case first&0x80 == 0:
+		eci = int(first)
+		return
+	case first&0xc0 == 0x80:
+		rest, ok = r.read(8)
+		eci = int(first&0x3f)<<8 | int(rest)
+	case first&0xe0 == 0xc0:
+		rest, ok = r.read(16)
+		eci = int(first&0x1f)<<16 | int(rest)
+	default:
+		ok = false
+	}
+	if !ok {
+		err = ErrBadData
+	}
+	return
+}
+
+// readSegment reads a segment of numeric, alphanumeric, byte or Kanji mode,
+// whose character count indicator is width bits.
+func readSegment(r *bitReader, mode uint32, width int, gs1 bool) (segment []byte, err error) {
+	v, ok := r.read(width)
+	if !ok {
+		err = ErrBadData
+		return
+	}
+	count := int(v)
+
+	switch mode {
+	case 0x1:
+		for ; count > 0 && ok; count -= 3 {
+			digits := minInt(count, 3)
+			v, ok = r.read(digits*3 + 1)
+			text := []byte{byte('0' + v/100), byte('0' + v/10%10), byte('0' + v%10)}
+			if v >= [4]uint32{0, 10, 100, 1000}[digits] {
+				ok = false
+			}
+			segment = append(segment, text[3-digits:]...)
+		}
+	case 0x2:
+		var text []byte
+		for ; count > 0 && ok; count -= 2 {
+			if count == 1 {
+				v, ok = r.read(6)
+				if v >= 45 {
+					ok = false
+					break
+				}
+				text = append(text, alphanumericCharset[v])
+				break
+			}
+			v, ok = r.read(11)
+			if v >= 45*45 {
+				ok = false
+				break
+			}
+			text = append(text, alphanumericCharset[v/45], alphanumericCharset[v%45])
+		}
+		if gs1 {
+			// % is group separator, and %% is % itself
+			text = []byte(strings.NewReplacer("%%", "%", "%", "\x1d").Replace(string(text)))
+		}
+		segment = text
+	case 0x4:
+		if count*8 > r.left() {
+			ok = false
+			break
+		}
+		segment = make([]byte, count)
+		for i := range segment {
+			v, _ = r.read(8)
+			segment[i] = byte(v)
+		}
+	default:
+		for ; count > 0 && ok; count-- {
+			v, ok = r.read(13)
+			code := v/0xc0<<8 | v%0xc0
+			if code < 0x1f00 {
+				code += 0x8140
+			} else {
+				code += 0xc140
+			}
+			segment = append(segment, byte(code>>8), byte(code))
+		}
+	}
+	if !ok {
+		segment, err = nil, ErrBadData
+	}
+	return
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,242 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qr
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReadGolden(t *testing.T) {
+	code, err := Encode([]Segment{NumericSegment("01234567")}, Options{Version: 1, Level: Medium, Mask: AutoMask})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newMatrix(1)
+	m.drawFunctionPatterns()
+	for y, row := range code.Modules {
+		copy(m.modules[y], row)
+	}
+	m.applyMask(code.Mask)
+	got := m.readBits(m.width-1, 6).bytes()[:rawDataModules(1)/8]
+	if want := append(append([]byte(nil), goldenData...), goldenECC...); !bytes.Equal(got, want) {
+		t.Errorf("codewords = % x, want % x", got, want)
+	}
+}
+
+func TestReadRoundTrip(t *testing.T) {
+	for _, version := range []int{1, 2, 6, 7, 10, 14, 27, 40} {
+		for level := Low; level <= High; level++ {
+			for _, mask := range []int{version % 8, (version + 3) % 8} {
+				// a quarter or so of data capacity, in three modes
+				text := strings.Repeat("0123456789", dataCodewords(version, level)/16) + "42"
+				segments := []Segment{
+					NumericSegment(text),
+					AlphanumericSegment("QR"),
+					ByteSegment([]byte{0}),
+				}
+				code, err := Encode(segments, Options{Version: version, Level: level, Mask: mask})
+				if err != nil {
+					t.Errorf("%d-%d mask %d: %v", version, level, mask, err)
+					continue
+				}
+				decoded, err := Read(code.Modules)
+				if err != nil {
+					t.Errorf("%d-%d mask %d: %v", version, level, mask, err)
+					continue
+				}
+				if decoded.Version != version || decoded.Level != level || decoded.Mask != mask {
+					t.Errorf("%d-%d mask %d: read %d-%d mask %d",
+						version, level, mask, decoded.Version, decoded.Level, decoded.Mask)
+				}
+				if got, want := string(decoded.Content()), text+"QR\x00"; got != want {
+					t.Errorf("%d-%d mask %d: content = %q, want %q", version, level, mask, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestReadHeader(t *testing.T) {
+	appendHeader := &StructuredAppend{Index: 2, Total: 5, Parity: 0xa7}
+	code, err := Encode([]Segment{ByteSegment([]byte("\xe4\xbd\xa0\xe5\xa5\xbd"))}, Options{
+		Level:  Quartile,
+		Mask:   AutoMask,
+		Append: appendHeader,
+		ECI:    26,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Read(code.Modules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Append == nil || *decoded.Append != *appendHeader {
+		t.Errorf("append = %+v, want %+v", decoded.Append, appendHeader)
+	}
+	if len(decoded.Parts) != 1 || decoded.Parts[0].ECI != 26 || string(decoded.Parts[0].Data) != "你好" {
+		t.Errorf("parts = %+v, want 你好 in ECI 26", decoded.Parts)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(b *bitBuffer)
+		want  Decoded
+		err   error
+	}{
+		{
+			name: "gs1",
+			build: func(b *bitBuffer) {
+				b.append(modeFNC1First, 4)
+				b.append(0x2, 4)
+				b.append(5, 9)
+				b.append(10*45+38, 11) // A%
+				b.append(11*45+38, 11) // B%
+				b.append(38, 6)        // %
+			},
+			want: Decoded{GS1: true, Parts: []Part{{Data: []byte("A\x1dB%")}}},
+		},
+		{
+			name: "kanji",
+			build: func(b *bitBuffer) {
+				b.append(modeKanji, 4)
+				b.append(1, 8)
+				b.append(0xd9f, 13) // 0x935f
+			},
+			want: Decoded{Parts: []Part{{Data: []byte{0x93, 0x5f}, Kanji: true}}},
+		},
+		{
+			name: "eci",
+			build: func(b *bitBuffer) {
+				b.append(0x4, 4)
+				b.append(1, 8)
+				b.append('a', 8)
+				b.append(modeECI, 4)
+				b.append(0x80|1000>>8, 8)
+				b.append(1000&0xff, 8)
+				b.append(0x4, 4)
+				b.append(1, 8)
+				b.append('b', 8)
+			},
+			want: Decoded{Parts: []Part{{Data: []byte("a")}, {ECI: 1000, Data: []byte("b")}}},
+		},
+		{
+			name: "numeric out of range",
+			build: func(b *bitBuffer) {
+				b.append(0x1, 4)
+				b.append(3, 10)
+				b.append(1000, 10)
+			},
+			err: ErrBadData,
+		},
+		{
+			name: "unknown mode",
+			build: func(b *bitBuffer) {
+				b.append(0xf, 4)
+			},
+			err: ErrBadData,
+		},
+		{
+			name: "byte count overflows",
+			build: func(b *bitBuffer) {
+				b.append(0x4, 4)
+				b.append(200, 8)
+				b.append('a', 8)
+			},
+			err: ErrBadData,
+		},
+	}
+	for _, tt := range tests {
+		var b bitBuffer
+		tt.build(&b)
+		b.pad(len(b)+4+7, 4)
+		decoded, err := parse(b.bytes(), qrScheme(1))
+		if err != tt.err {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if decoded.GS1 != tt.want.GS1 || len(decoded.Parts) != len(tt.want.Parts) {
+			t.Errorf("%s: decoded %+v, want %+v", tt.name, decoded, tt.want)
+			continue
+		}
+		for i, part := range decoded.Parts {
+			want := tt.want.Parts[i]
+			if part.ECI != want.ECI || part.Kanji != want.Kanji || !bytes.Equal(part.Data, want.Data) {
+				t.Errorf("%s: part %d = %+v, want %+v", tt.name, i, part, want)
+			}
+		}
+	}
+}
+
+func TestReadInfoErrors(t *testing.T) {
+	code, err := Encode([]Segment{AlphanumericSegment("VERSION SEVEN")}, Options{Version: 7, Level: Low, Mask: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := len(code.Modules)
+	first, second := formatPositions(size)
+
+	tests := []struct {
+		name   string
+		points [][2]int
+		ok     bool
+	}{
+		{"three bits of each format copy", [][2]int{first[0], first[5], first[14], second[1], second[7], second[13]}, true},
+		{"first format copy", [][2]int{first[0], first[1], first[2], first[3], first[4], first[5], first[6]}, true},
+		{"both format copies", [][2]int{
+			first[0], first[1], first[2], first[3], first[4],
+			second[0], second[1], second[2], second[3], second[4],
+		}, false},
+		{"three bits of each version copy", [][2]int{{size - 11, 0}, {size - 10, 1}, {size - 9, 5}, {0, size - 11}, {1, size - 10}, {5, size - 9}}, true},
+	}
+	for _, tt := range tests {
+		decoded, err := Read(flipped(code.Modules, tt.points...))
+		if !tt.ok {
+			if err != ErrBadFormat {
+				t.Errorf("%s: err = %v, want %v", tt.name, err, ErrBadFormat)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if decoded.Version != 7 || decoded.Level != Low || decoded.Mask != 5 || string(decoded.Content()) != "VERSION SEVEN" {
+			t.Errorf("%s: read %d-%d mask %d %q", tt.name, decoded.Version, decoded.Level, decoded.Mask, decoded.Content())
+		}
+	}
+}
+
+func TestReadCorrectsCodewords(t *testing.T) {
+	code, err := Encode([]Segment{ByteSegment([]byte("error correction"))}, Options{Version: 5, Level: High, Mask: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := len(code.Modules)
+	// a 4x4 blot of modules in the middle
+	var points [][2]int
+	for y := size/2 - 2; y < size/2+2; y++ {
+		for x := size/2 - 2; x < size/2+2; x++ {
+			points = append(points, [2]int{x, y})
+		}
+	}
+	decoded, err := Read(flipped(code.Modules, points...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(decoded.Content()); got != "error correction" {
+		t.Errorf("content = %q, want %q", got, "error correction")
+	}
+}
@@ -0,0 +1,491 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// rmqrBlock is count blocks of total codewords, data of which are data codewords
+type rmqrBlock struct {
+	count, total, data int
+}
+
+// rmqrSymbol describes a rMQR version, per ISO/IEC 23941
+type rmqrSymbol struct {
+	height, width int
+	// charCountBits per mode
+	charCountBits [3]int
+	// blocks of Medium and High level
+	blocks [2][]rmqrBlock
+}
+
+// MaxRMQRVersion count of rMQR versions
+const MaxRMQRVersion = 32
+
+// rmqrSymbols in order of version indicator
+var rmqrSymbols = [MaxRMQRVersion]rmqrSymbol{
+	{7, 43, [3]int{4, 3, 3}, [2][]rmqrBlock{{{1, 13, 6}}, {{1, 13, 3}}}},
+	{7, 59, [3]int{5, 5, 4}, [2][]rmqrBlock{{{1, 21, 12}}, {{1, 21, 7}}}},
+	{7, 77, [3]int{6, 5, 5}, [2][]rmqrBlock{{{1, 32, 20}}, {{1, 32, 10}}}},
+	{7, 99, [3]int{7, 6, 5}, [2][]rmqrBlock{{{1, 44, 28}}, {{1, 44, 14}}}},
+	{7, 139, [3]int{7, 6, 6}, [2][]rmqrBlock{{{1, 68, 44}}, {{2, 34, 12}}}},
+	{9, 43, [3]int{5, 5, 4}, [2][]rmqrBlock{{{1, 21, 12}}, {{1, 21, 7}}}},
+	{9, 59, [3]int{6, 5, 5}, [2][]rmqrBlock{{{1, 33, 21}}, {{1, 33, 11}}}},
+	{9, 77, [3]int{7, 6, 5}, [2][]rmqrBlock{{{1, 49, 31}}, {{1, 24, 8}, {1, 25, 9}}}},
+	{9, 99, [3]int{7, 6, 6}, [2][]rmqrBlock{{{1, 66, 42}}, {{2, 33, 11}}}},
+	{9, 139, [3]int{8, 7, 6}, [2][]rmqrBlock{{{1, 49, 31}, {1, 50, 32}}, {{3, 33, 11}}}},
+	{11, 27, [3]int{4, 4, 3}, [2][]rmqrBlock{{{1, 15, 7}}, {{1, 15, 5}}}},
+	{11, 43, [3]int{6, 5, 5}, [2][]rmqrBlock{{{1, 31, 19}}, {{1, 31, 11}}}},
+	{11, 59, [3]int{7, 6, 5}, [2][]rmqrBlock{{{1, 47, 31}}, {{1, 23, 7}, {1, 24, 8}}}},
+	{11, 77, [3]int{7, 6, 6}, [2][]rmqrBlock{{{1, 67, 43}}, {{1, 33, 11}, {1, 34, 12}}}},
+	{11, 99, [3]int{8, 7, 6}, [2][]rmqrBlock{{{1, 44, 28}, {1, 45, 29}}, {{1, 44, 14}, {1, 45, 15}}}},
+	{11, 139, [3]int{8, 7, 7}, [2][]rmqrBlock{{{2, 66, 42}}, {{3, 44, 14}}}},
+	{13, 27, [3]int{5, 5, 4}, [2][]rmqrBlock{{{1, 21, 12}}, {{1, 21, 7}}}},
+	{13, 43, [3]int{6, 6, 5}, [2][]rmqrBlock{{{1, 41, 27}}, {{1, 41, 13}}}},
+	{13, 59, [3]int{7, 6, 6}, [2][]rmqrBlock{{{1, 60, 38}}, {{2, 30, 10}}}},
+	{13, 77, [3]int{7, 7, 6}, [2][]rmqrBlock{{{1, 42, 26}, {1, 43, 27}}, {{1, 42, 14}, {1, 43, 15}}}},
+	{13, 99, [3]int{8, 7, 7}, [2][]rmqrBlock{{{1, 56, 36}, {1, 57, 37}}, {{1, 37, 11}, {2, 38, 12}}}},
+	{13, 139, [3]int{8, 8, 7}, [2][]rmqrBlock{{{2, 55, 35}, {1, 56, 36}}, {{2, 41, 13}, {2, 42, 14}}}},
+	{15, 43, [3]int{7, 6, 6}, [2][]rmqrBlock{{{1, 51, 33}}, {{1, 25, 7}, {1, 26, 8}}}},
+	{15, 59, [3]int{7, 7, 6}, [2][]rmqrBlock{{{1, 74, 48}}, {{2, 37, 13}}}},
+	{15, 77, [3]int{8, 7, 7}, [2][]rmqrBlock{{{1, 51, 33}, {1, 52, 34}}, {{2, 34, 10}, {1, 35, 11}}}},
+	{15, 99, [3]int{8, 7, 7}, [2][]rmqrBlock{{{2, 68, 44}}, {{4, 34, 12}}}},
+	{15, 139, [3]int{9, 8, 7}, [2][]rmqrBlock{{{2, 66, 42}, {1, 67, 43}}, {{1, 39, 13}, {4, 40, 14}}}},
+	{17, 43, [3]int{7, 6, 6}, [2][]rmqrBlock{{{1, 30, 18}, {1, 31, 19}}, {{1, 30, 10}, {1, 31, 11}}}},
+	{17, 59, [3]int{8, 7, 6}, [2][]rmqrBlock{{{2, 44, 28}}, {{2, 44, 14}}}},
+	{17, 77, [3]int{8, 7, 7}, [2][]rmqrBlock{{{2, 61, 39}}, {{1, 40, 12}, {2, 41, 13}}}},
+	{17, 99, [3]int{8, 8, 7}, [2][]rmqrBlock{{{2, 53, 33}, {1, 54, 34}}, {{4, 40, 14}}}},
+	{17, 139, [3]int{9, 8, 8}, [2][]rmqrBlock{{{4, 58, 38}}, {{2, 38, 12}, {4, 39, 13}}}},
+}
+
+// rmqrAlignmentColumns maps width to centers of alignment patterns
+var rmqrAlignmentColumns = map[int][]int{
+	27:  nil,
+	43:  {21},
+	59:  {19, 39},
+	77:  {25, 51},
+	99:  {23, 49, 75},
+	139: {27, 55, 83, 111},
+}
+
+// RMQRName returns name of rMQR version like R7x43,
+// version starts from 1.
+func RMQRName(version int) string {
+	symbol := rmqrSymbols[version-1]
+	return fmt.Sprintf("R%dx%d", symbol.height, symbol.width)
+}
+
+// RMQRVersion looks up rMQR version by name like R7x43,
+// ok is false if there is no such one.
+func RMQRVersion(name string) (version int, ok bool) {
+	for v := 1; v <= MaxRMQRVersion; v++ {
+		if strings.EqualFold(RMQRName(v), name) {
+			return v, true
+		}
+	}
+	return
+}
+
+// dataCodewords sums data codewords in blocks
+func (s rmqrSymbol) dataCodewords(level int) (sum int) {
+	for _, block := range s.blocks[level] {
+		sum += block.count * block.data
+	}
+	return
+}
+
+// EncodeRMQR builds rectangular Micro QR Code from segments.
+//
+// opts.Version is 1 to MaxRMQRVersion in order of ISO/IEC 23941 from R7x43 to R17x139,
+// or 0 for the smallest one which fits.
+// Level should be Medium or High. There is only one mask pattern,
+// and Structured Append is not supported.
+func EncodeRMQR(segments []Segment, opts Options) (code *Code, err error) {
+	level := -1
+	switch opts.Level {
+	case Medium:
+		level = 0
+	case High:
+		level = 1
+	default:
+		err = errors.New("rMQR supports error correction level M and H")
+		return
+	}
+	if opts.Mask != AutoMask {
+		err = errors.New("rMQR has only one mask pattern")
+		return
+	}
+	if opts.Version < 0 || opts.Version > MaxRMQRVersion {
+		err = fmt.Errorf("rMQR version should be between 1 and %d", MaxRMQRVersion)
+		return
+	}
+	if opts.Append != nil {
+		err = errors.New("rMQR does not support Structured Append")
+		return
+	}
+	if opts.ECI < 0 || opts.ECI > MaxECI {
+		err = errors.New("ECI should be between 0 and 999999")
+		return
+	}
+	var header bitBuffer
+	if opts.ECI > 0 {
+		header = eciBits(opts.ECI, 3)
+	}
+
+	version := 0
+	for v := 1; v <= MaxRMQRVersion; v++ {
+		if opts.Version != 0 && v != opts.Version {
+			continue
+		}
+		symbol := rmqrSymbols[v-1]
+		used, ok := rmqrBitLength(segments, symbol)
+		if !ok || len(header)+used > symbol.dataCodewords(level)*8 {
+			continue
+		}
+		// smallest area, then lower height
+		if version == 0 || symbol.width*symbol.height < rmqrSymbols[version-1].width*rmqrSymbols[version-1].height {
+			version = v
+		}
+	}
+	if version == 0 {
+		err = ErrTooLong
+		return
+	}
+	symbol := rmqrSymbols[version-1]
+
+	bits := header
+	for _, segment := range segments {
+		bits.append(uint32(segment.Mode)+1, 3)
+		bits.append(uint32(segment.Count), symbol.charCountBits[segment.Mode])
+		bits = append(bits, segment.bits...)
+	}
+	bits.pad(symbol.dataCodewords(level)*8, 3)
+
+	m := newGrid(symbol.width, symbol.height)
+	m.version = version
+	m.drawRMQRFunctionPatterns()
+	m.drawRMQRFormat(level, version)
+	m.drawBits(rmqrCodewords(bits.bytes(), symbol.blocks[level]), m.width-2, -1)
+	// the one and only mask
+	m.applyMask(4)
+
+	code = &Code{
+		Version: version,
+		Level:   opts.Level,
+		Mask:    AutoMask,
+		Modules: m.modules,
+	}
+	return
+}
+
+// rmqrBitLength is how many bits segments take in rMQR symbol,
+// ok is false if any count overflows its indicator.
+func rmqrBitLength(segments []Segment, symbol rmqrSymbol) (bits int, ok bool) {
+	for _, segment := range segments {
+		width := symbol.charCountBits[segment.Mode]
+		if segment.Count >= 1<<uint(width) {
+			return
+		}
+		bits += 3 + width + len(segment.bits)
+	}
+	ok = true
+	return
+}
+
+// rmqrCodewords splits data into blocks, appends error correction
+// codewords to each, then interleaves them into bits.
+func rmqrCodewords(data []byte, blocks []rmqrBlock) (bits bitBuffer) {
+	var (
+		dataBlocks [][]byte
+		eccBlocks  [][]byte
+		longest    int
+	)
+	for _, block := range blocks {
+		divisor := rsDivisor(block.total - block.data)
+		for i := 0; i < block.count; i++ {
+			dataBlocks = append(dataBlocks, data[:block.data])
+			eccBlocks = append(eccBlocks, rsRemainder(data[:block.data], divisor))
+			data = data[block.data:]
+			if block.data > longest {
+				longest = block.data
+			}
+		}
+	}
+
+	for i := 0; i < longest; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				bits.append(uint32(block[i]), 8)
+			}
+		}
+	}
+	for i := range eccBlocks[0] {
+		for _, block := range eccBlocks {
+			bits.append(uint32(block[i]), 8)
+		}
+	}
+	return
+}
+
+// drawRMQRFunctionPatterns draws finder, finder sub-pattern, corner finders,
+// alignment and timing patterns of rMQR, then reserves format area.
+func (m *matrix) drawRMQRFunctionPatterns() {
+	w, h := m.width, m.height
+
+	// timing patterns on edges
+	for x := 0; x < w; x++ {
+		m.set(x, 0, x%2 == 0)
+		m.set(x, h-1, x%2 == 0)
+	}
+	for y := 0; y < h; y++ {
+		m.set(0, y, y%2 == 0)
+		m.set(w-1, y, y%2 == 0)
+	}
+
+	// alignment patterns on top and bottom, joined by timing pattern
+	for _, center := range rmqrAlignmentColumns[w] {
+		for y := 0; y < h; y++ {
+			m.set(center, y, y%2 == 0)
+		}
+		for _, row := range []int{1, h - 2} {
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					m.set(center+dx, row+dy, dx != 0 || dy != 0)
+				}
+			}
+		}
+	}
+
+	m.drawFinder(3, 3)
+	// finder sub-pattern looks like QR alignment pattern
+	m.drawAlignment(w-3, h-3)
+
+	// corner finder patterns
+	m.set(w-1, 0, true)
+	m.set(w-2, 0, true)
+	m.set(w-3, 0, true)
+	m.set(w-1, 1, true)
+	m.set(w-2, 1, false)
+	m.set(0, h-1, true)
+	m.set(1, h-1, true)
+	m.set(2, h-1, true)
+	if h >= 11 {
+		m.set(0, h-2, true)
+		m.set(1, h-2, false)
+	}
+}
+
+// rmqrFormatCode is 18-bit format information of level, 0 for Medium and 1 for High,
+// and version, BCH coded but not masked.
+func rmqrFormatCode(level, version int) uint32 {
+	data := uint32(level<<5 | (version - 1))
+	remainder := data
+	for i := 0; i < 12; i++ {
+		remainder = (remainder << 1) ^ ((remainder >> 11) * 0x1f25)
+	}
+	return data<<12 | remainder
+}
+
+// masks of format information beside finder and finder sub-pattern
+const (
+	rmqrFinderSideMask = 0x1fab2
+	rmqrSubSideMask    = 0x20a7b
+)
+
+// rmqrFormatPositions are where bit i of format information lies
+// beside finder and above finder sub-pattern of symbol in width x height.
+func rmqrFormatPositions(width, height int) (finderSide, subSide [18][2]int) {
+	for i := 0; i < 18; i++ {
+		finderSide[i] = [2]int{8 + i/5, 1 + i%5}
+		if i < 15 {
+			subSide[i] = [2]int{width - 8 + i/5, height - 6 + i%5}
+		} else {
+			subSide[i] = [2]int{width - 5 + i - 15, height - 6}
+		}
+	}
+	return
+}
+
+// drawRMQRFormat draws both copies of format information,
+// beside finder and above finder sub-pattern.
+func (m *matrix) drawRMQRFormat(level, version int) {
+	code := rmqrFormatCode(level, version)
+	finderSide, subSide := rmqrFormatPositions(m.width, m.height)
+	for i := 0; i < 18; i++ {
+		m.set(finderSide[i][0], finderSide[i][1], (code^rmqrFinderSideMask)>>uint(i)&1 == 1)
+		m.set(subSide[i][0], subSide[i][1], (code^rmqrSubSideMask)>>uint(i)&1 == 1)
+	}
+}
+
+// rmqrModes maps rMQR mode indicator to QR Code one
+var rmqrModes = map[uint32]uint32{
+	1: 0x1,
+	2: 0x2,
+	3: 0x4,
+	4: modeKanji,
+	5: modeFNC1First,
+	6: modeFNC1Second,
+	7: modeECI,
+}
+
+// ReadRMQR decodes rMQR symbol from its modules, dark ones are true,
+// without quiet zone. Mask of decoded is AutoMask as there is only one.
+//
+// Kanji mode is not supported.
+func ReadRMQR(modules [][]bool) (decoded *Decoded, err error) {
+	height := len(modules)
+	width := 0
+	if height > 0 {
+		width = len(modules[0])
+	}
+	version := 0
+	for v := 1; v <= MaxRMQRVersion; v++ {
+		if rmqrSymbols[v-1].width == width && rmqrSymbols[v-1].height == height {
+			version = v
+		}
+	}
+	if version == 0 {
+		err = errors.New("size of symbol is invalid")
+		return
+	}
+	for _, row := range modules {
+		if len(row) != width {
+			err = errors.New("symbol is not rectangular")
+			return
+		}
+	}
+
+	level, read, ok := readRMQRFormat(modules)
+	if !ok || read != version {
+		err = ErrBadFormat
+		return
+	}
+	symbol := rmqrSymbols[version-1]
+
+	m := newGrid(width, height)
+	m.version = version
+	m.drawRMQRFunctionPatterns()
+	m.drawRMQRFormat(level, version)
+	for y, row := range modules {
+		copy(m.modules[y], row)
+	}
+	m.applyMask(4)
+
+	data, err := rmqrCorrect(m.readBits(m.width-2, -1).bytes(), symbol.blocks[level])
+	if err != nil {
+		return
+	}
+	decoded, err = parse(data, modeScheme{
+		modeBits:       3,
+		terminatorBits: 3,
+		modes:          rmqrModes,
+		countBits: func(mode uint32) int {
+			switch mode {
+			case 0x1:
+				return symbol.charCountBits[ModeNumeric]
+			case 0x2:
+				return symbol.charCountBits[ModeAlphanumeric]
+			case 0x4:
+				return symbol.charCountBits[ModeByte]
+			}
+			return 0
+		},
+	})
+	if err != nil {
+		return
+	}
+	decoded.Version, decoded.Level, decoded.Mask = version, [2]Level{Medium, High}[level], AutoMask
+	return
+}
+
+// readRMQRFormat finds level and version closest to either copy of format information
+func readRMQRFormat(modules [][]bool) (level, version int, ok bool) {
+	finderSide, subSide := rmqrFormatPositions(len(modules[0]), len(modules))
+	var a, b uint32
+	for i := 0; i < 18; i++ {
+		if modules[finderSide[i][1]][finderSide[i][0]] {
+			a |= 1 << uint(i)
+		}
+		if modules[subSide[i][1]][subSide[i][0]] {
+			b |= 1 << uint(i)
+		}
+	}
+	a ^= rmqrFinderSideMask
+	b ^= rmqrSubSideMask
+
+	best := maxInfoErrors + 1
+	for l := 0; l < 2; l++ {
+		for candidate := 1; candidate <= MaxRMQRVersion; candidate++ {
+			code := rmqrFormatCode(l, candidate)
+			distance := bits.OnesCount32(code ^ a)
+			if d := bits.OnesCount32(code ^ b); d < distance {
+				distance = d
+			}
+			if distance < best {
+				level, version, best = l, candidate, distance
+			}
+		}
+	}
+	ok = best <= maxInfoErrors
+	return
+}
+
+// rmqrCorrect reverses interleaving of rmqrCodewords, corrects errors
+// in every block and joins data codewords of them.
+func rmqrCorrect(codewords []byte, blocks []rmqrBlock) (data []byte, err error) {
+	var (
+		dataBlocks [][]byte
+		dataLens   []int
+		longest    int
+		total      int
+	)
+	eccLen := blocks[0].total - blocks[0].data
+	for _, block := range blocks {
+		for i := 0; i < block.count; i++ {
+			dataBlocks = append(dataBlocks, make([]byte, 0, block.total))
+			dataLens = append(dataLens, block.data)
+			total += block.total
+		}
+		if block.data > longest {
+			longest = block.data
+		}
+	}
+	if len(codewords) < total {
+		err = ErrBadData
+		return
+	}
+
+	k := 0
+	for i := 0; i < longest; i++ {
+		for j, size := range dataLens {
+			if i < size {
+				dataBlocks[j] = append(dataBlocks[j], codewords[k])
+				k++
+			}
+		}
+	}
+	for i := 0; i < eccLen; i++ {
+		for j := range dataBlocks {
+			dataBlocks[j] = append(dataBlocks[j], codewords[k])
+			k++
+		}
+	}
+
+	for j, block := range dataBlocks {
+		err = rsCorrect(block, eccLen)
+		if err != nil {
+			return
+		}
+		data = append(data, block[:dataLens[j]]...)
+	}
+	return
+}
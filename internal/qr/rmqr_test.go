@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qr
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRMQRFormatCode(t *testing.T) {
+	// same BCH code as QR version information, see ISO/IEC 18004 Table D.1
+	tests := []struct {
+		level, version int
+		want           uint32
+	}{
+		{0, 8, 0x07c94},
+		{0, 9, 0x085bc},
+		{0, 10, 0x09a99},
+		{0, 11, 0x0a4d3},
+		{0, 16, 0x0f928},
+	}
+	for _, tt := range tests {
+		if got := rmqrFormatCode(tt.level, tt.version); got != tt.want {
+			t.Errorf("rmqrFormatCode(%d, %d) = %#x, want %#x", tt.level, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestRMQRDataModules(t *testing.T) {
+	for version := 1; version <= MaxRMQRVersion; version++ {
+		symbol := rmqrSymbols[version-1]
+		m := newGrid(symbol.width, symbol.height)
+		m.version = version
+		m.drawRMQRFunctionPatterns()
+		m.drawRMQRFormat(0, version)
+		modules := len(m.readBits(m.width-2, -1))
+		for level := range symbol.blocks {
+			total := 0
+			for _, block := range symbol.blocks[level] {
+				total += block.count * block.total
+			}
+			if modules < total*8 || modules >= total*8+8 {
+				t.Errorf("%s level %d has %d data modules for %d codewords", RMQRName(version), level, modules, total)
+			}
+		}
+	}
+}
+
+func TestRMQRRoundTrip(t *testing.T) {
+	for version := 1; version <= MaxRMQRVersion; version++ {
+		for _, level := range []Level{Medium, High} {
+			symbol := rmqrSymbols[version-1]
+			// fills about a sixth of data capacity
+			digits := fmt.Sprintf("%0*d", symbol.dataCodewords(int(level-Medium)/2)*8/20, version)
+			segments := []Segment{AlphanumericSegment("R"), NumericSegment(digits)}
+			code, err := EncodeRMQR(segments, Options{Version: version, Level: level, Mask: AutoMask})
+			if err != nil {
+				t.Errorf("%s level %d: %v", RMQRName(version), level, err)
+				continue
+			}
+			decoded, err := ReadRMQR(code.Modules)
+			if err != nil {
+				t.Errorf("%s level %d: %v", RMQRName(version), level, err)
+				continue
+			}
+			if decoded.Version != version || decoded.Level != level || decoded.Mask != AutoMask {
+				t.Errorf("%s level %d: read version %d level %d mask %d",
+					RMQRName(version), level, decoded.Version, decoded.Level, decoded.Mask)
+			}
+			if got, want := string(decoded.Content()), "R"+digits; got != want {
+				t.Errorf("%s level %d: content = %q, want %q", RMQRName(version), level, got, want)
+			}
+		}
+	}
+}
+
+func TestReadRMQRECI(t *testing.T) {
+	code, err := EncodeRMQR([]Segment{ByteSegment([]byte("rMQR"))}, Options{Level: Medium, Mask: AutoMask, ECI: 26})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ReadRMQR(code.Modules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Parts) != 1 || decoded.Parts[0].ECI != 26 || string(decoded.Parts[0].Data) != "rMQR" {
+		t.Errorf("parts = %+v, want rMQR in ECI 26", decoded.Parts)
+	}
+}
+
+func TestReadRMQRErrors(t *testing.T) {
+	code, err := EncodeRMQR([]Segment{AlphanumericSegment("RECTANGULAR")}, Options{Version: 10, Level: High, Mask: AutoMask})
+	if err != nil {
+		t.Fatal(err)
+	}
+	height, width := len(code.Modules), len(code.Modules[0])
+
+	// first codeword starts above finder sub-pattern
+	decoded, err := ReadRMQR(flipped(code.Modules, [2]int{width - 2, height - 6}, [2]int{width - 2, height - 7}))
+	if err != nil {
+		t.Fatalf("corrupt codeword: %v", err)
+	}
+	if got := string(decoded.Content()); got != "RECTANGULAR" {
+		t.Errorf("corrupt codeword: content = %q, want RECTANGULAR", got)
+	}
+
+	// copy beside finder is unreadable, the other one is still there
+	decoded, err = ReadRMQR(flipped(code.Modules, [2]int{8, 1}, [2]int{8, 2}, [2]int{8, 3}, [2]int{8, 4}, [2]int{8, 5}))
+	if err != nil {
+		t.Fatalf("corrupt format: %v", err)
+	}
+	if decoded.Version != 10 || decoded.Level != High {
+		t.Errorf("corrupt format: read version %d level %d", decoded.Version, decoded.Level)
+	}
+
+	if _, err = ReadRMQR(code.Modules[1:]); err == nil {
+		t.Error("symbol of invalid size is read")
+	}
+}
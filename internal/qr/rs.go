@@ -0,0 +1,183 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qr
+
+import (
+	"errors"
+)
+
+// gfMultiply multiplies x and y in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func gfMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11d)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+// rsDivisor returns the generator polynomial of degree,
+// coefficients from the highest to the lowest power, the leading 1 omitted.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder computes Reed-Solomon error correction codewords of data
+func rsRemainder(data []byte, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coefficient := range divisor {
+			result[i] ^= gfMultiply(coefficient, factor)
+		}
+	}
+	return result
+}
+
+// gfExp and gfLog are powers and logarithms of 0x02 in GF(2^8)
+var gfExp, gfLog = func() (exp [512]byte, log [256]byte) {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		exp[i], log[x] = x, byte(i)
+		x = gfMultiply(x, 0x02)
+	}
+	// doubled to skip modulo in multiplication
+	for i := 255; i < len(exp); i++ {
+		exp[i] = exp[i-255]
+	}
+	return
+}()
+
+// gfMul multiplies x and y by table
+func gfMul(x, y byte) byte {
+	if x == 0 || y == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[x])+int(gfLog[y])]
+}
+
+// gfDivide divides x by non-zero y
+func gfDivide(x, y byte) byte {
+	if x == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[x])+255-int(gfLog[y])]
+}
+
+// gfEvaluate evaluates polynomial at x, coefficients from the lowest power
+func gfEvaluate(poly []byte, x byte) (result byte) {
+	for i := len(poly) - 1; i >= 0; i-- {
+		result = gfMul(result, x) ^ poly[i]
+	}
+	return
+}
+
+// errUncorrectable block has more errors than ECC can fix
+var errUncorrectable = errors.New("too many errors to correct")
+
+// rsCorrect fixes errors in block in place, which is data followed by
+// eccLen error correction codewords as made by rsRemainder.
+func rsCorrect(block []byte, eccLen int) (err error) {
+	// syndromes, block is a polynomial from the highest power
+	syndromes := make([]byte, eccLen)
+	clean := true
+	for i := range syndromes {
+		var s byte
+		x := gfExp[i]
+		for _, b := range block {
+			s = gfMul(s, x) ^ b
+		}
+		syndromes[i] = s
+		clean = clean && s == 0
+	}
+	if clean {
+		return
+	}
+
+	// Berlekamp-Massey for error locator, from the lowest power
+	locator, previous := []byte{1}, []byte{1}
+	errorCount, shift, lastDiscrepancy := 0, 1, byte(1)
+	for n := 0; n < eccLen; n++ {
+		discrepancy := syndromes[n]
+		for i := 1; i <= errorCount && i < len(locator); i++ {
+			discrepancy ^= gfMul(locator[i], syndromes[n-i])
+		}
+		if discrepancy == 0 {
+			shift++
+			continue
+		}
+		factor := gfDivide(discrepancy, lastDiscrepancy)
+		next := make([]byte, maxInt(len(locator), len(previous)+shift))
+		copy(next, locator)
+		for i, c := range previous {
+			next[i+shift] ^= gfMul(factor, c)
+		}
+		if 2*errorCount <= n {
+			previous, lastDiscrepancy = locator, discrepancy
+			errorCount = n + 1 - errorCount
+			shift = 1
+		} else {
+			shift++
+		}
+		locator = next
+	}
+	if 2*errorCount > eccLen {
+		err = errUncorrectable
+		return
+	}
+
+	// Chien search, error at power p makes locator(2^-p) zero
+	var positions []int
+	for p := 0; p < len(block); p++ {
+		if gfEvaluate(locator, gfExp[(255-p%255)%255]) == 0 {
+			positions = append(positions, p)
+		}
+	}
+	if len(positions) != errorCount {
+		err = errUncorrectable
+		return
+	}
+
+	// Forney, evaluator is syndromes times locator modulo x^eccLen
+	evaluator := make([]byte, eccLen)
+	for i := range evaluator {
+		for j := 0; j <= i && j < len(locator); j++ {
+			evaluator[i] ^= gfMul(locator[j], syndromes[i-j])
+		}
+	}
+	// formal derivative keeps odd powers only
+	derivative := make([]byte, len(locator))
+	for i := 1; i < len(locator); i += 2 {
+		derivative[i-1] = locator[i]
+	}
+	for _, p := range positions {
+		x := gfExp[p%255]
+		inverse := gfExp[(255-p%255)%255]
+		denominator := gfEvaluate(derivative, inverse)
+		if denominator == 0 {
+			err = errUncorrectable
+			return
+		}
+		block[len(block)-1-p] ^= gfMul(x, gfDivide(gfEvaluate(evaluator, inverse), denominator))
+	}
+	return
+}
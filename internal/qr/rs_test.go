@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qr
+
+import (
+	"bytes"
+	"testing"
+)
+
+// "01234567" in 1-M, ISO/IEC 18004 Annex I
+var (
+	goldenData = []byte{
+		0x10, 0x20, 0x0c, 0x56, 0x61, 0x80, 0xec, 0x11,
+		0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+	}
+	goldenECC = []byte{0xa5, 0x24, 0xd4, 0xc1, 0xed, 0x36, 0xc7, 0x87, 0x2c, 0x55}
+)
+
+func TestRSRemainder(t *testing.T) {
+	if got := rsRemainder(goldenData, rsDivisor(len(goldenECC))); !bytes.Equal(got, goldenECC) {
+		t.Errorf("rsRemainder = % x, want % x", got, goldenECC)
+	}
+}
+
+func TestRSCorrect(t *testing.T) {
+	clean := append(append([]byte(nil), goldenData...), goldenECC...)
+	tests := []struct {
+		name string
+		// errors position to xor value
+		errors map[int]byte
+		ok     bool
+	}{
+		{"clean", nil, true},
+		{"one in data", map[int]byte{0: 0xff}, true},
+		{"one in ecc", map[int]byte{len(clean) - 1: 0x01}, true},
+		{"five", map[int]byte{1: 0x10, 4: 0x33, 9: 0x80, 15: 0x01, 20: 0xaa}, true},
+		{"six", map[int]byte{0: 0x01, 2: 0x02, 5: 0x04, 8: 0x08, 12: 0x10, 17: 0x20}, false},
+	}
+	for _, tt := range tests {
+		block := append([]byte(nil), clean...)
+		for position, value := range tt.errors {
+			block[position] ^= value
+		}
+		err := rsCorrect(block, len(goldenECC))
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%s: errors beyond capacity are corrected into % x", tt.name, block)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(block, clean) {
+			t.Errorf("%s: corrected into % x, want % x", tt.name, block, clean)
+		}
+	}
+}
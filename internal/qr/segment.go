@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qr
+
+import (
+	"strings"
+)
+
+// Mode of a data segment
+type Mode int
+
+const (
+	// ModeNumeric digits 0-9
+	ModeNumeric Mode = iota
+	// ModeAlphanumeric digits, upper case letters and " $%*+-./:"
+	ModeAlphanumeric
+	// ModeByte arbitrary bytes
+	ModeByte
+)
+
+// alphanumericCharset of ModeAlphanumeric, in order of their values
+const alphanumericCharset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// Segment is a run of data encoded in the same mode
+type Segment struct {
+	Mode Mode
+	// number of characters, or bytes in ModeByte
+	Count int
+	// encoded data without mode indicator and character count
+	bits bitBuffer
+}
+
+// NumericSegment encodes digits
+func NumericSegment(digits string) Segment {
+	var bits bitBuffer
+	for i := 0; i < len(digits); i += 3 {
+		end := i + 3
+		if end > len(digits) {
+			end = len(digits)
+		}
+		var v uint32
+		for _, c := range digits[i:end] {
+			v = v*10 + uint32(c-'0')
+		}
+		bits.append(v, (end-i)*3+1)
+	}
+	return Segment{Mode: ModeNumeric, Count: len(digits), bits: bits}
+}
+
+// AlphanumericSegment encodes text made of alphanumericCharset
+func AlphanumericSegment(text string) Segment {
+	var bits bitBuffer
+	for i := 0; i+1 < len(text); i += 2 {
+		v := strings.IndexByte(alphanumericCharset, text[i])*45 + strings.IndexByte(alphanumericCharset, text[i+1])
+		bits.append(uint32(v), 11)
+	}
+	if len(text)%2 == 1 {
+		bits.append(uint32(strings.IndexByte(alphanumericCharset, text[len(text)-1])), 6)
+	}
+	return Segment{Mode: ModeAlphanumeric, Count: len(text), bits: bits}
+}
+
+// ByteSegment encodes arbitrary bytes
+func ByteSegment(data []byte) Segment {
+	var bits bitBuffer
+	for _, b := range data {
+		bits.append(uint32(b), 8)
+	}
+	return Segment{Mode: ModeByte, Count: len(data), bits: bits}
+}
+
+// eciBits is ECI mode indicator, modeBits wide,
+// followed by designator of assignment number eci.
+func eciBits(eci, modeBits int) (bits bitBuffer) {
+	bits.append(0x7, modeBits)
+	switch {
+	case eci < 1<<7:
+		bits.append(uint32(eci), 8)
+	case eci < 1<<14:
+		bits.append(0x2, 2)
+		bits.append(uint32(eci), 14)
+	default:
+		bits.append(0x6, 3)
+		bits.append(uint32(eci), 21)
+	}
+	return
+}
+
+// MakeSegment encodes data in the most compact mode it fits
+func MakeSegment(data []byte) Segment {
+	switch {
+	case isNumeric(data):
+		return NumericSegment(string(data))
+	case isAlphanumeric(data):
+		return AlphanumericSegment(string(data))
+	default:
+		return ByteSegment(data)
+	}
+}
+
+// isNumeric tells whether data are all digits
+func isNumeric(data []byte) bool {
+	for _, b := range data {
+		if b < '0' || b > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isAlphanumeric tells whether data fits in ModeAlphanumeric
+func isAlphanumeric(data []byte) bool {
+	for _, b := range data {
+		if strings.IndexByte(alphanumericCharset, b) < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+// Package zbar is a minimal binding of ZBar bar code reader,
+// which reports symbol type, quality and location along with data.
+package zbar
+
+// #cgo LDFLAGS: -lzbar
+// #include <stdlib.h>
+// #include <zbar.h>
+//
+// static void set_data(zbar_image_t *image, void *data, unsigned long length) {
+//     zbar_image_set_data(image, data, length, zbar_image_free_data);
+// }
+import "C"
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"unsafe"
+)
+
+// Type symbol type of ZBar
+//
+// Values are taken from zbar.h, some of them are unknown to older ZBar.
+type Type int
+
+// symbol types
+const (
+	None       Type = 0
+	EAN2       Type = 2
+	EAN5       Type = 5
+	EAN8       Type = 8
+	UPCE       Type = 9
+	ISBN10     Type = 10
+	UPCA       Type = 12
+	EAN13      Type = 13
+	ISBN13     Type = 14
+	I25        Type = 25
+	DataBar    Type = 34
+	DataBarExp Type = 35
+	Codabar    Type = 38
+	Code39     Type = 39
+	PDF417     Type = 57
+	QRCode     Type = 64
+	Code93     Type = 93
+	Code128    Type = 128
+)
+
+// cfgBinary ZBAR_CFG_BINARY, unknown to ZBar older than 0.23
+const cfgBinary = 4
+
+// typeMask ZBAR_SYMBOL, masks out add-on flags
+const typeMask = 0xff
+
+// fourccY800 8 bit grayscale image format
+const fourccY800 = 0x30303859
+
+// ErrScan error reported by ZBar when scanning
+var ErrScan = errors.New("error occurred when scanning")
+
+// Symbol a symbol found in image
+type Symbol struct {
+	Type Type
+	// Data raw data of symbol
+	Data []byte
+	// Quality relative quality of symbol, larger is better
+	Quality int
+	// Points location of symbol in image, as a polygon
+	Points []image.Point
+}
+
+// Scanner scans images for symbols
+//
+// Scanner is not safe for concurrent use.
+type Scanner struct {
+	scanner *C.zbar_image_scanner_t
+}
+
+// NewScanner makes a Scanner, which should be destroyed after use.
+func NewScanner() *Scanner {
+	return &Scanner{
+		scanner: C.zbar_image_scanner_create(),
+	}
+}
+
+// Destroy releases scanner
+func (s *Scanner) Destroy() {
+	C.zbar_image_scanner_destroy(s.scanner)
+}
+
+// Enable enables or disables symbol type t,
+// None applies to all types.
+func (s *Scanner) Enable(t Type, enabled bool) (err error) {
+	var value C.int
+	if enabled {
+		value = 1
+	}
+
+	if C.zbar_image_scanner_set_config(s.scanner, C.zbar_symbol_type_t(t), C.ZBAR_CFG_ENABLE, value) != 0 {
+		err = errors.New("symbol type is not supported")
+	}
+	return
+}
+
+// SetBinary makes data of symbol type t raw bytes
+// instead of text converted to UTF-8, if binary is true.
+func (s *Scanner) SetBinary(t Type, binary bool) (err error) {
+	var value C.int
+	if binary {
+		value = 1
+	}
+
+	if C.zbar_image_scanner_set_config(s.scanner, C.zbar_symbol_type_t(t), cfgBinary, value) != 0 {
+		err = errors.New("binary mode is not supported")
+	}
+	return
+}
+
+// Scan scans img for symbols
+//
+// symbols is empty if there's nothing found.
+func (s *Scanner) Scan(img image.Image) (symbols []Symbol, err error) {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return
+	}
+
+	gray := image.NewGray(bounds)
+	draw.Draw(gray, bounds, img, bounds.Min, draw.Src)
+
+	input := C.zbar_image_create()
+	defer C.zbar_image_destroy(input)
+	C.zbar_image_set_format(input, fourccY800)
+	C.zbar_image_set_size(input, C.uint(bounds.Dx()), C.uint(bounds.Dy()))
+	// ZBar holds the data while scanning, so it lives in C memory
+	// and is freed along with input.
+	C.set_data(input, C.CBytes(gray.Pix), C.ulong(len(gray.Pix)))
+
+	result := C.zbar_scan_image(s.scanner, input)
+	if result < 0 {
+		err = ErrScan
+		return
+	}
+
+	for symbol := C.zbar_image_first_symbol(input); symbol != nil; symbol = C.zbar_symbol_next(symbol) {
+		item := Symbol{
+			Type:    Type(C.zbar_symbol_get_type(symbol) & typeMask),
+			Data:    C.GoBytes(unsafe.Pointer(C.zbar_symbol_get_data(symbol)), C.int(C.zbar_symbol_get_data_length(symbol))),
+			Quality: int(C.zbar_symbol_get_quality(symbol)),
+		}
+		points := C.zbar_symbol_get_loc_size(symbol)
+		for i := C.uint(0); i < points; i++ {
+			item.Points = append(item.Points, image.Point{
+				X: int(C.zbar_symbol_get_loc_x(symbol, i)),
+				Y: int(C.zbar_symbol_get_loc_y(symbol, i)),
+			}.Add(bounds.Min))
+		}
+		symbols = append(symbols, item)
+	}
+
+	return
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qrcode
+
+import (
+	"image"
+	"image/draw"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// MaxLogoRatio is the max ratio of logo width to symbol width(quiet zone excluded).
+//
+// Modules under the logo are lost, this cap keeps the loss
+// well within what error correction level H recovers.
+const MaxLogoRatio = 0.3
+
+// logoArea returns the square in module coordinates reserved for logo,
+// which lies in the middle of a bitmap of modules x modules.
+func logoArea(modules, quietZone int) image.Rectangle {
+	symbol := modules - 2*quietZone
+	side := int(float64(symbol) * MaxLogoRatio)
+	// symbol width is odd, so should be the side to stay centered
+	if (symbol-side)%2 != 0 {
+		side--
+	}
+	min := quietZone + (symbol-side)/2
+	return image.Rect(min, min, min+side, min+side)
+}
+
+// clearArea returns a copy of bitmap whose modules within area are light
+func clearArea(bitmap [][]bool, area image.Rectangle) [][]bool {
+	cleared := make([][]bool, len(bitmap))
+	for y, row := range bitmap {
+		cleared[y] = make([]bool, len(row))
+		for x, dark := range row {
+			cleared[y][x] = dark && !image.Pt(x, y).In(area)
+		}
+	}
+	return cleared
+}
+
+// fitLogo returns the largest rectangle with logo's aspect ratio
+// centered within area.
+func fitLogo(logo image.Image, area image.Rectangle) image.Rectangle {
+	bounds := logo.Bounds()
+	w, h := area.Dx(), area.Dy()
+	if bounds.Dx()*h > bounds.Dy()*w {
+		h = bounds.Dy() * w / bounds.Dx()
+	} else {
+		w = bounds.Dx() * h / bounds.Dy()
+	}
+	min := area.Min.Add(image.Pt((area.Dx()-w)/2, (area.Dy()-h)/2))
+	return image.Rectangle{Min: min, Max: min.Add(image.Pt(w, h))}
+}
+
+// drawLogo composites logo onto img, scaled to fit within area in pixel
+func drawLogo(img image.Image, logo image.Image, area image.Rectangle) *image.NRGBA {
+	canvas := image.NewNRGBA(img.Bounds())
+	draw.Draw(canvas, canvas.Bounds(), img, img.Bounds().Min, draw.Src)
+	xdraw.CatmullRom.Scale(canvas, fitLogo(logo, area), logo, logo.Bounds(), draw.Over, nil)
+	return canvas
+}
@@ -0,0 +1,234 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qrcode
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/pkg/errors"
+)
+
+// Option sets up QREncoder made by NewEncoder,
+// telling why if the setting is invalid.
+type Option func(q *QREncoder) error
+
+// NewEncoder makes an encoder with options applied in order,
+// returning error of the first invalid one,
+// or of the combination, e.g. a mask pattern the symbology does not have.
+//
+// Anything not set takes its default, e.g. a PNG image of QR Code
+// with ecc M, as large as the QR Code needs.
+func NewEncoder(opts ...Option) (q *QREncoder, err error) {
+	q = new(QREncoder)
+	for _, opt := range opts {
+		err = opt(q)
+		if err != nil {
+			q = nil
+			return
+		}
+	}
+	_, err = q.check()
+	if err != nil {
+		q = nil
+		return
+	}
+	return
+}
+
+// WithContent sets content to encode
+func WithContent(content string) Option {
+	return func(q *QREncoder) error {
+		q.Content = content
+		return nil
+	}
+}
+
+// WithFormat sets type of file to produce
+func WithFormat(format Format) Option {
+	return func(q *QREncoder) error {
+		if !IsValidType(string(format)) {
+			return errors.Wrapf(ErrUnknownType, "type %s", format)
+		}
+		q.Type = string(format)
+		return nil
+	}
+}
+
+// WithSymbology picks symbology, see Symbologies
+func WithSymbology(symbology string) Option {
+	return func(q *QREncoder) error {
+		if !IsValidSymbology(symbology) {
+			return errors.Errorf("unknown symbology %s", symbology)
+		}
+		q.Symbology = symbology
+		return nil
+	}
+}
+
+// WithECC sets error correction level, ECCLow to ECCHigh
+func WithECC(ecc string) Option {
+	return func(q *QREncoder) error {
+		if !IsValidECC(ecc) {
+			return errors.New("ecc should be one of L, M, Q or H")
+		}
+		q.ECC = ecc
+		return nil
+	}
+}
+
+// WithVersion fixes version of QR Code family,
+// up to MaxVersion, MaxMicroVersion or MaxRMQRVersion per symbology,
+// checked against symbology set so far, and by NewEncoder against the final one.
+func WithVersion(version int) Option {
+	return func(q *QREncoder) error {
+		maxVersion, _ := versionMaskRange(q.symbology())
+		if maxVersion == AutoVersion {
+			return errors.Errorf("version is not supported by symbology %s", q.symbology())
+		}
+		if version < 1 || version > maxVersion {
+			return errors.Errorf("version should be from 1 to %d for symbology %s", maxVersion, q.symbology())
+		}
+		q.Version = version
+		return nil
+	}
+}
+
+// WithMask fixes mask pattern of QR Code or Micro QR,
+// up to MaxMask or MaxMicroMask per symbology,
+// checked against symbology set so far, and by NewEncoder against the final one.
+func WithMask(mask int) Option {
+	return func(q *QREncoder) error {
+		_, maxMask := versionMaskRange(q.symbology())
+		if maxMask < 0 {
+			return errors.Errorf("mask is not supported by symbology %s", q.symbology())
+		}
+		if mask < 0 || mask > maxMask {
+			return errors.Errorf("mask should be from 0 to %d for symbology %s", maxMask, q.symbology())
+		}
+		q.Mask = &mask
+		return nil
+	}
+}
+
+// WithECI declares character set of content by ECI assignment number, see ECI
+func WithECI(eci int) Option {
+	return func(q *QREncoder) error {
+		if eci <= 0 || eci > MaxECI {
+			return errors.Errorf("eci should be from 1 to %d", MaxECI)
+		}
+		q.ECI = eci
+		return nil
+	}
+}
+
+// WithSize sets image size in pixel,
+// which grows if it is too small to hold every module unless WithStrict.
+func WithSize(pixels int) Option {
+	return func(q *QREncoder) error {
+		if pixels <= 0 {
+			return errors.New("size should be positive")
+		}
+		q.Size = pixels
+		return nil
+	}
+}
+
+// WithPhysicalSize sets image size in unit, UnitMillimeter or UnitInch,
+// overriding WithSize. See WithDPI.
+func WithPhysicalSize(size float64, unit string) Option {
+	return func(q *QREncoder) error {
+		if size <= 0 {
+			return errors.New("physical size should be positive")
+		}
+		if unit != UnitMillimeter && unit != UnitInch {
+			return errors.New("unit should be one of mm or in")
+		}
+		q.PhysicalSize, q.Unit = size, unit
+		return nil
+	}
+}
+
+// WithDPI sets dots per inch converting between pixel and physical size
+func WithDPI(dpi int) Option {
+	return func(q *QREncoder) error {
+		if dpi <= 0 {
+			return errors.New("dpi should be positive")
+		}
+		q.DPI = dpi
+		return nil
+	}
+}
+
+// WithScale sets pixels per module, overriding WithSize and WithPhysicalSize
+func WithScale(pixelsPerModule int) Option {
+	return func(q *QREncoder) error {
+		if pixelsPerModule <= 0 {
+			return errors.New("scale should be positive")
+		}
+		q.Scale = pixelsPerModule
+		return nil
+	}
+}
+
+// WithStrict makes image size exactly the one set,
+// ErrSizeTooSmall is returned if it can not hold every module.
+func WithStrict() Option {
+	return func(q *QREncoder) error {
+		q.Strict = true
+		return nil
+	}
+}
+
+// WithBorder sets quiet zone width in modules, none if 0
+func WithBorder(modules int) Option {
+	return func(q *QREncoder) error {
+		if modules < 0 {
+			return errors.New("border should not be negative")
+		}
+		q.Border = modules
+		if modules == 0 {
+			q.Border = NoBorder
+		}
+		return nil
+	}
+}
+
+// WithColors sets colors of dark and light modules
+func WithColors(foreground, background color.Color) Option {
+	return func(q *QREncoder) error {
+		if foreground == nil || background == nil {
+			return errors.New("colors should not be nil")
+		}
+		q.Foreground, q.Background = foreground, background
+		return nil
+	}
+}
+
+// WithQuality sets JPEG quality from 1 to 100
+func WithQuality(quality int) Option {
+	return func(q *QREncoder) error {
+		if quality < 1 || quality > 100 {
+			return errors.New("quality should be from 1 to 100")
+		}
+		q.Quality = quality
+		return nil
+	}
+}
+
+// WithLogo puts logo in the center, which raises ecc to ECCHigh.
+// Only QR Code in raster types and SVG supports logo.
+func WithLogo(logo image.Image) Option {
+	return func(q *QREncoder) error {
+		if logo == nil || logo.Bounds().Empty() {
+			return errors.New("logo is empty")
+		}
+		q.Logo = logo
+		return nil
+	}
+}
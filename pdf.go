@@ -0,0 +1,63 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+)
+
+// writePDF renders bitmap into dest as a single page PDF document,
+// whose page is width points(1/72 inch) wide.
+func writePDF(dest io.Writer, bitmap [][]bool, width float64, fg, bg color.Color) (err error) {
+	rows, columns := len(bitmap), len(bitmap[0])
+	unit := width / float64(columns)
+	height := unit * float64(rows)
+
+	// page content
+	var content bytes.Buffer
+	if r, g, b, transparent := rgb(bg); !transparent {
+		fmt.Fprintf(&content, "%.3f %.3f %.3f rg\n0 0 %.4f %.4f re f\n", r, g, b, width, height)
+	}
+	r, g, b, _ := rgb(fg)
+	fmt.Fprintf(&content, "%.3f %.3f %.3f rg\n", r, g, b)
+	eachRun(bitmap, func(x, y, length int) {
+		// PDF origin lies in the bottom left
+		fmt.Fprintf(&content, "%.4f %.4f %.4f %.4f re\n",
+			float64(x)*unit, float64(rows-y-1)*unit, float64(length)*unit, unit)
+	})
+	content.WriteString("f\n")
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.4f %.4f] /Contents 4 0 R /Resources << >> >>", width, height),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+		"<< /Producer (qrcode-api) >>",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(objects)+1, len(objects), xref)
+
+	_, err = buf.WriteTo(dest)
+	return
+}
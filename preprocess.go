@@ -0,0 +1,313 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qrcode
+
+import (
+	"context"
+	"image"
+	"image/draw"
+	"math"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+)
+
+// effort levels of decoding
+const (
+	// EffortLow scans image once as it is
+	EffortLow = "low"
+	// EffortMedium tries resizing, contrast stretching,
+	// adaptive threshold and inversion if nothing found
+	EffortMedium = "medium"
+	// EffortHigh tries sharpening and rotations further
+	EffortHigh = "high"
+	// DefaultEffort default effort level
+	DefaultEffort = EffortLow
+)
+
+const (
+	// maxScanSide images whose longer side is beyond are scaled down
+	maxScanSide = 1600
+	// minScanSide images whose shorter side is below are scaled up
+	minScanSide = 400
+)
+
+// IsValidEffort tells whether effort is a known effort level
+func IsValidEffort(effort string) bool {
+	switch effort {
+	case EffortLow, EffortMedium, EffortHigh:
+		return true
+	}
+	return false
+}
+
+// locate maps a point in processed image back to the original one
+type locate func(p image.Point) image.Point
+
+// pass turns an image into another one to scan
+type pass func(img *image.Gray) (*image.Gray, locate)
+
+// passes lists pipelines of passes tried after the original image,
+// every pipeline starts from the resized grayscale image.
+func passes(effort string) (pipelines [][]pass) {
+	if effort != EffortMedium && effort != EffortHigh {
+		return
+	}
+	pipelines = [][]pass{
+		{},
+		{stretchContrast},
+		{stretchContrast, adaptiveThreshold},
+		{stretchContrast, invert},
+	}
+	if effort != EffortHigh {
+		return
+	}
+	return append(pipelines,
+		[]pass{sharpen, adaptiveThreshold},
+		[]pass{stretchContrast, invert, adaptiveThreshold},
+		[]pass{stretchContrast, rotation(22.5)},
+		[]pass{stretchContrast, rotation(45)},
+		[]pass{stretchContrast, rotation(67.5)},
+	)
+}
+
+// DecodeSymbolsWithEffort decodes symbols of symbologies from image,
+// retrying on processed image until something found, as hard as effort.
+//
+// Locations of symbols are in img's coordinate regardless of processing.
+// symbols and err are both nil when nothing found.
+func DecodeSymbolsWithEffort(img image.Image, effort string, symbologies ...string) (symbols []Symbol, err error) {
+	return DecodeContext(context.Background(), img, effort, symbologies...)
+}
+
+// DecodeContext is DecodeSymbolsWithEffort giving up when ctx is done,
+// in which case err is ctx.Err().
+//
+// ctx is checked before every scan and processing step,
+// while a scan in progress runs to its end.
+// err is of the last scan, earlier failed passes are dropped.
+func DecodeContext(ctx context.Context, img image.Image, effort string, symbologies ...string) (symbols []Symbol, err error) {
+	err = ctx.Err()
+	if err != nil {
+		return
+	}
+	symbols, err = DecodeSymbols(img, symbologies...)
+	if err != nil || len(symbols) > 0 {
+		return
+	}
+	pipelines := passes(effort)
+	if len(pipelines) == 0 {
+		return
+	}
+
+	base, baseLocate := toScanSize(img)
+	for index, pipeline := range pipelines {
+		// the resized image is the same as the original one when not resized
+		if index == 0 && base.Bounds().Size() == img.Bounds().Size() {
+			continue
+		}
+
+		processed, locations := base, []locate{baseLocate}
+		for _, step := range pipeline {
+			err = ctx.Err()
+			if err != nil {
+				return
+			}
+			var loc locate
+			processed, loc = step(processed)
+			locations = append(locations, loc)
+		}
+		err = ctx.Err()
+		if err != nil {
+			return
+		}
+
+		found, passErr := DecodeSymbols(processed, symbologies...)
+		err = passErr
+		if passErr != nil || len(found) == 0 {
+			continue
+		}
+		symbols = found
+		for i := range symbols {
+			for j, point := range symbols[i].Polygon {
+				for k := len(locations) - 1; k >= 0; k-- {
+					point = locations[k](point)
+				}
+				symbols[i].Polygon[j] = point
+			}
+		}
+		return
+	}
+	return
+}
+
+// keep does not move points
+func keep(p image.Point) image.Point {
+	return p
+}
+
+// toScanSize converts img into grayscale, whose origin is at (0, 0),
+// and scales it into a size friendly to scanning.
+func toScanSize(img image.Image) (gray *image.Gray, loc locate) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	scale := 1.0
+	if longer := math.Max(float64(w), float64(h)); longer > maxScanSide {
+		scale = maxScanSide / longer
+	} else if shorter := math.Min(float64(w), float64(h)); shorter > 0 && shorter < minScanSide {
+		scale = math.Min(minScanSide/shorter, maxScanSide/longer)
+	}
+
+	if scale == 1 {
+		gray = image.NewGray(image.Rect(0, 0, w, h))
+		draw.Draw(gray, gray.Bounds(), img, bounds.Min, draw.Src)
+	} else {
+		gray = image.NewGray(image.Rect(0, 0, int(math.Max(1, float64(w)*scale)), int(math.Max(1, float64(h)*scale))))
+		xdraw.BiLinear.Scale(gray, gray.Bounds(), img, bounds, xdraw.Src, nil)
+	}
+	loc = func(p image.Point) image.Point {
+		return image.Point{
+			X: int(float64(p.X) / scale),
+			Y: int(float64(p.Y) / scale),
+		}.Add(bounds.Min)
+	}
+	return
+}
+
+// stretchContrast maps the darkest and lightest 1% of pixels to black and white
+func stretchContrast(img *image.Gray) (*image.Gray, locate) {
+	var histogram [256]int
+	for _, v := range img.Pix {
+		histogram[v]++
+	}
+	cut := len(img.Pix) / 100
+	low, high := 0, 255
+	for sum := 0; low < 255 && sum+histogram[low] <= cut; low++ {
+		sum += histogram[low]
+	}
+	for sum := 0; high > 0 && sum+histogram[high] <= cut; high-- {
+		sum += histogram[high]
+	}
+	if high <= low {
+		return img, keep
+	}
+
+	var table [256]uint8
+	for v := range table {
+		table[v] = uint8(math.Max(0, math.Min(255, float64(v-low)*255/float64(high-low))))
+	}
+	out := image.NewGray(img.Bounds())
+	for i, v := range img.Pix {
+		out.Pix[i] = table[v]
+	}
+	return out, keep
+}
+
+// adaptiveThreshold turns pixels black which are notably darker than their neighborhood,
+// and white otherwise, which copes with uneven lighting.
+func adaptiveThreshold(img *image.Gray) (*image.Gray, locate) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	radius := int(math.Max(7, math.Min(float64(w), float64(h))/16))
+
+	// integral[y][x] is the sum of pixels above and left of (x, y)
+	stride := w + 1
+	integral := make([]uint64, stride*(h+1))
+	for y := 0; y < h; y++ {
+		var row uint64
+		for x := 0; x < w; x++ {
+			row += uint64(img.Pix[y*img.Stride+x])
+			integral[(y+1)*stride+x+1] = integral[y*stride+x+1] + row
+		}
+	}
+
+	out := image.NewGray(bounds)
+	for y := 0; y < h; y++ {
+		top, bottom := maxInt(0, y-radius), minInt(h, y+radius+1)
+		for x := 0; x < w; x++ {
+			left, right := maxInt(0, x-radius), minInt(w, x+radius+1)
+			sum := integral[bottom*stride+right] - integral[top*stride+right] - integral[bottom*stride+left] + integral[top*stride+left]
+			count := uint64((bottom - top) * (right - left))
+			// darker than 85% of local mean
+			if uint64(img.Pix[y*img.Stride+x])*count*100 < sum*85 {
+				out.Pix[y*out.Stride+x] = 0
+			} else {
+				out.Pix[y*out.Stride+x] = 0xff
+			}
+		}
+	}
+	return out, keep
+}
+
+// sharpen enhances edges by a 3x3 kernel
+func sharpen(img *image.Gray) (*image.Gray, locate) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	out := image.NewGray(bounds)
+	copy(out.Pix, img.Pix)
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			i := y*img.Stride + x
+			v := 5*int(img.Pix[i]) - int(img.Pix[i-1]) - int(img.Pix[i+1]) - int(img.Pix[i-img.Stride]) - int(img.Pix[i+img.Stride])
+			out.Pix[i] = uint8(maxInt(0, minInt(255, v)))
+		}
+	}
+	return out, keep
+}
+
+// invert turns light-on-dark symbols into dark-on-light ones
+func invert(img *image.Gray) (*image.Gray, locate) {
+	out := image.NewGray(img.Bounds())
+	for i, v := range img.Pix {
+		out.Pix[i] = 0xff - v
+	}
+	return out, keep
+}
+
+// rotation returns a pass rotating image clockwise by degrees,
+// which helps linear symbols scanned along rows and columns.
+func rotation(degrees float64) pass {
+	return func(img *image.Gray) (*image.Gray, locate) {
+		sin, cos := math.Sincos(degrees * math.Pi / 180)
+		w, h := float64(img.Bounds().Dx()), float64(img.Bounds().Dy())
+		rotatedW, rotatedH := math.Abs(w*cos)+math.Abs(h*sin), math.Abs(w*sin)+math.Abs(h*cos)
+
+		out := image.NewGray(image.Rect(0, 0, int(math.Ceil(rotatedW)), int(math.Ceil(rotatedH))))
+		draw.Draw(out, out.Bounds(), image.White, image.ZP, draw.Src)
+		// rotates around centers of both images
+		cx, cy, rcx, rcy := w/2, h/2, rotatedW/2, rotatedH/2
+		transform := f64.Aff3{
+			cos, -sin, rcx - cos*cx + sin*cy,
+			sin, cos, rcy - sin*cx - cos*cy,
+		}
+		xdraw.BiLinear.Transform(out, transform, img, img.Bounds(), xdraw.Src, nil)
+
+		return out, func(p image.Point) image.Point {
+			x, y := float64(p.X)-rcx, float64(p.Y)-rcy
+			return image.Point{
+				X: int(math.Floor(cos*x + sin*y + cx + 0.5)),
+				Y: int(math.Floor(-sin*x + cos*y + cy + 0.5)),
+			}
+		}
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
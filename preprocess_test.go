@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qrcode
+
+import (
+	"context"
+	"errors"
+	"image"
+	"testing"
+)
+
+// failingDecoder finds nothing, and fails in scans listed in fails, counting from 1
+type failingDecoder struct {
+	scans *int
+	fails map[int]bool
+}
+
+func (d failingDecoder) Symbologies() []string {
+	return []string{SymbologyQR}
+}
+
+func (d failingDecoder) Decode(img image.Image, symbologies ...string) (symbols []Symbol, err error) {
+	*d.scans++
+	if d.fails[*d.scans] {
+		err = errors.New("scan failed")
+	}
+	return
+}
+
+func TestDecodeContextPassError(t *testing.T) {
+	defer func(inUse Decoder) {
+		decoder = inUse
+	}(decoder)
+
+	// the original image, then every pass on image scaled up
+	scans := 1 + len(passes(EffortMedium))
+	tests := []struct {
+		name    string
+		fails   map[int]bool
+		wantErr bool
+	}{
+		{"no failure", nil, false},
+		{"early pass fails", map[int]bool{2: true, 3: true}, false},
+		{"last pass fails", map[int]bool{scans: true}, true},
+	}
+	for _, tt := range tests {
+		var count int
+		decoder = failingDecoder{scans: &count, fails: tt.fails}
+		symbols, err := DecodeContext(context.Background(), image.NewGray(image.Rect(0, 0, 100, 100)), EffortMedium)
+		if count != scans {
+			t.Errorf("%s: scanned %d times, want %d", tt.name, count, scans)
+		}
+		if symbols != nil || (err != nil) != tt.wantErr {
+			t.Errorf("%s: DecodeContext() = %v, %v, want error %v", tt.name, symbols, err, tt.wantErr)
+		}
+	}
+}
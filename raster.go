@@ -0,0 +1,146 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qrcode
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+
+	"golang.org/x/image/bmp"
+)
+
+// rasterize renders bitmap into an image size pixels wide.
+//
+// Every module takes the same whole number of pixels,
+// the symbol is centered and the remaining space is filled with bg.
+// Image is as high as wide for square bitmap, otherwise its height
+// keeps the same margin. Image grows to the minimum size needed
+// if size is too small.
+func rasterize(bitmap [][]bool, size int, fg, bg color.Color) *image.Paletted {
+	rows, columns := len(bitmap), len(bitmap[0])
+	offset, pixelsPerModule := modulePixels(columns, size)
+	if size < columns {
+		size = columns
+	}
+	height := size - (columns-rows)*pixelsPerModule
+
+	// index 0 is the background, which is zero value of Pix
+	img := image.NewPaletted(image.Rect(0, 0, size, height), color.Palette{bg, fg})
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for j := 0; j < pixelsPerModule; j++ {
+				start := img.PixOffset(offset+x*pixelsPerModule, offset+y*pixelsPerModule+j)
+				for i := 0; i < pixelsPerModule; i++ {
+					img.Pix[start+i] = 1
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+// modulePixels tells where module (0, 0) starts and how many pixels
+// a module takes in an image produced by rasterize.
+func modulePixels(modules, size int) (offset, pixelsPerModule int) {
+	if size < modules {
+		size = modules
+	}
+	pixelsPerModule = size / modules
+	offset = (size - modules*pixelsPerModule) / 2
+	return
+}
+
+// writePNG encodes img into dest as PNG
+func writePNG(dest io.Writer, img image.Image) error {
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	return encoder.Encode(dest, img)
+}
+
+// writeJPEG encodes img into dest as JPEG,
+// which has no alpha channel so img is flattened onto white.
+func writeJPEG(dest io.Writer, img image.Image, quality int) error {
+	flattened := image.NewRGBA(img.Bounds())
+	draw.Draw(flattened, flattened.Bounds(), image.White, image.ZP, draw.Src)
+	draw.Draw(flattened, flattened.Bounds(), img, img.Bounds().Min, draw.Over)
+	return jpeg.Encode(dest, flattened, &jpeg.Options{Quality: quality})
+}
+
+// writeGIF encodes img into dest as GIF.
+//
+// Image which is not paletted(e.g. with logo) is dithered with
+// fg, bg and web safe colors, so fg and bg(even transparent) are kept.
+func writeGIF(dest io.Writer, img image.Image, fg, bg color.Color) error {
+	paletted, ok := img.(*image.Paletted)
+	if !ok {
+		p := append(color.Palette{bg, fg}, palette.WebSafe...)
+		paletted = image.NewPaletted(img.Bounds(), p)
+		draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), img, img.Bounds().Min)
+	}
+	return gif.Encode(dest, paletted, nil)
+}
+
+// writeBMP encodes img into dest as BMP
+func writeBMP(dest io.Writer, img image.Image) error {
+	return bmp.Encode(dest, img)
+}
+
+// writeRaster encodes img into dest as fileType,
+// fg and bg are kept by GIF palette and quality is for JPEG.
+func writeRaster(dest io.Writer, fileType string, img image.Image, fg, bg color.Color, quality int) error {
+	switch fileType {
+	case TypeJPEG:
+		return writeJPEG(dest, img, quality)
+	case TypeGIF:
+		return writeGIF(dest, img, fg, bg)
+	case TypeBMP:
+		return writeBMP(dest, img)
+	case TypeWebP:
+		return writeWebP(dest, img)
+	default:
+		return writePNG(dest, img)
+	}
+}
+
+// tile lays images out in a grid as square as possible, in reading order.
+// Every cell is as large as the largest image, which is centered in it.
+func tile(images []image.Image, bg color.Color) *image.NRGBA {
+	var cell int
+	for _, img := range images {
+		if dx := img.Bounds().Dx(); dx > cell {
+			cell = dx
+		}
+		if dy := img.Bounds().Dy(); dy > cell {
+			cell = dy
+		}
+	}
+	columns := int(math.Ceil(math.Sqrt(float64(len(images)))))
+	rows := (len(images) + columns - 1) / columns
+
+	sheet := image.NewNRGBA(image.Rect(0, 0, columns*cell, rows*cell))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(bg), image.ZP, draw.Src)
+	for i, img := range images {
+		bounds := img.Bounds()
+		min := image.Pt(
+			i%columns*cell+(cell-bounds.Dx())/2,
+			i/columns*cell+(cell-bounds.Dy())/2,
+		)
+		draw.Draw(sheet, bounds.Sub(bounds.Min).Add(min), img, bounds.Min, draw.Src)
+	}
+	return sheet
+}
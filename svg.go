@@ -0,0 +1,66 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qrcode
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// writeSVG renders bitmap into dest as a SVG image,
+// width and height are with optional unit, e.g. 360 or 30mm.
+//
+// Every module is an unit square in viewBox, so the image scales
+// without blurring. Dark modules are merged into horizontal runs
+// to keep the path short.
+//
+// logo, if not nil, is embedded as PNG and fit within area(in modules).
+func writeSVG(dest io.Writer, bitmap [][]bool, width, height string, fg, bg color.Color, logo image.Image, area image.Rectangle) (err error) {
+	rows, columns := len(bitmap), len(bitmap[0])
+	w := bufio.NewWriter(dest)
+
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" version="1.1" width="%s" height="%s" viewBox="0 0 %d %d" shape-rendering="crispEdges">
+`, width, height, columns, rows)
+	fmt.Fprintf(w, `<rect width="%d" height="%d" %s/>
+`, columns, rows, svgFill(bg))
+	fmt.Fprintf(w, `<path %s d="`, svgFill(fg))
+	eachRun(bitmap, func(x, y, length int) {
+		fmt.Fprintf(w, "M%d %dh%dv1h-%dz", x, y, length, length)
+	})
+	fmt.Fprint(w, `"/>
+`)
+	if logo != nil {
+		var encoded bytes.Buffer
+		err = writePNG(&encoded, logo)
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(w, `<image x="%d" y="%d" width="%d" height="%d" xlink:href="data:image/png;base64,%s"/>
+`, area.Min.X, area.Min.Y, area.Dx(), area.Dy(), base64.StdEncoding.EncodeToString(encoded.Bytes()))
+	}
+	fmt.Fprint(w, `</svg>
+`)
+
+	err = w.Flush()
+	return
+}
+
+// svgFill produces fill attributes for color c
+func svgFill(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if n.A == 0xff {
+		return fmt.Sprintf(`fill="#%02x%02x%02x"`, n.R, n.G, n.B)
+	}
+	return fmt.Sprintf(`fill="#%02x%02x%02x" fill-opacity="%.3f"`, n.R, n.G, n.B, float64(n.A)/0xff)
+}
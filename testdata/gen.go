@@ -0,0 +1,128 @@
+//go:build ignore
+// +build ignore
+
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+// gen makes the corpus in testdata by encoder of this package,
+// run in project root:
+//
+//	go run testdata/gen.go
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+
+	qrcode "github.com/nanmu42/qrcode-api"
+)
+
+func main() {
+	for name, opts := range map[string][]qrcode.Option{
+		"qr.png": {
+			qrcode.WithContent("https://github.com/nanmu42/qrcode-api"),
+		},
+		"qr-utf8.png": {
+			qrcode.WithContent("二维码，QR Code"),
+			qrcode.WithECC("Q"),
+		},
+		"qr.jpg": {
+			qrcode.WithContent("JPEG of quality 50"),
+			qrcode.WithFormat(qrcode.FormatJPEG),
+			qrcode.WithQuality(50),
+			qrcode.WithScale(5),
+		},
+		"qr.pdf": {
+			qrcode.WithContent("QR Code in PDF"),
+			qrcode.WithFormat(qrcode.FormatPDF),
+		},
+		"ean13.png": {
+			qrcode.WithContent("5901234123457"),
+			qrcode.WithSymbology(qrcode.SymbologyEAN13),
+			qrcode.WithScale(2),
+		},
+		"code128.png": {
+			qrcode.WithContent("Code 128"),
+			qrcode.WithSymbology(qrcode.SymbologyCode128),
+			qrcode.WithScale(2),
+		},
+	} {
+		write(name, encode(opts...))
+	}
+
+	// turned clockwise by 90 degrees
+	upright := decode(encode(qrcode.WithContent("turned right")))
+	bounds := upright.Bounds()
+	turned := image.NewGray(image.Rect(0, 0, bounds.Dy(), bounds.Dx()))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			turned.Set(bounds.Dy()-1-y, x, upright.At(x, y))
+		}
+	}
+	write("qr-turned.png", encodePNG(turned))
+
+	// frames of the same size
+	var animation gif.GIF
+	for _, content := range []string{"first frame", "second frame"} {
+		frame := decode(encode(qrcode.WithContent(content), qrcode.WithVersion(2)))
+		paletted := image.NewPaletted(frame.Bounds(), palette.Plan9)
+		draw.Draw(paletted, paletted.Bounds(), frame, frame.Bounds().Min, draw.Src)
+		animation.Image = append(animation.Image, paletted)
+		animation.Delay = append(animation.Delay, 100)
+	}
+	var buf bytes.Buffer
+	err := gif.EncodeAll(&buf, &animation)
+	if err != nil {
+		log.Fatal(err)
+	}
+	write("qr-frames.gif", buf.Bytes())
+}
+
+func encode(opts ...qrcode.Option) []byte {
+	opts = append([]qrcode.Option{qrcode.WithScale(4), qrcode.WithBorder(4)}, opts...)
+	q, err := qrcode.NewEncoder(opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var buf bytes.Buffer
+	_, err = q.Encode(&buf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodePNG(img image.Image) []byte {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decode(file []byte) image.Image {
+	img, _, err := image.Decode(bytes.NewReader(file))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return img
+}
+
+func write(name string, file []byte) {
+	err := ioutil.WriteFile(filepath.Join("testdata", name), file, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,40 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qrcode
+
+import (
+	"image/color"
+)
+
+// eachRun calls fn for every horizontal run of dark modules in bitmap,
+// with its left-top module position and its length.
+func eachRun(bitmap [][]bool, fn func(x, y, length int)) {
+	for y, row := range bitmap {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fn(start, y, x-start)
+		}
+	}
+}
+
+// rgb splits c into red, green and blue in [0, 1], and tells whether
+// c is fully transparent.
+func rgb(c color.Color) (r, g, b float64, transparent bool) {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	r = float64(n.R) / 0xff
+	g = float64(n.G) / 0xff
+	b = float64(n.B) / 0xff
+	transparent = n.A == 0
+	return
+}
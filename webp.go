@@ -0,0 +1,425 @@
+/*
+ * Copyright (c) 2018 LI Zhennan
+ *
+ * Use of this work is governed by an MIT License.
+ * You may find a license copy in project root.
+ */
+
+package qrcode
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// A minimal lossless WebP(VP8L) encoder.
+//
+// There is no transform or color cache, pixels are coded as literals
+// or backward references to the previous pixel and the pixel above,
+// which works well for QR Codes made of long runs of two colors.
+//
+// See https://developers.google.com/speed/webp/docs/webp_lossless_bitstream_specification
+
+const (
+	vp8lMaxSide      = 1 << 14
+	vp8lLiteralCodes = 256
+	vp8lLengthCodes  = 24
+	vp8lDistanceSyms = 40
+	vp8lMaxLength    = 4096
+	vp8lMinMatch     = 3
+	// distance codes of the pixel above and the one on the left,
+	// per the 2D distance map.
+	vp8lDistanceAbove = 1
+	vp8lDistanceLeft  = 2
+	// max code length of prefix codes and of the code length code
+	vp8lMaxCodeLength       = 15
+	vp8lMaxCodeLengthLength = 7
+)
+
+// vp8lCodeLengthOrder is the order code length code lengths are stored in
+var vp8lCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// vp8lSymbol is a coded pixel, which is either a literal ARGB
+// or a backward reference.
+type vp8lSymbol struct {
+	argb     uint32
+	length   int
+	distance int
+}
+
+// writeWebP encodes img into dest as lossless WebP
+func writeWebP(dest io.Writer, img image.Image) (err error) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= 0 || h <= 0 || w > vp8lMaxSide || h > vp8lMaxSide {
+		err = errors.Errorf("webp: can not encode image of %dx%d", w, h)
+		return
+	}
+
+	pixels := make([]uint32, 0, w*h)
+	opaque := true
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A != 0xff {
+				opaque = false
+			}
+			pixels = append(pixels, uint32(c.A)<<24|uint32(c.R)<<16|uint32(c.G)<<8|uint32(c.B))
+		}
+	}
+	symbols := vp8lMatch(pixels, w)
+
+	// histograms of green(with length prefix), red, blue, alpha and distance
+	var histograms [5][]int
+	histograms[0] = make([]int, vp8lLiteralCodes+vp8lLengthCodes)
+	for i := 1; i < 4; i++ {
+		histograms[i] = make([]int, vp8lLiteralCodes)
+	}
+	histograms[4] = make([]int, vp8lDistanceSyms)
+	for _, s := range symbols {
+		if s.length == 0 {
+			histograms[0][s.argb>>8&0xff]++
+			histograms[1][s.argb>>16&0xff]++
+			histograms[2][s.argb&0xff]++
+			histograms[3][s.argb>>24]++
+			continue
+		}
+		lengthCode, _, _ := vp8lPrefix(s.length)
+		distanceCode, _, _ := vp8lPrefix(s.distance)
+		histograms[0][vp8lLiteralCodes+lengthCode]++
+		histograms[4][distanceCode]++
+	}
+
+	bw := &vp8lBitWriter{}
+	// header
+	bw.write(0x2f, 8)
+	bw.write(uint32(w-1), 14)
+	bw.write(uint32(h-1), 14)
+	if opaque {
+		bw.write(0, 1)
+	} else {
+		bw.write(1, 1)
+	}
+	bw.write(0, 3)
+	// no transform, no color cache, no meta prefix code
+	bw.write(0, 1)
+	bw.write(0, 1)
+	bw.write(0, 1)
+
+	var codes [5]vp8lCode
+	for i, histogram := range histograms {
+		codes[i] = bw.writeCode(histogram)
+	}
+
+	for _, s := range symbols {
+		if s.length == 0 {
+			codes[0].write(bw, int(s.argb>>8&0xff))
+			codes[1].write(bw, int(s.argb>>16&0xff))
+			codes[2].write(bw, int(s.argb&0xff))
+			codes[3].write(bw, int(s.argb>>24))
+			continue
+		}
+		lengthCode, lengthBits, lengthExtra := vp8lPrefix(s.length)
+		codes[0].write(bw, vp8lLiteralCodes+lengthCode)
+		bw.write(lengthExtra, lengthBits)
+		distanceCode, distanceBits, distanceExtra := vp8lPrefix(s.distance)
+		codes[4].write(bw, distanceCode)
+		bw.write(distanceExtra, distanceBits)
+	}
+
+	data := bw.bytes()
+	chunkSize := len(data)
+	padding := chunkSize & 1
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(4+8+chunkSize+padding))
+	buf.WriteString("WEBPVP8L")
+	binary.Write(&buf, binary.LittleEndian, uint32(chunkSize))
+	buf.Write(data)
+	if padding != 0 {
+		buf.WriteByte(0)
+	}
+
+	_, err = buf.WriteTo(dest)
+	return
+}
+
+// vp8lMatch turns pixels into literals and backward references
+// to the pixel on the left or the one above, whichever is longer.
+func vp8lMatch(pixels []uint32, width int) (symbols []vp8lSymbol) {
+	matchLength := func(i, distance int) (length int) {
+		if i < distance {
+			return
+		}
+		for i+length < len(pixels) && length < vp8lMaxLength &&
+			pixels[i+length] == pixels[i+length-distance] {
+			length++
+		}
+		return
+	}
+
+	for i := 0; i < len(pixels); {
+		left := matchLength(i, 1)
+		above := matchLength(i, width)
+		switch {
+		case above >= left && above >= vp8lMinMatch:
+			symbols = append(symbols, vp8lSymbol{length: above, distance: vp8lDistanceAbove})
+			i += above
+		case left >= vp8lMinMatch:
+			symbols = append(symbols, vp8lSymbol{length: left, distance: vp8lDistanceLeft})
+			i += left
+		default:
+			symbols = append(symbols, vp8lSymbol{argb: pixels[i]})
+			i++
+		}
+	}
+	return
+}
+
+// vp8lPrefix splits value(starting from 1) into prefix code and extra bits
+func vp8lPrefix(value int) (code int, extraBits uint, extra uint32) {
+	value--
+	if value < 4 {
+		code = value
+		return
+	}
+	highest := uint(0)
+	for v := value; v > 1; v >>= 1 {
+		highest++
+	}
+	second := (value >> (highest - 1)) & 1
+	extraBits = highest - 1
+	code = int(2*highest) + second
+	extra = uint32(value & (1<<extraBits - 1))
+	return
+}
+
+// vp8lCode is a canonical prefix code
+type vp8lCode struct {
+	lengths []int
+	codes   []uint32
+}
+
+// write emits symbol
+func (c *vp8lCode) write(bw *vp8lBitWriter, symbol int) {
+	bw.write(c.codes[symbol], uint(c.lengths[symbol]))
+}
+
+// newVP8LCode assigns canonical codes per lengths, bit-reversed
+// since they are read bit by bit from the least significant end.
+func newVP8LCode(lengths []int) vp8lCode {
+	var count [vp8lMaxCodeLength + 1]int
+	for _, l := range lengths {
+		count[l]++
+	}
+	count[0] = 0
+	var next [vp8lMaxCodeLength + 2]uint32
+	code := uint32(0)
+	for bits := 1; bits <= vp8lMaxCodeLength; bits++ {
+		code = (code + uint32(count[bits-1])) << 1
+		next[bits] = code
+	}
+
+	codes := make([]uint32, len(lengths))
+	for symbol, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		c := next[l]
+		next[l]++
+		// reverse
+		var reversed uint32
+		for i := 0; i < l; i++ {
+			reversed = reversed<<1 | c&1
+			c >>= 1
+		}
+		codes[symbol] = reversed
+	}
+	return vp8lCode{lengths: lengths, codes: codes}
+}
+
+// writeCode writes prefix code built from histogram and returns it
+func (bw *vp8lBitWriter) writeCode(histogram []int) vp8lCode {
+	var used []int
+	for symbol, count := range histogram {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+
+	// simple code, at most two symbols below 256
+	if len(used) <= 2 && (len(used) == 0 || used[len(used)-1] < 256) {
+		lengths := make([]int, len(histogram))
+		bw.write(1, 1)
+		switch len(used) {
+		case 0:
+			bw.write(0, 1)
+			bw.write(0, 1)
+			bw.write(0, 1)
+		case 1:
+			bw.write(0, 1)
+			bw.write(1, 1)
+			bw.write(uint32(used[0]), 8)
+		case 2:
+			bw.write(1, 1)
+			bw.write(1, 1)
+			bw.write(uint32(used[0]), 8)
+			bw.write(uint32(used[1]), 8)
+			lengths[used[0]] = 1
+			lengths[used[1]] = 1
+		}
+		return newVP8LCode(lengths)
+	}
+
+	// normal code
+	lengths := huffmanLengths(histogram, vp8lMaxCodeLength)
+	var lengthHistogram [19]int
+	for _, l := range lengths {
+		lengthHistogram[l]++
+	}
+	lengthLengths := huffmanLengths(lengthHistogram[:], vp8lMaxCodeLengthLength)
+	lengthCode := newVP8LCode(lengthLengths)
+
+	bw.write(0, 1)
+	bw.write(19-4, 4)
+	for _, symbol := range vp8lCodeLengthOrder {
+		bw.write(uint32(lengthLengths[symbol]), 3)
+	}
+	// every symbol has its code length written
+	bw.write(0, 1)
+	for _, l := range lengths {
+		lengthCode.write(bw, l)
+	}
+
+	return newVP8LCode(lengths)
+}
+
+// huffmanLengths calculates code lengths no longer than limit.
+//
+// There are always at least two symbols with non-zero length,
+// so the code is complete.
+func huffmanLengths(histogram []int, limit int) []int {
+	counts := make([]int, len(histogram))
+	copy(counts, histogram)
+	var used int
+	for _, count := range counts {
+		if count > 0 {
+			used++
+		}
+	}
+	for symbol := 0; used < 2; symbol++ {
+		if counts[symbol] == 0 {
+			counts[symbol] = 1
+			used++
+		}
+	}
+
+	// flatten counts until the tree is shallow enough
+	for floor := 1; ; floor *= 2 {
+		lengths := buildHuffmanLengths(counts, floor)
+		longest := 0
+		for _, l := range lengths {
+			if l > longest {
+				longest = l
+			}
+		}
+		if longest <= limit {
+			return lengths
+		}
+	}
+}
+
+// huffmanNode is a node of Huffman tree
+type huffmanNode struct {
+	weight      int
+	symbol      int
+	left, right *huffmanNode
+}
+
+// huffmanHeap is a min-heap of huffmanNode
+type huffmanHeap []*huffmanNode
+
+func (h huffmanHeap) Len() int { return len(h) }
+func (h huffmanHeap) Less(i, j int) bool {
+	if h[i].weight != h[j].weight {
+		return h[i].weight < h[j].weight
+	}
+	return h[i].symbol < h[j].symbol
+}
+func (h huffmanHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *huffmanHeap) Push(x interface{}) { *h = append(*h, x.(*huffmanNode)) }
+func (h *huffmanHeap) Pop() interface{} {
+	old := *h
+	node := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return node
+}
+
+// buildHuffmanLengths builds Huffman tree with counts lifted to floor
+// and returns depth of every symbol.
+func buildHuffmanLengths(counts []int, floor int) []int {
+	h := make(huffmanHeap, 0, len(counts))
+	for symbol, count := range counts {
+		if count == 0 {
+			continue
+		}
+		if count < floor {
+			count = floor
+		}
+		h = append(h, &huffmanNode{weight: count, symbol: symbol})
+	}
+	heap.Init(&h)
+	for h.Len() > 1 {
+		a := heap.Pop(&h).(*huffmanNode)
+		b := heap.Pop(&h).(*huffmanNode)
+		heap.Push(&h, &huffmanNode{weight: a.weight + b.weight, symbol: -1, left: a, right: b})
+	}
+
+	lengths := make([]int, len(counts))
+	var walk func(node *huffmanNode, depth int)
+	walk = func(node *huffmanNode, depth int) {
+		if node.left == nil {
+			lengths[node.symbol] = depth
+			return
+		}
+		walk(node.left, depth+1)
+		walk(node.right, depth+1)
+	}
+	walk(h[0], 0)
+	return lengths
+}
+
+// vp8lBitWriter packs bits from the least significant end
+type vp8lBitWriter struct {
+	buf   []byte
+	acc   uint64
+	nBits uint
+}
+
+// write appends the lowest n bits of v
+func (bw *vp8lBitWriter) write(v uint32, n uint) {
+	bw.acc |= uint64(v&(1<<n-1)) << bw.nBits
+	bw.nBits += n
+	for bw.nBits >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.nBits -= 8
+	}
+}
+
+// bytes flushes and returns written bytes
+func (bw *vp8lBitWriter) bytes() []byte {
+	if bw.nBits > 0 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc = 0
+		bw.nBits = 0
+	}
+	return bw.buf
+}